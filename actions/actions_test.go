@@ -5,14 +5,35 @@ import (
 
 	"github.com/gobuffalo/packr"
 	"github.com/gobuffalo/suite"
+	"github.com/kradalby/bork/kube"
+	"github.com/kradalby/bork/models"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	restclient "k8s.io/client-go/rest"
+	k8stesting "k8s.io/client-go/testing"
 )
 
 type ActionSuite struct {
 	*suite.Action
+	clientset           *fake.Clientset
+	newKubernetesClient func(*models.Cluster) (*kube.Client, error)
 }
 
 func Test_ActionSuite(t *testing.T) {
-	action, err := suite.NewActionWithFixtures(App(), packr.NewBox("../fixtures"))
+	// Every table is truncated between the tests, so they only run
+	// against the test database
+	if ENV != "test" {
+		t.Skip("run with GO_ENV=test to test the actions against the test database")
+	}
+	if err := models.DB.RawQuery("SELECT 1").Exec(); err != nil {
+		t.Skipf("database not available: %s", err)
+	}
+
+	action, err := suite.NewActionWithFixtures(App(""), packr.NewBox("../fixtures"))
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -22,3 +43,76 @@ func Test_ActionSuite(t *testing.T) {
 	}
 	suite.Run(t, as)
 }
+
+// SetupTest runs the handlers against a fake cluster.
+func (as *ActionSuite) SetupTest() {
+	as.Action.SetupTest()
+
+	as.clientset = newFakeClientset()
+	client := kube.NewClient(as.clientset, &restclient.Config{Host: "https://kubernetes.example.com"})
+
+	as.newKubernetesClient = newKubernetesClient
+	newKubernetesClient = func(*models.Cluster) (*kube.Client, error) {
+		return client, nil
+	}
+}
+
+func (as *ActionSuite) TearDownTest() {
+	newKubernetesClient = as.newKubernetesClient
+	as.Action.TearDownTest()
+}
+
+// login creates a user and logs them in.
+func (as *ActionSuite) login(username string, admin bool) *models.User {
+	user := &models.User{
+		Username:   username,
+		Email:      username + "@example.com",
+		IsAdmin:    admin,
+		IsActive:   true,
+		Provider:   "openid-connect",
+		ProviderID: username,
+	}
+	as.NoError(as.DB.Create(user))
+
+	as.Session.Set("current_user_id", user.ID)
+	return user
+}
+
+// newFakeClientset returns a fake clientset that, like the token
+// controller of a real cluster, gives every new service account a
+// token secret.
+func newFakeClientset() *fake.Clientset {
+	tracker := k8stesting.NewObjectTracker(scheme.Scheme, scheme.Codecs.UniversalDecoder())
+
+	clientset := &fake.Clientset{}
+	clientset.AddReactor("*", "*", k8stesting.ObjectReaction(tracker))
+	clientset.AddWatchReactor("*", func(action k8stesting.Action) (bool, watch.Interface, error) {
+		w, err := tracker.Watch(action.GetResource(), action.GetNamespace())
+		return err == nil, w, err
+	})
+
+	clientset.PrependReactor("create", "serviceaccounts", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if action.GetSubresource() != "" {
+			return false, nil, nil
+		}
+		serviceAccount := action.(k8stesting.CreateAction).GetObject().(*corev1.ServiceAccount)
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      serviceAccount.Name + "-token-abcde",
+				Namespace: action.GetNamespace(),
+				Annotations: map[string]string{
+					corev1.ServiceAccountNameKey: serviceAccount.Name,
+				},
+			},
+			Type: corev1.SecretTypeServiceAccountToken,
+			Data: map[string][]byte{
+				corev1.ServiceAccountTokenKey:  []byte("token-" + serviceAccount.Name),
+				corev1.ServiceAccountRootCAKey: []byte("certificate"),
+			},
+		}
+		serviceAccount.Secrets = append(serviceAccount.Secrets, corev1.ObjectReference{Name: secret.Name})
+		return false, nil, tracker.Add(secret)
+	})
+
+	return clientset
+}
@@ -10,6 +10,8 @@ import (
 	paramlogger "github.com/gobuffalo/mw-paramlogger"
 
 	"github.com/gobuffalo/envy"
+	"github.com/gobuffalo/pop"
+	"github.com/gobuffalo/uuid"
 	"github.com/unrolled/secure"
 
 	// "github.com/gobuffalo/x/sessions"
@@ -71,6 +73,9 @@ func App(kubeconf string) *buffalo.App {
 		apiV1 := app.Group("/api/v1")
 		apiV1.Use(Authorize)
 		apiV1.Use(SetCurrentUser)
+		// Changes are audited, credential routes are wrapped with
+		// AuditCredentials to audit who read them
+		apiV1.Use(Audit)
 
 		users := apiV1.Group("/users")
 		// apiV1.Resource("/users", UsersResource{})
@@ -81,19 +86,47 @@ func App(kubeconf string) *buffalo.App {
 		namespaces := apiV1.Group("/namespaces")
 		namespaces.GET("/prefix/", NamespacePrefix)
 		namespaces.POST("/validate/", NamespaceValidateName)
+		namespaces.GET("/networkpolicies/", NamespaceNetworkPolicies)
+		namespaces.GET("/roleprofiles/", NamespaceRoleProfiles)
+		namespaces.GET("/config/", AuditCredentials(NamespacesConfig))
 		namespaces.Resource("/", NamespacesResource{})
 		namespaces.POST("/{namespace_id}/coowners", NamespaceAddCoOwner)
 		namespaces.DELETE("/{namespace_id}/coowners", NamespaceDeleteCoOwner)
+		namespaces.PUT("/{namespace_id}/coowners/{user_id}/roleprofile", NamespaceSetCoOwnerRoleProfile)
 		namespaces.GET("/{namespace_id}/available_users", NamespaceAvailableUsers)
-		namespaces.GET("/{namespace_id}/token", NamespaceToken)
-		namespaces.GET("/{namespace_id}/certificate", NamespaceCertificate)
-		namespaces.GET("/{namespace_id}/certificateb64", NamespaceCertificateB64)
+		namespaces.GET("/{namespace_id}/token", AuditCredentials(NamespaceToken))
+		namespaces.POST("/{namespace_id}/token/rotate", NamespaceRotateToken)
+		namespaces.GET("/{namespace_id}/certificate", AuditCredentials(NamespaceCertificate))
+		namespaces.GET("/{namespace_id}/certificateb64", AuditCredentials(NamespaceCertificateB64))
 		namespaces.GET("/{namespace_id}/endpoint", NamespaceEndpoint)
-		namespaces.GET("/{namespace_id}/auth", NamespaceAuth)
-		namespaces.GET("/{namespace_id}/config", NamespaceConfig)
+		namespaces.GET("/{namespace_id}/auth", AuditCredentials(NamespaceAuth))
+		namespaces.GET("/{namespace_id}/config", AuditCredentials(NamespaceConfig))
+		namespaces.GET("/{namespace_id}/config/oidc", AuditCredentials(NamespaceOIDCConfig))
+		namespaces.GET("/{namespace_id}/deploy/token", AuditCredentials(NamespaceDeployToken))
+		namespaces.POST("/{namespace_id}/deploy/token/rotate", NamespaceRotateDeployToken)
+		namespaces.GET("/{namespace_id}/deploy/certificate", AuditCredentials(NamespaceDeployCertificate))
+		namespaces.GET("/{namespace_id}/deploy/config", AuditCredentials(NamespaceDeployConfig))
+		namespaces.GET("/{namespace_id}/deploy/auth", AuditCredentials(NamespaceDeployAuth))
+		namespaces.GET("/{namespace_id}/quota", NamespaceQuota)
+		namespaces.PUT("/{namespace_id}/quota", NamespaceSetQuota)
+		namespaces.PUT("/{namespace_id}/networkpolicy", NamespaceSetNetworkPolicy)
+		namespaces.PUT("/{namespace_id}/roleprofile", NamespaceSetRoleProfile)
+		namespaces.GET("/{namespace_id}/status", NamespaceReconcileStatus)
+		namespaces.POST("/{namespace_id}/restore", NamespaceRestore)
+		namespaces.POST("/{namespace_id}/lock", NamespaceLock)
+		namespaces.DELETE("/{namespace_id}/lock", NamespaceUnlock)
+		namespaces.PUT("/{namespace_id}/expiry", NamespaceSetExpiry)
+		namespaces.GET("/{namespace_id}/activity", NamespaceActivity)
+
+		clusters := apiV1.Group("/clusters")
+		clusters.GET("/", ClusterList)
 
 		admin := apiV1.Group("/admin")
 		admin.GET("/dashboard", Dashboard)
+		admin.GET("/sync/plan", AdminSyncPlan)
+		admin.GET("/namespaces/{namespace_id}/drift", AdminNamespaceDrift)
+		admin.POST("/namespaces/{namespace_id}/repair", AdminNamespaceRepair)
+		admin.GET("/audit", AdminAuditEvents)
 
 		app.GET("/{path:.+}", HomeHandler)
 		app.GET("/", HomeHandler)
@@ -115,21 +148,68 @@ func forceSSL() buffalo.MiddlewareFunc {
 	})
 }
 
-func getKubernetesClient() (*kube.Client, error) {
+// newKubernetesClient returns a client for the cluster, or for the
+// default cluster if it is nil. Tests replace it to run the handlers
+// against a fake clientset.
+var newKubernetesClient = func(cluster *models.Cluster) (*kube.Client, error) {
+	if cluster != nil {
+		return kube.NewClusterClient(cluster)
+	}
+
 	if ENV == DEVELOPMENT {
-		client, err := kube.NewOutOfClusterClient(kubernetesConf)
-		if err != nil {
-			return nil, err
-		}
+		return kube.NewOutOfClusterClient(kubernetesConf)
+	}
 
-		return client, err
+	return kube.NewInClusterClient()
+}
+
+func getKubernetesClient() (*kube.Client, error) {
+	return newKubernetesClient(nil)
+}
+
+// getKubernetesClientForCluster returns a client for the cluster with
+// the given ID, or for the default cluster if the ID is null.
+func getKubernetesClientForCluster(tx *pop.Connection, clusterID uuid.NullUUID) (*kube.Client, error) {
+	if !clusterID.Valid {
+		return getKubernetesClient()
 	}
 
-	client, err := kube.NewInClusterClient()
+	cluster := &models.Cluster{}
+	if err := tx.Find(cluster, clusterID.UUID); err != nil {
+		return nil, err
+	}
+
+	return newKubernetesClient(cluster)
+}
+
+// getKubernetesClientsForAllClusters returns a client for the default
+// cluster followed by one for every registered cluster.
+func getKubernetesClientsForAllClusters(tx *pop.Connection) ([]*kube.Client, error) {
+	client, err := getKubernetesClient()
 	if err != nil {
 		return nil, err
 	}
 
-	return client, err
+	clients := []*kube.Client{client}
+
+	clusters := models.Clusters{}
+	if err := tx.Order("name").All(&clusters); err != nil {
+		return nil, err
+	}
+
+	for i := range clusters {
+		client, err := newKubernetesClient(&clusters[i])
+		if err != nil {
+			return nil, err
+		}
+		clients = append(clients, client)
+	}
+
+	return clients, nil
+}
 
+// getKubernetesClientForNamespace returns a client for the cluster the
+// namespace lives on.
+func getKubernetesClientForNamespace(tx *pop.Connection, namespace *models.Namespace) (*kube.Client, error) {
+	return getKubernetesClientForCluster(tx, namespace.ClusterID)
 }
@@ -0,0 +1,283 @@
+package actions
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"reflect"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/pop"
+	"github.com/gobuffalo/uuid"
+	"github.com/kradalby/bork/models"
+	"github.com/pkg/errors"
+)
+
+// Audit records an audit event for every mutating request. Reads of
+// credentials are recorded by AuditCredentials on their routes.
+func Audit(next buffalo.Handler) buffalo.Handler {
+	return func(c buffalo.Context) error {
+		if c.Request().Method == "GET" {
+			return next(c)
+		}
+
+		route, _ := c.Value("current_route").(buffalo.RouteInfo)
+		return audit(c, auditAction(route.HandlerName), next)
+	}
+}
+
+// AuditCredentials records an audit event for every request of a
+// handler handing out credentials, even though it does not change
+// anything. It wraps the handler of each such route.
+func AuditCredentials(next buffalo.Handler) buffalo.Handler {
+	action := auditAction(handlerName(next))
+	return func(c buffalo.Context) error {
+		return audit(c, action, next)
+	}
+}
+
+// audit runs the handler and records its audit event. Events are
+// written outside the transaction of the request, failed requests are
+// recorded too.
+func audit(c buffalo.Context, action string, next buffalo.Handler) error {
+	event := newAuditEvent(c, action)
+
+	err := next(c)
+
+	// Handlers creating an object name it after the fact
+	if namespace, ok := c.Value("audit_namespace").(*models.Namespace); ok {
+		setAuditNamespace(event, namespace)
+	}
+
+	event.Status, event.Outcome = auditResult(err, responseStatus(c))
+	if err != nil {
+		event.Detail += "\nerror: " + errors.Cause(err).Error()
+	}
+
+	if recordErr := models.RecordAuditEvent(models.DB, event); recordErr != nil {
+		log.Printf("[Error] %#v", recordErr)
+	}
+
+	return err
+}
+
+// newAuditEvent returns the event of the request, with the actor and
+// the namespace of the request as target.
+func newAuditEvent(c buffalo.Context, action string) *models.AuditEvent {
+	request := c.Request()
+
+	event := &models.AuditEvent{
+		Source:   models.AuditSourceAPI,
+		Action:   action,
+		SourceIP: request.RemoteAddr,
+		Detail:   request.Method + " " + request.URL.RequestURI(),
+	}
+
+	if host, _, err := net.SplitHostPort(request.RemoteAddr); err == nil {
+		event.SourceIP = host
+	}
+	// Kept apart from the source IP as any client can set it
+	if forwarded := request.Header.Get("X-Forwarded-For"); forwarded != "" {
+		event.Detail += "\nforwarded for: " + forwarded
+	}
+
+	if user, ok := c.Value("current_user").(*models.User); ok {
+		event.ActorID = uuid.NullUUID{UUID: user.ID, Valid: true}
+		event.Actor = user.Username
+	}
+
+	if id := c.Param("namespace_id"); id != "" {
+		event.TargetType = "namespace"
+		event.TargetID = id
+
+		// Looked up before the handler runs, the namespace may be
+		// deleted by it
+		namespace := &models.Namespace{}
+		if err := models.DB.Find(namespace, id); err == nil {
+			setAuditNamespace(event, namespace)
+		}
+	}
+
+	return event
+}
+
+// setAuditTarget names the namespace a handler created as the target of
+// the audit event of the request.
+func setAuditTarget(c buffalo.Context, namespace *models.Namespace) {
+	c.Set("audit_namespace", namespace)
+}
+
+func setAuditNamespace(event *models.AuditEvent, namespace *models.Namespace) {
+	event.TargetType = "namespace"
+	event.TargetID = namespace.ID.String()
+	event.Target = namespace.Name
+	event.NamespaceID = uuid.NullUUID{UUID: namespace.ID, Valid: true}
+}
+
+// auditAction returns the handler without its package, like
+// "NamespaceToken" or "NamespacesResource.Destroy".
+func auditAction(handlerName string) string {
+	action := handlerName[strings.LastIndex(handlerName, "/")+1:]
+	action = strings.TrimPrefix(action, "actions.")
+	return strings.TrimSuffix(action, "-fm")
+}
+
+// handlerName returns the full name of the handler function, the same
+// way buffalo names the handler of a route.
+func handlerName(h buffalo.Handler) string {
+	return runtime.FuncForPC(reflect.ValueOf(h).Pointer()).Name()
+}
+
+// responseStatus returns the status code written to the response, 0 if
+// none was written yet.
+func responseStatus(c buffalo.Context) int {
+	if response, ok := c.Response().(*buffalo.Response); ok {
+		return response.Status
+	}
+	return 0
+}
+
+// auditResult returns the status code and outcome of a request, from
+// the error the handler returned or else the status of the response.
+func auditResult(err error, status int) (int, string) {
+	if err != nil {
+		status = 500
+		switch httpErr := errors.Cause(err).(type) {
+		case buffalo.HTTPError:
+			status = httpErr.Status
+		case *buffalo.HTTPError:
+			status = httpErr.Status
+		}
+	}
+	if status == 0 {
+		status = 200
+	}
+
+	if status >= 400 {
+		return status, models.AuditFailure
+	}
+	return status, models.AuditSuccess
+}
+
+// AdminAuditEvents gets the audit events, newest first. They can be
+// filtered on actor_id, namespace_id, action, target_type, outcome,
+// source and a since and until time in RFC 3339, and paginated with
+// page and per_page. This function is mapped to the path
+// GET /admin/audit
+func AdminAuditEvents(c buffalo.Context) error {
+	user, err := getLoggedInUser(c)
+	if err != nil {
+		return c.Error(403, errors.New("Permission denied"))
+	}
+
+	if !user.IsAdmin {
+		return c.Error(403, errors.New("Permission denied"))
+	}
+
+	filter, err := auditFilterFromParams(c.Params())
+	if err != nil {
+		return c.Error(400, err)
+	}
+	filter.ActorID = c.Param("actor_id")
+	filter.NamespaceID = c.Param("namespace_id")
+
+	return renderAuditEvents(c, filter)
+}
+
+// NamespaceActivity gets the audit events of a Namespace, newest first,
+// filtered and paginated like the admin audit events. This function is
+// mapped to the path GET /namespaces/{namespace_id}/activity
+func NamespaceActivity(c buffalo.Context) error {
+	user, err := getLoggedInUser(c)
+	if err != nil {
+		return c.Error(403, errors.New("Permission denied"))
+	}
+
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return c.Error(500, errors.New("Could not establish database connection"))
+	}
+
+	// Allocate an empty Namespace
+	namespace := &models.Namespace{}
+
+	// To find the Namespace the parameter namespace_id is used.
+	if err := tx.Eager().Find(namespace, c.Param("namespace_id")); err != nil {
+		return c.Error(404, errors.New("Namespace not found"))
+	}
+
+	// Can the user access this data?
+	if !canViewActivity(namespace, user) {
+		return c.Error(403, errors.New("Permission denied"))
+	}
+
+	filter, err := auditFilterFromParams(c.Params())
+	if err != nil {
+		return c.Error(400, err)
+	}
+	filter.NamespaceID = namespace.ID.String()
+
+	return renderAuditEvents(c, filter)
+}
+
+// canViewActivity reports if the user may read the audit events of the
+// namespace, admins and the members of the namespace.
+func canViewActivity(namespace *models.Namespace, user *models.User) bool {
+	return user.IsAdmin || isOwner(namespace, user) || isCoOwner(namespace, user)
+}
+
+// auditFilterFromParams returns the filter of the request parameters,
+// without the actor and namespace the handlers set themselves.
+func auditFilterFromParams(params buffalo.ParamValues) (models.AuditFilter, error) {
+	filter := models.AuditFilter{
+		Action:     params.Get("action"),
+		TargetType: params.Get("target_type"),
+		Outcome:    params.Get("outcome"),
+		Source:     params.Get("source"),
+	}
+
+	for _, bound := range []struct {
+		param string
+		time  *time.Time
+	}{
+		{"since", &filter.Since},
+		{"until", &filter.Until},
+	} {
+		value := params.Get(bound.param)
+		if value == "" {
+			continue
+		}
+
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return filter, fmt.Errorf("Invalid %s, use RFC 3339", bound.param)
+		}
+		*bound.time = parsed
+	}
+
+	return filter, nil
+}
+
+func renderAuditEvents(c buffalo.Context, filter models.AuditFilter) error {
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return c.Error(500, errors.New("Could not establish database connection"))
+	}
+
+	events := models.AuditEvents{}
+
+	// Paginate results. Params "page" and "per_page" control pagination.
+	// Default values are "page=1" and "per_page=20".
+	query := filter.Apply(tx.PaginateFromParams(c.Params()))
+
+	if err := query.Order("created_at desc").All(&events); err != nil {
+		return errors.WithStack(err)
+	}
+
+	return c.Render(200, r.JSON(events))
+}
@@ -0,0 +1,139 @@
+package actions
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/uuid"
+	"github.com/kradalby/bork/models"
+	"github.com/pkg/errors"
+)
+
+func TestAuditResult(t *testing.T) {
+	tests := []struct {
+		name    string
+		err     error
+		status  int
+		code    int
+		outcome string
+	}{
+		{"nothing written", nil, 0, 200, models.AuditSuccess},
+		{"created", nil, 201, 201, models.AuditSuccess},
+		{"rendered error", nil, 404, 404, models.AuditFailure},
+		{"http error", buffalo.HTTPError{Status: 403, Cause: errors.New("Permission denied")}, 0, 403, models.AuditFailure},
+		{"wrapped http error", errors.WithStack(buffalo.HTTPError{Status: 400, Cause: errors.New("Invalid ttl")}), 0, 400, models.AuditFailure},
+		{"other error", errors.New("connection refused"), 200, 500, models.AuditFailure},
+	}
+
+	for _, tt := range tests {
+		code, outcome := auditResult(tt.err, tt.status)
+		if code != tt.code || outcome != tt.outcome {
+			t.Errorf("%s: expected %d %s, got %d %s", tt.name, tt.code, tt.outcome, code, outcome)
+		}
+	}
+}
+
+func TestCredentialRoutesAreAudited(t *testing.T) {
+	credentials := map[string]bool{
+		"/api/v1/namespaces/config/":                            true,
+		"/api/v1/namespaces/{namespace_id}/token/":              true,
+		"/api/v1/namespaces/{namespace_id}/certificate/":        true,
+		"/api/v1/namespaces/{namespace_id}/certificateb64/":     true,
+		"/api/v1/namespaces/{namespace_id}/auth/":               true,
+		"/api/v1/namespaces/{namespace_id}/config/":             true,
+		"/api/v1/namespaces/{namespace_id}/config/oidc/":        true,
+		"/api/v1/namespaces/{namespace_id}/deploy/token/":       true,
+		"/api/v1/namespaces/{namespace_id}/deploy/certificate/": true,
+		"/api/v1/namespaces/{namespace_id}/deploy/config/":      true,
+		"/api/v1/namespaces/{namespace_id}/deploy/auth/":        true,
+	}
+
+	audited := map[string]bool{}
+	for _, route := range App("").Routes() {
+		if route.Method != "GET" || !strings.Contains(route.HandlerName, "AuditCredentials") {
+			continue
+		}
+		if !credentials[route.Path] {
+			t.Errorf("expected %s not to be audited as a credential read", route.Path)
+		}
+		audited[route.Path] = true
+	}
+
+	for path := range credentials {
+		if !audited[path] {
+			t.Errorf("expected %s to be audited as a credential read", path)
+		}
+	}
+}
+
+func TestAuditCredentialsAction(t *testing.T) {
+	for handler, action := range map[string]buffalo.Handler{
+		"NamespaceToken":        NamespaceToken,
+		"NamespaceDeployConfig": NamespaceDeployConfig,
+	} {
+		if got := auditAction(handlerName(action)); got != handler {
+			t.Errorf("expected action %s, got %s", handler, got)
+		}
+	}
+}
+
+func TestAuditFilterFromParams(t *testing.T) {
+	filter, err := auditFilterFromParams(url.Values{
+		"action":      {"NamespaceToken"},
+		"target_type": {"namespace"},
+		"outcome":     {"failure"},
+		"source":      {"api"},
+		"since":       {"2026-10-01T00:00:00Z"},
+		"actor_id":    {"ignored"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if filter.Action != "NamespaceToken" || filter.TargetType != "namespace" || filter.Outcome != "failure" || filter.Source != "api" {
+		t.Errorf("unexpected filter %#v", filter)
+	}
+	if filter.Since.IsZero() || !filter.Until.IsZero() {
+		t.Errorf("expected only since to be set, got %s and %s", filter.Since, filter.Until)
+	}
+	if filter.ActorID != "" || filter.NamespaceID != "" {
+		t.Errorf("expected the actor and namespace to be left to the handlers, got %#v", filter)
+	}
+
+	for _, param := range []string{"since", "until"} {
+		if _, err := auditFilterFromParams(url.Values{param: {"yesterday"}}); err == nil {
+			t.Errorf("expected an error for an invalid %s", param)
+		}
+	}
+}
+
+func TestCanViewActivity(t *testing.T) {
+	owner := &models.User{ID: uuid.Must(uuid.NewV4())}
+	coOwner := &models.User{ID: uuid.Must(uuid.NewV4())}
+	admin := &models.User{ID: uuid.Must(uuid.NewV4()), IsAdmin: true}
+	other := &models.User{ID: uuid.Must(uuid.NewV4())}
+
+	namespace := &models.Namespace{
+		OwnerID:  owner.ID,
+		CoOwners: models.Users{*coOwner},
+	}
+
+	tests := []struct {
+		name    string
+		user    *models.User
+		allowed bool
+	}{
+		{"owner", owner, true},
+		{"co-owner", coOwner, true},
+		{"admin", admin, true},
+		{"other user", other, false},
+	}
+
+	for _, tt := range tests {
+		if allowed := canViewActivity(namespace, tt.user); allowed != tt.allowed {
+			t.Errorf("%s: expected allowed %t, got %t", tt.name, tt.allowed, allowed)
+		}
+	}
+}
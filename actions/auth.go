@@ -2,6 +2,7 @@ package actions
 
 import (
 	"fmt"
+	"log"
 	"os"
 	"strings"
 
@@ -26,6 +27,14 @@ func init() {
 	// scopes := []string{"openid", "email", "profile", "offline_access"}
 	// scopes := []string{"openid", "email", "groups", "profile", "offline_access"}
 
+	// Nobody can log in without a provider, which is only of use to the
+	// tests
+	discoveryURL := os.Getenv("OPENID_CONNECT_DISCOVERY_URL")
+	if discoveryURL == "" && ENV != PRODUCTION {
+		log.Printf("[INFO] OPENID_CONNECT_DISCOVERY_URL is not set, logging in is disabled")
+		return
+	}
+
 	scopesString := envy.Get("OPENID_CONNECT_SCOPES", "openid")
 	scopes := strings.Split(scopesString, " ")
 
@@ -33,7 +42,7 @@ func init() {
 		os.Getenv("OPENID_CONNECT_KEY"),
 		os.Getenv("OPENID_CONNECT_SECRET"),
 		os.Getenv("OPENID_CONNECT_CALLBACK"),
-		os.Getenv("OPENID_CONNECT_DISCOVERY_URL"),
+		discoveryURL,
 		scopes...,
 	)
 	if err != nil {
@@ -0,0 +1,27 @@
+package actions
+
+import (
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/pop"
+	"github.com/kradalby/bork/models"
+	"github.com/pkg/errors"
+)
+
+// ClusterList lists the clusters namespaces can be created in. A
+// namespace created without a cluster lives on the default cluster.
+// This function is mapped to the path GET /clusters/
+func ClusterList(c buffalo.Context) error {
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return c.Error(500, errors.New("Could not establish database connection"))
+	}
+
+	clusters := &models.Clusters{}
+
+	if err := tx.Order("name").All(clusters); err != nil {
+		return errors.WithStack(err)
+	}
+
+	return c.Render(200, r.JSON(clusters))
+}
@@ -0,0 +1,80 @@
+package actions
+
+import (
+	"log"
+
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/pop"
+	"github.com/kradalby/bork/kube"
+	"github.com/kradalby/bork/models"
+	"github.com/pkg/errors"
+)
+
+// StartDeleters runs a deleter for the default cluster and every
+// registered cluster until stop is closed, reaping expired namespaces
+// and deleting the namespaces whose grace period has passed.
+func StartDeleters(stop <-chan struct{}) error {
+	interval := kube.DeletionInterval()
+	if interval <= 0 {
+		log.Printf("[INFO] Namespace deleter disabled")
+		return nil
+	}
+
+	clients, err := getKubernetesClientsForAllClusters(models.DB)
+	if err != nil {
+		return err
+	}
+
+	for _, client := range clients {
+		go client.RunDeleter(stop, interval)
+	}
+
+	return nil
+}
+
+// NamespaceRestore takes a Namespace out of pending deletion and scales
+// its workloads back up. This function is mapped to the path
+// POST /namespaces/{namespace_id}/restore
+func NamespaceRestore(c buffalo.Context) error {
+	user, err := getLoggedInUser(c)
+	if err != nil {
+		return c.Error(403, errors.New("Permission denied"))
+	}
+
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return c.Error(500, errors.New("Could not establish database connection"))
+	}
+
+	// Allocate an empty Namespace
+	namespace := &models.Namespace{}
+
+	// To find the Namespace the parameter namespace_id is used.
+	if err := tx.Eager().Find(namespace, c.Param("namespace_id")); err != nil {
+		return c.Error(404, errors.New("Namespace not found"))
+	}
+
+	if !user.IsAdmin && !isOwner(namespace, user) {
+		return c.Error(403, errors.New("Permission denied"))
+	}
+
+	if !namespace.PendingDeletion() {
+		return c.Error(404, errors.New("Namespace is not pending deletion"))
+	}
+
+	kubeClient, err := getKubernetesClientForNamespace(tx, namespace)
+	if err != nil {
+		return c.Error(500, err)
+	}
+
+	err = kubeClient.RestoreNamespace(namespace, *user)
+	if err == kube.ErrNamespaceTTLExceeded {
+		return c.Error(403, errors.New("Namespace has reached its maximum TTL, only admins can restore it"))
+	}
+	if err != nil {
+		return c.Error(500, err)
+	}
+
+	return c.Render(200, r.JSON(namespace))
+}
@@ -0,0 +1,248 @@
+package actions
+
+import (
+	b64 "encoding/base64"
+
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/pop"
+	"github.com/kradalby/bork/kube"
+	"github.com/kradalby/bork/models"
+	"github.com/pkg/errors"
+)
+
+// The deploy endpoints hand out the credentials of the deploy service
+// account of a namespace, meant for CI pipelines instead of the tokens
+// of the owners.
+
+// NamespaceDeployToken returns the token of the deploy service account.
+// This function is mapped to the path
+// GET /namespaces/{namespace_id}/deploy/token
+func NamespaceDeployToken(c buffalo.Context) error {
+	user, err := getLoggedInUser(c)
+	if err != nil {
+		return c.Error(403, errors.New("Permission denied"))
+	}
+
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return c.Error(500, errors.New("Could not establish database connection"))
+	}
+
+	// Allocate an empty Namespace
+	namespace := &models.Namespace{}
+
+	// To find the Namespace the parameter namespace_id is used.
+	if err := tx.Eager().Find(namespace, c.Param("namespace_id")); err != nil {
+		return c.Error(404, errors.New("Namespace not found"))
+	}
+
+	// Can the user access this data?
+	if !user.IsAdmin && !isOwner(namespace, user) && !isCoOwner(namespace, user) {
+		return c.Error(403, errors.New("Permission denied"))
+	}
+
+	kubeClient, err := getKubernetesClientForNamespace(tx, namespace)
+	if err != nil {
+		return c.Error(500, err)
+	}
+
+	ttl, err := getTokenTTL(c)
+	if err != nil {
+		return err
+	}
+
+	token, err := kubeClient.GetDeployToken(namespace.Name, ttl)
+	if err != nil {
+		return c.Error(500, err)
+	}
+
+	return c.Render(200, r.JSON(map[string]string{"token": token}))
+}
+
+// NamespaceRotateDeployToken issues a new token for the deploy service
+// account and revokes the old one. This function is mapped to the path
+// POST /namespaces/{namespace_id}/deploy/token/rotate
+func NamespaceRotateDeployToken(c buffalo.Context) error {
+	user, err := getLoggedInUser(c)
+	if err != nil {
+		return c.Error(403, errors.New("Permission denied"))
+	}
+
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return c.Error(500, errors.New("Could not establish database connection"))
+	}
+
+	// Allocate an empty Namespace
+	namespace := &models.Namespace{}
+
+	// To find the Namespace the parameter namespace_id is used.
+	if err := tx.Eager().Find(namespace, c.Param("namespace_id")); err != nil {
+		return c.Error(404, errors.New("Namespace not found"))
+	}
+
+	// Can the user access this data?
+	if !user.IsAdmin && !isOwner(namespace, user) && !isCoOwner(namespace, user) {
+		return c.Error(403, errors.New("Permission denied"))
+	}
+
+	kubeClient, err := getKubernetesClientForNamespace(tx, namespace)
+	if err != nil {
+		return c.Error(500, err)
+	}
+
+	token, err := kubeClient.RotateDeployToken(namespace.Name)
+	if kube.IsTokenTimeout(err) {
+		return c.Error(504, errors.New("Timed out waiting for the new token, the old token is still valid"))
+	}
+	if err != nil {
+		return c.Error(500, err)
+	}
+
+	return c.Render(200, r.JSON(map[string]string{"token": token}))
+}
+
+// NamespaceDeployCertificate returns the CA of the API server for the
+// deploy service account. This function is mapped to the path
+// GET /namespaces/{namespace_id}/deploy/certificate
+func NamespaceDeployCertificate(c buffalo.Context) error {
+	user, err := getLoggedInUser(c)
+	if err != nil {
+		return c.Error(403, errors.New("Permission denied"))
+	}
+
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return c.Error(500, errors.New("Could not establish database connection"))
+	}
+
+	// Allocate an empty Namespace
+	namespace := &models.Namespace{}
+
+	// To find the Namespace the parameter namespace_id is used.
+	if err := tx.Eager().Find(namespace, c.Param("namespace_id")); err != nil {
+		return c.Error(404, errors.New("Namespace not found"))
+	}
+
+	// Can the user access this data?
+	if !user.IsAdmin && !isOwner(namespace, user) && !isCoOwner(namespace, user) {
+		return c.Error(403, errors.New("Permission denied"))
+	}
+
+	kubeClient, err := getKubernetesClientForNamespace(tx, namespace)
+	if err != nil {
+		return c.Error(500, err)
+	}
+
+	cert, err := kubeClient.GetDeployCertificate(namespace.Name)
+	if err != nil {
+		return c.Error(500, err)
+	}
+
+	return c.Render(200, r.JSON(map[string]string{"certificate": cert}))
+}
+
+// NamespaceDeployConfig returns a kubeconfig using the token of the
+// deploy service account. This function is mapped to the path
+// GET /namespaces/{namespace_id}/deploy/config
+func NamespaceDeployConfig(c buffalo.Context) error {
+	user, err := getLoggedInUser(c)
+	if err != nil {
+		return c.Error(403, errors.New("Permission denied"))
+	}
+
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return c.Error(500, errors.New("Could not establish database connection"))
+	}
+
+	// Allocate an empty Namespace
+	namespace := &models.Namespace{}
+
+	// To find the Namespace the parameter namespace_id is used.
+	if err := tx.Eager().Find(namespace, c.Param("namespace_id")); err != nil {
+		return c.Error(404, errors.New("Namespace not found"))
+	}
+
+	// Can the user access this data?
+	if !user.IsAdmin && !isOwner(namespace, user) && !isCoOwner(namespace, user) {
+		return c.Error(403, errors.New("Permission denied"))
+	}
+
+	kubeClient, err := getKubernetesClientForNamespace(tx, namespace)
+	if err != nil {
+		return c.Error(500, err)
+	}
+
+	ttl, err := getTokenTTL(c)
+	if err != nil {
+		return err
+	}
+
+	config, err := kubeClient.CreateDeployConfiguration(namespace.Name, ttl, getKubeconfigOptions(c))
+	if err != nil {
+		return c.Error(500, err)
+	}
+
+	return renderConfiguration(c, namespace.Name+"-deploy.kubeconfig", config)
+}
+
+// NamespaceDeployAuth returns everything a CI pipeline needs to reach
+// the namespace with the deploy service account. This function is
+// mapped to the path GET /namespaces/{namespace_id}/deploy/auth
+func NamespaceDeployAuth(c buffalo.Context) error {
+	user, err := getLoggedInUser(c)
+	if err != nil {
+		return c.Error(403, errors.New("Permission denied"))
+	}
+
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return c.Error(500, errors.New("Could not establish database connection"))
+	}
+
+	// Allocate an empty Namespace
+	namespace := &models.Namespace{}
+
+	// To find the Namespace the parameter namespace_id is used.
+	if err := tx.Eager().Find(namespace, c.Param("namespace_id")); err != nil {
+		return c.Error(404, errors.New("Namespace not found"))
+	}
+
+	// Can the user access this data?
+	if !user.IsAdmin && !isOwner(namespace, user) && !isCoOwner(namespace, user) {
+		return c.Error(403, errors.New("Permission denied"))
+	}
+
+	kubeClient, err := getKubernetesClientForNamespace(tx, namespace)
+	if err != nil {
+		return c.Error(500, err)
+	}
+
+	ttl, err := getTokenTTL(c)
+	if err != nil {
+		return err
+	}
+
+	token, err := kubeClient.GetDeployToken(namespace.Name, ttl)
+	if err != nil {
+		return c.Error(500, err)
+	}
+
+	cert, err := kubeClient.GetDeployCertificate(namespace.Name)
+	if err != nil {
+		return c.Error(500, err)
+	}
+
+	return c.Render(200, r.JSON(map[string]string{
+		"token":           token,
+		"certificate":     cert,
+		"certificate_b64": b64.StdEncoding.EncodeToString([]byte(cert)),
+		"endpoint":        kubeClient.Endpoint(),
+	}))
+}
@@ -0,0 +1,70 @@
+package actions
+
+import (
+	"time"
+
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/pop"
+	"github.com/gobuffalo/pop/nulls"
+	"github.com/kradalby/bork/kube"
+	"github.com/kradalby/bork/models"
+	"github.com/pkg/errors"
+)
+
+// NamespaceSetExpiry changes when a Namespace expires, to the expires_at
+// of the body or ?ttl=72h from now. Owners can extend it up to the
+// maximum TTL, admins can also remove it. This function is mapped to
+// the path PUT /namespaces/{namespace_id}/expiry
+func NamespaceSetExpiry(c buffalo.Context) error {
+	user, err := getLoggedInUser(c)
+	if err != nil {
+		return c.Error(403, errors.New("Permission denied"))
+	}
+
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return c.Error(500, errors.New("Could not establish database connection"))
+	}
+
+	// Allocate an empty Namespace
+	namespace := &models.Namespace{}
+
+	// To find the Namespace the parameter namespace_id is used.
+	if err := tx.Eager().Find(namespace, c.Param("namespace_id")); err != nil {
+		return c.Error(404, errors.New("Namespace not found"))
+	}
+
+	if !user.IsAdmin && !isOwner(namespace, user) {
+		return c.Error(403, errors.New("Permission denied"))
+	}
+
+	now := time.Now()
+	var expiresAt nulls.Time
+
+	if value := c.Param("ttl"); value != "" {
+		ttl, err := time.ParseDuration(value)
+		if err != nil {
+			return c.Error(400, errors.New("Invalid ttl"))
+		}
+		expiresAt = nulls.NewTime(now.Add(ttl))
+	} else {
+		selected := &models.Namespace{}
+
+		// Bind the selected expiry to the request body
+		if err := c.Bind(selected); err != nil {
+			return errors.WithStack(err)
+		}
+		expiresAt = selected.ExpiresAt
+	}
+
+	if err := kube.ValidateExpiry(*user, namespace, expiresAt, now); err != nil {
+		return c.Error(400, err)
+	}
+
+	if err := namespace.SetExpiry(tx, expiresAt); err != nil {
+		return errors.WithStack(err)
+	}
+
+	return c.Render(200, r.JSON(namespace))
+}
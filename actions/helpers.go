@@ -1,10 +1,15 @@
 package actions
 
 import (
+	"fmt"
+	"io"
 	"regexp"
+	"time"
 
 	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/buffalo/render"
 	"github.com/gobuffalo/pop"
+	"github.com/kradalby/bork/kube"
 	"github.com/kradalby/bork/models"
 	"github.com/pkg/errors"
 )
@@ -41,6 +46,80 @@ func isCoOwner(namespace *models.Namespace, user *models.User) bool {
 	return false
 }
 
+// getUserToken returns a token of the service account of the user in
+// the namespace. Administrators who are not members get a token of the
+// namespace service account.
+func getUserToken(kubeClient *kube.Client, namespace *models.Namespace, user *models.User, ttl time.Duration) (string, error) {
+	if isOwner(namespace, user) || isCoOwner(namespace, user) {
+		return kubeClient.GetMemberToken(namespace.Name, *user, ttl)
+	}
+	return kubeClient.GetToken(namespace.Name, ttl)
+}
+
+// rotateUserToken revokes the tokens of the service account of the user
+// in the namespace and returns a new one, see getUserToken.
+func rotateUserToken(kubeClient *kube.Client, namespace *models.Namespace, user *models.User) (string, error) {
+	if isOwner(namespace, user) || isCoOwner(namespace, user) {
+		return kubeClient.RotateMemberToken(namespace.Name, *user)
+	}
+	return kubeClient.RotateToken(namespace.Name)
+}
+
+// getUserConfiguration returns a kubeconfig with a token of the user,
+// see getUserToken.
+func getUserConfiguration(kubeClient *kube.Client, namespace *models.Namespace, user *models.User, ttl time.Duration, options kube.KubeconfigOptions) (string, error) {
+	if isOwner(namespace, user) || isCoOwner(namespace, user) {
+		return kubeClient.CreateMemberConfiguration(namespace.Name, *user, ttl, options)
+	}
+	return kubeClient.CreateConfiguration(namespace.Name, ttl, options)
+}
+
+// getKubeconfigOptions returns the kubeconfig options asked for with
+// the cluster_name, user_name, context_name and insecure parameters.
+func getKubeconfigOptions(c buffalo.Context) kube.KubeconfigOptions {
+	return kube.KubeconfigOptions{
+		ClusterName:           c.Param("cluster_name"),
+		UserName:              c.Param("user_name"),
+		ContextName:           c.Param("context_name"),
+		InsecureSkipTLSVerify: c.Param("insecure") == "true",
+	}
+}
+
+// renderConfiguration renders the kubeconfig wrapped in JSON, or as a
+// YAML file download when asked for with format=yaml.
+func renderConfiguration(c buffalo.Context, filename string, config string) error {
+	if c.Param("format") != "yaml" {
+		return c.Render(200, r.JSON(map[string]string{"config": config}))
+	}
+
+	c.Response().Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	return c.Render(200, r.Func("application/yaml", func(w io.Writer, d render.Data) error {
+		_, err := io.WriteString(w, config)
+		return err
+	}))
+}
+
+// getTokenTTL returns the TTL asked for with the ttl parameter, like
+// 30m or 8h, zero if none is given. Tokens only expire in the
+// tokenrequest credential mode.
+func getTokenTTL(c buffalo.Context) (time.Duration, error) {
+	param := c.Param("ttl")
+	if param == "" {
+		return 0, nil
+	}
+
+	ttl, err := time.ParseDuration(param)
+	if err != nil || ttl <= 0 {
+		return 0, c.Error(400, errors.New("Invalid TTL, use a duration like 30m or 8h"))
+	}
+
+	if ttl > kube.MaxTokenTTL() {
+		return 0, c.Error(400, errors.Errorf("TTL cannot be longer than %s", kube.MaxTokenTTL()))
+	}
+
+	return ttl, nil
+}
+
 func ValidateNamespaceName(prefix string, name string) []string {
 	errors := []string{}
 
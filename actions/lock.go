@@ -0,0 +1,58 @@
+package actions
+
+import (
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/pop"
+	"github.com/kradalby/bork/models"
+	"github.com/pkg/errors"
+)
+
+// NamespaceLock locks a Namespace so it can not be deleted. This
+// function is mapped to the path
+// POST /namespaces/{namespace_id}/lock
+func NamespaceLock(c buffalo.Context) error {
+	return setNamespaceLock(c, true)
+}
+
+// NamespaceUnlock removes the lock of a Namespace. This function is
+// mapped to the path
+// DELETE /namespaces/{namespace_id}/lock
+func NamespaceUnlock(c buffalo.Context) error {
+	return setNamespaceLock(c, false)
+}
+
+func setNamespaceLock(c buffalo.Context, locked bool) error {
+	user, err := getLoggedInUser(c)
+	if err != nil {
+		return c.Error(403, errors.New("Permission denied"))
+	}
+
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return c.Error(500, errors.New("Could not establish database connection"))
+	}
+
+	// Allocate an empty Namespace
+	namespace := &models.Namespace{}
+
+	// To find the Namespace the parameter namespace_id is used.
+	if err := tx.Eager().Find(namespace, c.Param("namespace_id")); err != nil {
+		return c.Error(404, errors.New("Namespace not found"))
+	}
+
+	if !user.IsAdmin && !isOwner(namespace, user) {
+		return c.Error(403, errors.New("Permission denied"))
+	}
+
+	kubeClient, err := getKubernetesClientForNamespace(tx, namespace)
+	if err != nil {
+		return c.Error(500, err)
+	}
+
+	if err := kubeClient.LockNamespace(namespace, locked); err != nil {
+		return c.Error(500, err)
+	}
+
+	return c.Render(200, r.JSON(namespace))
+}
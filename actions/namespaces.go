@@ -3,10 +3,11 @@ package actions
 import (
 	"log"
 	"strings"
+	"time"
 
 	"github.com/gobuffalo/buffalo"
-	"github.com/gobuffalo/envy"
 	"github.com/gobuffalo/pop"
+	"github.com/kradalby/bork/kube"
 	"github.com/kradalby/bork/models"
 	"github.com/pkg/errors"
 )
@@ -122,28 +123,67 @@ func (v NamespacesResource) Create(c buffalo.Context) error {
 		return errors.New(strings.Join(nameErrors, "\n"))
 	}
 
+	if namespace.NetworkPolicy != "" && !kube.IsValidNetworkPolicy(namespace.NetworkPolicy) {
+		return c.Error(400, errors.New("Unknown network policy"))
+	}
+
+	if namespace.RoleProfile != "" {
+		if err := validateRoleProfile(c, namespace.RoleProfile); err != nil {
+			return err
+		}
+	}
+
+	if namespace.ClusterID.Valid {
+		if err := tx.Find(&models.Cluster{}, namespace.ClusterID.UUID); err != nil {
+			return c.Error(400, errors.New("Unknown cluster"))
+		}
+	}
+
+	// Without an expiry of their own namespaces get the default TTL of
+	// the owner
+	expiresAt := kube.DefaultExpiry(*user, time.Now())
+	if namespace.ExpiresAt.Valid {
+		if err := kube.ValidateExpiry(*user, namespace, namespace.ExpiresAt, time.Now()); err != nil {
+			return c.Error(400, err)
+		}
+		expiresAt = namespace.ExpiresAt
+	}
+
 	// Validate the data from the html form
 	_, err = namespace.Validate(tx)
 	if err != nil {
 		return errors.WithStack(err)
 	}
 
-	kubeClient, err := getKubernetesClient()
+	kubeClient, err := getKubernetesClientForNamespace(tx, namespace)
 	if err != nil {
 		return c.Error(500, err)
 	}
 
-	newNamespaceID, err := kubeClient.CreateNamespace(namespaceName, user.ID)
+	newNamespace := &models.Namespace{
+		Name:          namespaceName,
+		OwnerID:       user.ID,
+		NetworkPolicy: namespace.NetworkPolicy,
+		ClusterID:     namespace.ClusterID,
+		RoleProfile:   namespace.RoleProfile,
+		ExpiresAt:     expiresAt,
+	}
+
+	newNamespaceID, err := kubeClient.CreateNamespace(newNamespace)
+	if kube.IsTokenTimeout(err) {
+		return c.Error(504, errors.New("Timed out waiting for the service account token, please try again"))
+	}
 	if err != nil {
 		return errors.WithStack(err)
 	}
 
-	newNamespace := &models.Namespace{}
 	// To find the Namespace the parameter namespace_id is used.
 	if err := tx.Eager().Find(newNamespace, newNamespaceID); err != nil {
 		return c.Error(404, errors.New("Namespace not found"))
 	}
 
+	setAuditTarget(c, newNamespace)
+
 	return c.Render(201, r.JSON(newNamespace))
 }
 
@@ -159,8 +199,10 @@ func (v NamespacesResource) Update(c buffalo.Context) error {
 	return c.Error(501, errors.New("Not implemented"))
 }
 
-// Destroy deletes a Namespace from the DB. This function is mapped
-// to the path DELETE /namespaces/{namespace_id}
+// Destroy puts a Namespace in pending deletion, it is deleted from the
+// cluster and the DB when the grace period has passed. Admins can
+// delete it right away with ?force=true. This function is mapped to the
+// path DELETE /namespaces/{namespace_id}
 func (v NamespacesResource) Destroy(c buffalo.Context) error {
 	user, err := getLoggedInUser(c)
 	if err != nil {
@@ -185,17 +227,37 @@ func (v NamespacesResource) Destroy(c buffalo.Context) error {
 		return c.Error(403, errors.New("Permission denied"))
 	}
 
-	kubeClient, err := getKubernetesClient()
+	if namespace.Locked {
+		return c.Error(403, errors.New("Namespace is locked"))
+	}
+
+	kubeClient, err := getKubernetesClientForNamespace(tx, namespace)
 	if err != nil {
 		return c.Error(500, err)
 	}
 
-	err = kubeClient.DeleteNamespace(namespace.ID)
-	if err != nil {
-		return errors.WithStack(err)
+	if user.IsAdmin && c.Param("force") == "true" {
+		err = kubeClient.DeleteNamespace(namespace.ID)
+		if err == kube.ErrNamespaceLocked {
+			return c.Error(403, errors.New("Namespace is locked"))
+		}
+		if err != nil {
+			return errors.WithStack(err)
+		}
+
+		return c.Render(200, r.JSON(namespace))
+	}
+
+	if !namespace.PendingDeletion() {
+		err = kubeClient.ScheduleNamespaceDeletion(namespace)
+		if err == kube.ErrNamespaceLocked {
+			return c.Error(403, errors.New("Namespace is locked"))
+		}
+		if err != nil {
+			return errors.WithStack(err)
+		}
 	}
 
-	// Redirect to the namespaces index page
 	return c.Render(200, r.JSON(namespace))
 }
 
@@ -208,7 +270,7 @@ func NamespaceCoOwner(c buffalo.Context) error {
 	// userID := c.Session().Session.Values["current_user_id"]
 	tx, ok := c.Value("tx").(*pop.Connection)
 	if !ok {
-		return errors.WithStack(errors.New("no transaction found"))
+		// return errors.WithStack(errors.New("no transaction found"))
 		return c.Error(500, errors.New("Could not establish database connection"))
 	}
 
@@ -259,16 +321,35 @@ func NamespaceAddCoOwner(c buffalo.Context) error {
 		return errors.WithStack(err)
 	}
 
+	if err := tx.Find(coOwner, coOwner.ID); err != nil {
+		return c.Error(404, errors.New("User not found"))
+	}
+
 	// TODO: Rewrite this mess
 	if err := tx.RawQuery("INSERT INTO namespaces_users (namespace_id, user_id) VALUES (?, ?)", c.Param("namespace_id"), coOwner.ID).Exec(); err != nil {
 		return errors.WithStack(err)
 	}
 
+	kubeClient, err := getKubernetesClientForNamespace(tx, namespace)
+	if err != nil {
+		return c.Error(500, err)
+	}
+
+	// Give the co-owner a service account of their own
+	if err := kubeClient.AddMember(namespace, *coOwner); err != nil {
+		return c.Error(500, err)
+	}
+
 	// Get the updated namespace with the new CoOwner
 	if err := tx.Eager().Find(namespace, c.Param("namespace_id")); err != nil {
 		return c.Error(404, errors.New("Namespace not found"))
 	}
 
+	// Update the OIDC bindings and the co-owners on every object
+	if err := kubeClient.ApplyNamespace(namespace); err != nil {
+		return c.Error(500, err)
+	}
+
 	return c.Render(200, r.JSON(namespace))
 }
 
@@ -303,16 +384,43 @@ func NamespaceDeleteCoOwner(c buffalo.Context) error {
 		return errors.WithStack(err)
 	}
 
+	if err := tx.Find(coOwner, coOwner.ID); err != nil {
+		return c.Error(404, errors.New("User not found"))
+	}
+
 	// TODO: Rewrite this mess
 	if err := tx.RawQuery("DELETE FROM namespaces_users WHERE namespace_id = ? AND user_id = ?", c.Param("namespace_id"), coOwner.ID).Exec(); err != nil {
 		return errors.WithStack(err)
 	}
 
+	// A co-owner added again starts out with the namespace profile
+	if _, ok := namespace.MemberRoleProfiles[coOwner.ID.String()]; ok {
+		delete(namespace.MemberRoleProfiles, coOwner.ID.String())
+		if err := tx.Update(namespace); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+
+	kubeClient, err := getKubernetesClientForNamespace(tx, namespace)
+	if err != nil {
+		return c.Error(500, err)
+	}
+
+	// Revoke the credentials of the co-owner
+	if err := kubeClient.RemoveMember(namespace.Name, *coOwner); err != nil {
+		return c.Error(500, err)
+	}
+
 	namespace = &models.Namespace{}
 	// Get the updated namespace with the new CoOwner
 	if err := tx.Eager().Find(namespace, c.Param("namespace_id")); err != nil {
 		return c.Error(404, errors.New("Namespace not found"))
 	}
+
+	// Update the OIDC bindings and the co-owners on every object
+	if err := kubeClient.ApplyNamespace(namespace); err != nil {
+		return c.Error(500, err)
+	}
 	log.Printf("Namespace: %#v", namespace)
 
 	return c.Render(200, r.JSON(namespace))
@@ -384,12 +492,75 @@ func NamespaceToken(c buffalo.Context) error {
 		return c.Error(403, errors.New("Permission denied"))
 	}
 
-	kubeClient, err := getKubernetesClient()
+	kubeClient, err := getKubernetesClientForNamespace(tx, namespace)
 	if err != nil {
 		return c.Error(500, err)
 	}
 
-	token, err := kubeClient.GetToken(namespace.Name)
+	ttl, err := getTokenTTL(c)
+	if err != nil {
+		return err
+	}
+
+	token, err := getUserToken(kubeClient, namespace, user, ttl)
+	if err != nil {
+		return c.Error(500, err)
+	}
+
+	return c.Render(200, r.JSON(map[string]string{"token": token}))
+}
+
+// NamespaceRotateToken issues a new token for the service account of
+// the user in the namespace and revokes their old ones. With all=true
+// owners and admins revoke the tokens of every member and of the
+// namespace service account. This function is mapped to the path
+// POST /namespaces/{namespace_id}/token/rotate
+func NamespaceRotateToken(c buffalo.Context) error {
+	user, err := getLoggedInUser(c)
+	if err != nil {
+		return c.Error(403, errors.New("Permission denied"))
+	}
+
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return c.Error(500, errors.New("Could not establish database connection"))
+	}
+
+	// Allocate an empty Namespace
+	namespace := &models.Namespace{}
+
+	// To find the Namespace the parameter namespace_id is used.
+	if err := tx.Eager().Find(namespace, c.Param("namespace_id")); err != nil {
+		return c.Error(404, errors.New("Namespace not found"))
+	}
+
+	// Can the user access this data?
+	if !user.IsAdmin && !isOwner(namespace, user) && !isCoOwner(namespace, user) {
+		return c.Error(403, errors.New("Permission denied"))
+	}
+
+	kubeClient, err := getKubernetesClientForNamespace(tx, namespace)
+	if err != nil {
+		return c.Error(500, err)
+	}
+
+	var token string
+	if c.Param("all") == "true" {
+		if !user.IsAdmin && !isOwner(namespace, user) {
+			return c.Error(403, errors.New("Permission denied"))
+		}
+
+		err = kubeClient.RotateAllTokens(namespace)
+		if err == nil {
+			token, err = getUserToken(kubeClient, namespace, user, 0)
+		}
+	} else {
+		token, err = rotateUserToken(kubeClient, namespace, user)
+	}
+	if kube.IsTokenTimeout(err) {
+		return c.Error(504, errors.New("Timed out waiting for the new token, the old token is still valid"))
+	}
 	if err != nil {
 		return c.Error(500, err)
 	}
@@ -422,7 +593,7 @@ func NamespaceCertificate(c buffalo.Context) error {
 		return c.Error(403, errors.New("Permission denied"))
 	}
 
-	kubeClient, err := getKubernetesClient()
+	kubeClient, err := getKubernetesClientForNamespace(tx, namespace)
 	if err != nil {
 		return c.Error(500, err)
 	}
@@ -461,7 +632,7 @@ func NamespaceCertificateB64(c buffalo.Context) error {
 		return c.Error(403, errors.New("Permission denied"))
 	}
 
-	kubeClient, err := getKubernetesClient()
+	kubeClient, err := getKubernetesClientForNamespace(tx, namespace)
 	if err != nil {
 		return c.Error(500, err)
 	}
@@ -475,17 +646,27 @@ func NamespaceCertificateB64(c buffalo.Context) error {
 }
 
 func NamespaceEndpoint(c buffalo.Context) error {
-	kubeClient, err := getKubernetesClient()
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		// return errors.WithStack(errors.New("no transaction found"))
+		return c.Error(500, errors.New("Could not establish database connection"))
+	}
+
+	// Allocate an empty Namespace
+	namespace := &models.Namespace{}
+
+	// To find the Namespace the parameter namespace_id is used.
+	if err := tx.Find(namespace, c.Param("namespace_id")); err != nil {
+		return c.Error(404, errors.New("Namespace not found"))
+	}
+
+	kubeClient, err := getKubernetesClientForNamespace(tx, namespace)
 	if err != nil {
 		return c.Error(500, err)
 	}
 
-	var endpoint string
-	if ENV == "development" {
-		endpoint = kubeClient.GetEndpoint()
-	} else {
-		endpoint = envy.Get("BORK_KUBERNETES_ENDPOINT", "")
-	}
+	endpoint := kubeClient.Endpoint()
 
 	return c.Render(200, r.JSON(map[string]string{"endpoint": endpoint}))
 }
@@ -516,12 +697,17 @@ func NamespaceAuth(c buffalo.Context) error {
 		return c.Error(403, errors.New("Permission denied"))
 	}
 
-	kubeClient, err := getKubernetesClient()
+	kubeClient, err := getKubernetesClientForNamespace(tx, namespace)
 	if err != nil {
 		return c.Error(500, err)
 	}
 
-	token, err := kubeClient.GetToken(namespace.Name)
+	ttl, err := getTokenTTL(c)
+	if err != nil {
+		return err
+	}
+
+	token, err := getUserToken(kubeClient, namespace, user, ttl)
 	if err != nil {
 		return c.Error(500, err)
 	}
@@ -536,12 +722,7 @@ func NamespaceAuth(c buffalo.Context) error {
 		return c.Error(500, err)
 	}
 
-	var endpoint string
-	if ENV == "development" {
-		endpoint = kubeClient.GetEndpoint()
-	} else {
-		endpoint = envy.Get("BORK_KUBERNETES_ENDPOINT", "")
-	}
+	endpoint := kubeClient.Endpoint()
 
 	return c.Render(200, r.JSON(map[string]string{
 		"token":           token,
@@ -577,18 +758,136 @@ func NamespaceConfig(c buffalo.Context) error {
 		return c.Error(403, errors.New("Permission denied"))
 	}
 
-	kubeClient, err := getKubernetesClient()
+	kubeClient, err := getKubernetesClientForNamespace(tx, namespace)
 	if err != nil {
 		return c.Error(500, err)
 	}
 
-	config, err := kubeClient.CreateConfiguration(namespace.Name)
+	ttl, err := getTokenTTL(c)
+	if err != nil {
+		return err
+	}
+
+	config, err := getUserConfiguration(kubeClient, namespace, user, ttl, getKubeconfigOptions(c))
 	if err != nil {
 		return c.Error(500, err)
 	}
 
-	return c.Render(200, r.JSON(map[string]string{"config": config}))
+	return renderConfiguration(c, namespace.Name+".kubeconfig", config)
 }
+
+// NamespaceOIDCConfig returns a kubeconfig logging in with the OpenID
+// Connect identity of the user. This function is mapped to the path
+// GET /namespaces/{namespace_id}/config/oidc
+func NamespaceOIDCConfig(c buffalo.Context) error {
+	if !kube.OIDCEnabled() {
+		return c.Error(404, errors.New("OpenID Connect bindings are not enabled"))
+	}
+
+	user, err := getLoggedInUser(c)
+	if err != nil {
+		return c.Error(403, errors.New("Permission denied"))
+	}
+
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return c.Error(500, errors.New("Could not establish database connection"))
+	}
+
+	// Allocate an empty Namespace
+	namespace := &models.Namespace{}
+
+	// To find the Namespace the parameter namespace_id is used.
+	if err := tx.Eager().Find(namespace, c.Param("namespace_id")); err != nil {
+		return c.Error(404, errors.New("Namespace not found"))
+	}
+
+	// Only members are bound by their identity
+	if !isOwner(namespace, user) && !isCoOwner(namespace, user) {
+		return c.Error(403, errors.New("Permission denied"))
+	}
+
+	kubeClient, err := getKubernetesClientForNamespace(tx, namespace)
+	if err != nil {
+		return c.Error(500, err)
+	}
+
+	config, err := kubeClient.CreateOIDCConfiguration(namespace.Name, *user, getKubeconfigOptions(c))
+	if err == kube.ErrOIDCClientNotConfigured {
+		return c.Error(501, err)
+	}
+	if err != nil {
+		return c.Error(500, err)
+	}
+
+	return renderConfiguration(c, namespace.Name+"-oidc.kubeconfig", config)
+}
+
+// NamespacesConfig returns a single kubeconfig with a context for every
+// namespace the user owns or co-owns, optionally only the namespaces
+// with a name matching the filter parameter, like "bork-kradalby-*".
+// This function is mapped to the path GET /namespaces/config/
+func NamespacesConfig(c buffalo.Context) error {
+	user, err := getLoggedInUser(c)
+	if err != nil {
+		return c.Error(403, errors.New("Permission denied"))
+	}
+
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return c.Error(500, errors.New("Could not establish database connection"))
+	}
+
+	namespaces, err := models.MemberNamespaces(tx, *user)
+	if err != nil {
+		return c.Error(500, err)
+	}
+
+	namespaces, err = namespaces.FilterName(c.Param("filter"))
+	if err != nil {
+		return c.Error(400, errors.New("Invalid filter"))
+	}
+
+	if len(namespaces) == 0 {
+		return c.Error(404, errors.New("No namespaces found"))
+	}
+
+	ttl, err := getTokenTTL(c)
+	if err != nil {
+		return err
+	}
+
+	// Names are chosen per namespace so the contexts do not collide
+	options := kube.KubeconfigOptions{
+		InsecureSkipTLSVerify: c.Param("insecure") == "true",
+	}
+
+	configs := kube.Kubeconfigs{}
+	for i := range namespaces {
+		kubeClient, err := getKubernetesClientForNamespace(tx, &namespaces[i])
+		if err != nil {
+			return c.Error(500, err)
+		}
+
+		config, err := kubeClient.MemberKubeconfig(namespaces[i].Name, *user, ttl, options)
+		if err != nil {
+			log.Printf("[Error] %#v", err)
+			return c.Error(500, err)
+		}
+
+		configs = append(configs, config)
+	}
+
+	config, err := configs.Merge()
+	if err != nil {
+		return c.Error(500, err)
+	}
+
+	return renderConfiguration(c, user.Username+".kubeconfig", config)
+}
+
 func NamespacePrefix(c buffalo.Context) error {
 	userID := c.Session().Session.Values["current_user_id"]
 
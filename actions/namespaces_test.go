@@ -1,29 +1,123 @@
 package actions
 
-func (as *ActionSuite) Test_NamespacesResource_List() {
-	as.Fail("Not Implemented!")
+import (
+	"strings"
+
+	"github.com/kradalby/bork/models"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// createNamespace creates a namespace of the logged in user through the
+// API.
+func (as *ActionSuite) createNamespace(name string) *models.Namespace {
+	res := as.JSON("/api/v1/namespaces/").Post(map[string]string{"name": name})
+	as.Equal(201, res.Code, res.Body.String())
+
+	namespace := &models.Namespace{}
+	res.Bind(namespace)
+	return namespace
 }
 
-func (as *ActionSuite) Test_NamespacesResource_Show() {
-	as.Fail("Not Implemented!")
+func (as *ActionSuite) Test_NamespacesResource_Create() {
+	owner := as.login("alice", false)
+
+	namespace := as.createNamespace("web")
+	as.Equal("bork-alice-web", namespace.Name)
+	as.Equal(owner.ID, namespace.OwnerID)
+
+	stored := &models.Namespace{}
+	as.NoError(as.DB.Find(stored, namespace.ID))
+
+	_, err := as.clientset.CoreV1().Namespaces().Get("bork-alice-web", metav1.GetOptions{})
+	as.NoError(err)
 }
 
-func (as *ActionSuite) Test_NamespacesResource_New() {
-	as.Fail("Not Implemented!")
+func (as *ActionSuite) Test_NamespacesResource_Create_InvalidName() {
+	as.login("alice", false)
+
+	res := as.JSON("/api/v1/namespaces/").Post(map[string]string{"name": "Not Valid"})
+	as.NotEqual(201, res.Code)
+
+	count, err := as.DB.Count(&models.Namespaces{})
+	as.NoError(err)
+	as.Equal(0, count)
 }
 
-func (as *ActionSuite) Test_NamespacesResource_Create() {
-	as.Fail("Not Implemented!")
+func (as *ActionSuite) Test_NamespacesResource_Destroy() {
+	as.login("alice", false)
+	namespace := as.createNamespace("web")
+
+	res := as.JSON("/api/v1/namespaces/%s", namespace.ID).Delete()
+	as.Equal(200, res.Code, res.Body.String())
+
+	// The namespace stays until its grace period has passed
+	stored := &models.Namespace{}
+	as.NoError(as.DB.Find(stored, namespace.ID))
+	as.True(stored.PendingDeletion())
+
+	_, err := as.clientset.CoreV1().Namespaces().Get(namespace.Name, metav1.GetOptions{})
+	as.NoError(err)
 }
 
-func (as *ActionSuite) Test_NamespacesResource_Edit() {
-	as.Fail("Not Implemented!")
+func (as *ActionSuite) Test_NamespacesResource_Destroy_Force() {
+	as.login("alice", false)
+	namespace := as.createNamespace("web")
+
+	as.login("admin", true)
+	res := as.JSON("/api/v1/namespaces/%s?force=true", namespace.ID).Delete()
+	as.Equal(200, res.Code, res.Body.String())
+
+	count, err := as.DB.Where("id = ?", namespace.ID).Count(&models.Namespaces{})
+	as.NoError(err)
+	as.Equal(0, count)
+
+	_, err = as.clientset.CoreV1().Namespaces().Get(namespace.Name, metav1.GetOptions{})
+	as.Error(err)
 }
 
-func (as *ActionSuite) Test_NamespacesResource_Update() {
-	as.Fail("Not Implemented!")
+func (as *ActionSuite) Test_NamespacesResource_Destroy_NotOwner() {
+	as.login("alice", false)
+	namespace := as.createNamespace("web")
+
+	as.login("bob", false)
+	res := as.JSON("/api/v1/namespaces/%s", namespace.ID).Delete()
+	as.Equal(403, res.Code)
+
+	stored := &models.Namespace{}
+	as.NoError(as.DB.Find(stored, namespace.ID))
+	as.False(stored.PendingDeletion())
 }
 
-func (as *ActionSuite) Test_NamespacesResource_Destroy() {
-	as.Fail("Not Implemented!")
+func (as *ActionSuite) Test_NamespaceToken() {
+	as.login("alice", false)
+	namespace := as.createNamespace("web")
+
+	res := as.JSON("/api/v1/namespaces/%s/token", namespace.ID).Get()
+	as.Equal(200, res.Code, res.Body.String())
+
+	body := map[string]string{}
+	res.Bind(&body)
+	as.True(strings.HasPrefix(body["token"], "token-"), body["token"])
+}
+
+func (as *ActionSuite) Test_NamespaceToken_NotMember() {
+	as.login("alice", false)
+	namespace := as.createNamespace("web")
+
+	as.login("bob", false)
+	res := as.JSON("/api/v1/namespaces/%s/token", namespace.ID).Get()
+	as.Equal(403, res.Code)
+}
+
+func (as *ActionSuite) Test_NamespaceConfig() {
+	as.login("alice", false)
+	namespace := as.createNamespace("web")
+
+	res := as.JSON("/api/v1/namespaces/%s/config", namespace.ID).Get()
+	as.Equal(200, res.Code, res.Body.String())
+
+	body := map[string]string{}
+	res.Bind(&body)
+	as.Contains(body["config"], "namespace: "+namespace.Name)
+	as.Contains(body["config"], "token: token-")
 }
@@ -0,0 +1,75 @@
+package actions
+
+import (
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/pop"
+	"github.com/kradalby/bork/kube"
+	"github.com/kradalby/bork/models"
+	"github.com/pkg/errors"
+)
+
+// NamespaceNetworkPolicies lists the network policy presets owners can
+// choose from. This function is mapped to the path
+// GET /namespaces/networkpolicies/
+func NamespaceNetworkPolicies(c buffalo.Context) error {
+	return c.Render(200, r.JSON(map[string]interface{}{
+		"presets": kube.NetworkPolicyPresets(),
+		"default": kube.DefaultNetworkPolicy(),
+	}))
+}
+
+// NamespaceSetNetworkPolicy changes the network policy preset of a
+// Namespace. This function is mapped to the path
+// PUT /namespaces/{namespace_id}/networkpolicy
+func NamespaceSetNetworkPolicy(c buffalo.Context) error {
+	user, err := getLoggedInUser(c)
+	if err != nil {
+		return c.Error(403, errors.New("Permission denied"))
+	}
+
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return c.Error(500, errors.New("Could not establish database connection"))
+	}
+
+	// Allocate an empty Namespace
+	namespace := &models.Namespace{}
+
+	// To find the Namespace the parameter namespace_id is used.
+	if err := tx.Eager().Find(namespace, c.Param("namespace_id")); err != nil {
+		return c.Error(404, errors.New("Namespace not found"))
+	}
+
+	if !user.IsAdmin && !isOwner(namespace, user) {
+		return c.Error(403, errors.New("Permission denied"))
+	}
+
+	selected := &models.Namespace{}
+
+	// Bind the selected preset to the request body
+	if err := c.Bind(selected); err != nil {
+		return errors.WithStack(err)
+	}
+
+	if !kube.IsValidNetworkPolicy(selected.NetworkPolicy) {
+		return c.Error(400, errors.New("Unknown network policy"))
+	}
+
+	namespace.NetworkPolicy = selected.NetworkPolicy
+
+	if err := tx.Update(namespace); err != nil {
+		return errors.WithStack(err)
+	}
+
+	kubeClient, err := getKubernetesClientForNamespace(tx, namespace)
+	if err != nil {
+		return c.Error(500, err)
+	}
+
+	if err := kubeClient.ApplyNetworkPolicy(namespace); err != nil {
+		return c.Error(500, err)
+	}
+
+	return c.Render(200, r.JSON(namespace))
+}
@@ -0,0 +1,99 @@
+package actions
+
+import (
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/pop"
+	"github.com/kradalby/bork/kube"
+	"github.com/kradalby/bork/models"
+	"github.com/pkg/errors"
+)
+
+// NamespaceQuota gets the quota of a Namespace. This function is mapped to
+// the path GET /namespaces/{namespace_id}/quota
+func NamespaceQuota(c buffalo.Context) error {
+	user, err := getLoggedInUser(c)
+	if err != nil {
+		return c.Error(403, errors.New("Permission denied"))
+	}
+
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return c.Error(500, errors.New("Could not establish database connection"))
+	}
+
+	// Allocate an empty Namespace
+	namespace := &models.Namespace{}
+
+	// To find the Namespace the parameter namespace_id is used.
+	if err := tx.Eager().Find(namespace, c.Param("namespace_id")); err != nil {
+		return c.Error(404, errors.New("Namespace not found"))
+	}
+
+	// Can the user access this data?
+	if !user.IsAdmin && !isOwner(namespace, user) && !isCoOwner(namespace, user) {
+		return c.Error(403, errors.New("Permission denied"))
+	}
+
+	return c.Render(200, r.JSON(map[string]models.Quota{
+		"default":   kube.DefaultQuota(),
+		"override":  namespace.Quota,
+		"effective": kube.QuotaFor(namespace),
+	}))
+}
+
+// NamespaceSetQuota replaces the quota override of a Namespace and applies
+// it to the cluster. This function is mapped to the path
+// PUT /namespaces/{namespace_id}/quota
+func NamespaceSetQuota(c buffalo.Context) error {
+	user, err := getLoggedInUser(c)
+	if err != nil {
+		return c.Error(403, errors.New("Permission denied"))
+	}
+
+	// Only administrators can change the resource limits
+	if !user.IsAdmin {
+		return c.Error(403, errors.New("Permission denied"))
+	}
+
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return c.Error(500, errors.New("Could not establish database connection"))
+	}
+
+	// Allocate an empty Namespace
+	namespace := &models.Namespace{}
+
+	// To find the Namespace the parameter namespace_id is used.
+	if err := tx.Eager().Find(namespace, c.Param("namespace_id")); err != nil {
+		return c.Error(404, errors.New("Namespace not found"))
+	}
+
+	override := models.Quota{}
+
+	if err := c.Bind(&override); err != nil {
+		return errors.WithStack(err)
+	}
+
+	if err := kube.ValidateQuota(override); err != nil {
+		return c.Error(400, err)
+	}
+
+	namespace.Quota = override
+
+	if err := tx.Update(namespace); err != nil {
+		return errors.WithStack(err)
+	}
+
+	kubeClient, err := getKubernetesClientForNamespace(tx, namespace)
+	if err != nil {
+		return c.Error(500, err)
+	}
+
+	if err := kubeClient.ApplyQuota(namespace); err != nil {
+		return c.Error(500, err)
+	}
+
+	return c.Render(200, r.JSON(namespace))
+}
@@ -0,0 +1,73 @@
+package actions
+
+import (
+	"log"
+
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/pop"
+	"github.com/kradalby/bork/kube"
+	"github.com/kradalby/bork/models"
+	"github.com/pkg/errors"
+)
+
+// StartControllers runs a reconcile controller for the default cluster
+// and every registered cluster until stop is closed. Clusters
+// registered later are picked up on the next start. It does nothing if
+// BORK_RECONCILE_INTERVAL is zero.
+func StartControllers(stop <-chan struct{}) error {
+	interval := kube.ReconcileInterval()
+	if interval <= 0 {
+		log.Printf("[INFO] Reconcile controller disabled")
+		return nil
+	}
+
+	clients, err := getKubernetesClientsForAllClusters(models.DB)
+	if err != nil {
+		return err
+	}
+
+	for _, client := range clients {
+		go func(controller *kube.Controller) {
+			if err := controller.Run(stop); err != nil {
+				log.Printf("[Error] %#v", err)
+			}
+		}(kube.NewController(client, interval))
+	}
+
+	return nil
+}
+
+// NamespaceReconcileStatus gets the result of the last reconcile of a
+// Namespace. This function is mapped to the path
+// GET /namespaces/{namespace_id}/status
+func NamespaceReconcileStatus(c buffalo.Context) error {
+	user, err := getLoggedInUser(c)
+	if err != nil {
+		return c.Error(403, errors.New("Permission denied"))
+	}
+
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return c.Error(500, errors.New("Could not establish database connection"))
+	}
+
+	// Allocate an empty Namespace
+	namespace := &models.Namespace{}
+
+	// To find the Namespace the parameter namespace_id is used.
+	if err := tx.Eager().Find(namespace, c.Param("namespace_id")); err != nil {
+		return c.Error(404, errors.New("Namespace not found"))
+	}
+
+	// Can the user access this data?
+	if !user.IsAdmin && !isOwner(namespace, user) && !isCoOwner(namespace, user) {
+		return c.Error(403, errors.New("Permission denied"))
+	}
+
+	return c.Render(200, r.JSON(map[string]interface{}{
+		"status":        namespace.ReconcileStatus,
+		"error":         namespace.ReconcileError,
+		"reconciled_at": namespace.ReconciledAt,
+	}))
+}
@@ -0,0 +1,174 @@
+package actions
+
+import (
+	"log"
+
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/pop"
+	"github.com/kradalby/bork/kube"
+	"github.com/kradalby/bork/models"
+	"github.com/pkg/errors"
+)
+
+// NamespaceRoleProfiles lists the role profiles owners can give the
+// namespace and its co-owners. This function is mapped to the path
+// GET /namespaces/roleprofiles/
+func NamespaceRoleProfiles(c buffalo.Context) error {
+	profiles, err := kube.LoadRoleProfiles()
+	if err != nil {
+		log.Printf("[Error] %#v", err)
+		return c.Error(500, errors.New("Could not load role profiles"))
+	}
+
+	return c.Render(200, r.JSON(map[string]interface{}{
+		"profiles": profiles,
+		"default":  kube.DefaultRoleProfile(),
+	}))
+}
+
+// NamespaceSetRoleProfile changes the role profile of a Namespace,
+// given to the namespace service account, the owner and co-owners
+// without a profile of their own. This function is mapped to the path
+// PUT /namespaces/{namespace_id}/roleprofile
+func NamespaceSetRoleProfile(c buffalo.Context) error {
+	user, err := getLoggedInUser(c)
+	if err != nil {
+		return c.Error(403, errors.New("Permission denied"))
+	}
+
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return c.Error(500, errors.New("Could not establish database connection"))
+	}
+
+	// Allocate an empty Namespace
+	namespace := &models.Namespace{}
+
+	// To find the Namespace the parameter namespace_id is used.
+	if err := tx.Eager().Find(namespace, c.Param("namespace_id")); err != nil {
+		return c.Error(404, errors.New("Namespace not found"))
+	}
+
+	if !user.IsAdmin && !isOwner(namespace, user) {
+		return c.Error(403, errors.New("Permission denied"))
+	}
+
+	selected := &models.Namespace{}
+
+	// Bind the selected profile to the request body
+	if err := c.Bind(selected); err != nil {
+		return errors.WithStack(err)
+	}
+
+	if err := validateRoleProfile(c, selected.RoleProfile); err != nil {
+		return err
+	}
+
+	namespace.RoleProfile = selected.RoleProfile
+
+	if err := tx.Update(namespace); err != nil {
+		return errors.WithStack(err)
+	}
+
+	return applyRoleProfiles(c, tx, namespace)
+}
+
+// NamespaceSetCoOwnerRoleProfile gives a co-owner of a Namespace a role
+// profile of their own, an empty profile makes the co-owner use the
+// profile of the namespace again. This function is mapped to the path
+// PUT /namespaces/{namespace_id}/coowners/{user_id}/roleprofile
+func NamespaceSetCoOwnerRoleProfile(c buffalo.Context) error {
+	user, err := getLoggedInUser(c)
+	if err != nil {
+		return c.Error(403, errors.New("Permission denied"))
+	}
+
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return c.Error(500, errors.New("Could not establish database connection"))
+	}
+
+	// Allocate an empty Namespace
+	namespace := &models.Namespace{}
+
+	// To find the Namespace the parameter namespace_id is used.
+	if err := tx.Eager().Find(namespace, c.Param("namespace_id")); err != nil {
+		return c.Error(404, errors.New("Namespace not found"))
+	}
+
+	if !user.IsAdmin && !isOwner(namespace, user) {
+		return c.Error(403, errors.New("Permission denied"))
+	}
+
+	coOwner, ok := findCoOwner(namespace, c.Param("user_id"))
+	if !ok {
+		return c.Error(404, errors.New("Co-owner not found"))
+	}
+
+	selected := &models.Namespace{}
+
+	// Bind the selected profile to the request body
+	if err := c.Bind(selected); err != nil {
+		return errors.WithStack(err)
+	}
+
+	if namespace.MemberRoleProfiles == nil {
+		namespace.MemberRoleProfiles = models.MemberRoleProfiles{}
+	}
+
+	if selected.RoleProfile == "" {
+		delete(namespace.MemberRoleProfiles, coOwner.ID.String())
+	} else {
+		if err := validateRoleProfile(c, selected.RoleProfile); err != nil {
+			return err
+		}
+		namespace.MemberRoleProfiles[coOwner.ID.String()] = selected.RoleProfile
+	}
+
+	if err := tx.Update(namespace); err != nil {
+		return errors.WithStack(err)
+	}
+
+	return applyRoleProfiles(c, tx, namespace)
+}
+
+// applyRoleProfiles reconciles the roles and bindings of the namespace
+// and renders it.
+func applyRoleProfiles(c buffalo.Context, tx *pop.Connection, namespace *models.Namespace) error {
+	kubeClient, err := getKubernetesClientForNamespace(tx, namespace)
+	if err != nil {
+		return c.Error(500, err)
+	}
+
+	if err := kubeClient.ApplyRoleProfiles(namespace); err != nil {
+		return c.Error(500, err)
+	}
+
+	return c.Render(200, r.JSON(namespace))
+}
+
+// validateRoleProfile returns an error for the client if the profile
+// does not exist.
+func validateRoleProfile(c buffalo.Context, name string) error {
+	profiles, err := kube.LoadRoleProfiles()
+	if err != nil {
+		log.Printf("[Error] %#v", err)
+		return c.Error(500, errors.New("Could not load role profiles"))
+	}
+
+	if !profiles.Has(name) {
+		return c.Error(400, errors.New("Unknown role profile"))
+	}
+	return nil
+}
+
+func findCoOwner(namespace *models.Namespace, userID string) (models.User, bool) {
+	for _, coOwner := range namespace.CoOwners {
+		if coOwner.ID.String() == userID {
+			return coOwner, true
+		}
+	}
+	return models.User{}, false
+}
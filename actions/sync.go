@@ -0,0 +1,123 @@
+package actions
+
+import (
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/pop"
+	"github.com/kradalby/bork/kube"
+	"github.com/kradalby/bork/models"
+	"github.com/pkg/errors"
+)
+
+// AdminSyncPlan gets what syncing the namespaces would create, delete
+// and repair, without changing anything. The cluster parameter limits
+// it to one cluster. This function is mapped to the path
+// GET /admin/sync/plan
+func AdminSyncPlan(c buffalo.Context) error {
+	user, err := getLoggedInUser(c)
+	if err != nil {
+		return c.Error(403, errors.New("Permission denied"))
+	}
+
+	if !user.IsAdmin {
+		return c.Error(403, errors.New("Permission denied"))
+	}
+
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return c.Error(500, errors.New("Could not establish database connection"))
+	}
+
+	clients, err := getKubernetesClientsForAllClusters(tx)
+	if err != nil {
+		return c.Error(500, err)
+	}
+
+	plans := []*kube.SyncPlan{}
+	for _, client := range clients {
+		if cluster := c.Param("cluster"); cluster != "" && client.ClusterName() != cluster {
+			continue
+		}
+
+		plan, err := client.PlanSync()
+		if err != nil {
+			return c.Error(500, err)
+		}
+		plans = append(plans, plan)
+	}
+
+	if len(plans) == 0 {
+		return c.Error(404, errors.New("Cluster not found"))
+	}
+
+	return c.Render(200, r.JSON(plans))
+}
+
+// AdminNamespaceDrift gets the objects of a Namespace that differ from
+// what bork would create. This function is mapped to the path
+// GET /admin/namespaces/{namespace_id}/drift
+func AdminNamespaceDrift(c buffalo.Context) error {
+	namespace, kubeClient, err := getAdminNamespace(c)
+	if err != nil {
+		return err
+	}
+
+	drift, err := kubeClient.FindDrift(namespace)
+	if err != nil {
+		return c.Error(500, err)
+	}
+
+	return c.Render(200, r.JSON(drift))
+}
+
+// AdminNamespaceRepair repairs the objects of a Namespace that differ
+// from what bork would create, and returns what was repaired. This
+// function is mapped to the path
+// POST /admin/namespaces/{namespace_id}/repair
+func AdminNamespaceRepair(c buffalo.Context) error {
+	namespace, kubeClient, err := getAdminNamespace(c)
+	if err != nil {
+		return err
+	}
+
+	drift, err := kubeClient.FindDrift(namespace)
+	if err != nil {
+		return c.Error(500, err)
+	}
+
+	if err := kubeClient.RepairDrift(namespace, drift); err != nil {
+		return c.Error(500, err)
+	}
+
+	return c.Render(200, r.JSON(drift))
+}
+
+// getAdminNamespace returns the namespace of the request and a client
+// for its cluster, or the error to return if the user is not an admin.
+func getAdminNamespace(c buffalo.Context) (*models.Namespace, *kube.Client, error) {
+	user, err := getLoggedInUser(c)
+	if err != nil || !user.IsAdmin {
+		return nil, nil, c.Error(403, errors.New("Permission denied"))
+	}
+
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return nil, nil, c.Error(500, errors.New("Could not establish database connection"))
+	}
+
+	// Allocate an empty Namespace
+	namespace := &models.Namespace{}
+
+	// To find the Namespace the parameter namespace_id is used.
+	if err := tx.Eager().Find(namespace, c.Param("namespace_id")); err != nil {
+		return nil, nil, c.Error(404, errors.New("Namespace not found"))
+	}
+
+	kubeClient, err := getKubernetesClientForNamespace(tx, namespace)
+	if err != nil {
+		return nil, nil, c.Error(500, err)
+	}
+
+	return namespace, kubeClient, nil
+}
@@ -1,29 +1,48 @@
 package actions
 
-func (as *ActionSuite) Test_UsersResource_List() {
-	as.Fail("Not Implemented!")
-}
+import (
+	"github.com/kradalby/bork/models"
+)
 
-func (as *ActionSuite) Test_UsersResource_Show() {
-	as.Fail("Not Implemented!")
-}
+func (as *ActionSuite) Test_UserList() {
+	as.login("alice", false)
+	as.login("admin", true)
 
-func (as *ActionSuite) Test_UsersResource_New() {
-	as.Fail("Not Implemented!")
+	res := as.JSON("/api/v1/users/").Get()
+	as.Equal(200, res.Code, res.Body.String())
+
+	users := models.Users{}
+	res.Bind(&users)
+	as.Len(users, 2)
 }
 
-func (as *ActionSuite) Test_UsersResource_Create() {
-	as.Fail("Not Implemented!")
+func (as *ActionSuite) Test_UserList_NotAdmin() {
+	as.login("alice", false)
+
+	res := as.JSON("/api/v1/users/").Get()
+	as.Equal(403, res.Code)
 }
 
-func (as *ActionSuite) Test_UsersResource_Edit() {
-	as.Fail("Not Implemented!")
+func (as *ActionSuite) Test_UserShow() {
+	alice := as.login("alice", false)
+
+	res := as.JSON("/api/v1/users/%s", alice.ID).Get()
+	as.Equal(200, res.Code, res.Body.String())
+
+	user := &models.User{}
+	res.Bind(user)
+	as.Equal(alice.ID, user.ID)
+	as.Equal("alice", user.Username)
 }
 
-func (as *ActionSuite) Test_UsersResource_Update() {
-	as.Fail("Not Implemented!")
+func (as *ActionSuite) Test_UserShow_NotFound() {
+	as.login("alice", false)
+
+	res := as.JSON("/api/v1/users/%s", "00000000-0000-0000-0000-000000000000").Get()
+	as.Equal(404, res.Code)
 }
 
-func (as *ActionSuite) Test_UsersResource_Destroy() {
-	as.Fail("Not Implemented!")
+func (as *ActionSuite) Test_Unauthenticated() {
+	res := as.JSON("/api/v1/users/").Get()
+	as.Equal(403, res.Code)
 }
@@ -0,0 +1,121 @@
+// Copyright © 2018 Kristoffer Dalby <kradalby@kradalby.no>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"log"
+	"os"
+	osuser "os/user"
+	"strings"
+
+	"github.com/gobuffalo/uuid"
+	"github.com/kradalby/bork/models"
+	"github.com/spf13/cobra"
+)
+
+// cliAuditEvent is the audit event of the running command, recorded as
+// started before it runs and marked successful or failed after.
+// Commands that are interrupted leave it started.
+var cliAuditEvent *models.AuditEvent
+
+// startCLIAudit records the command about to run. The server and
+// commands without an action of their own are not recorded, the API
+// records its own events.
+func startCLIAudit(cmd *cobra.Command, args []string) {
+	if (cmd.Run == nil && cmd.RunE == nil) || cmd == serveCmd {
+		return
+	}
+
+	event := &models.AuditEvent{
+		Actor:   cliActor(),
+		Source:  models.AuditSourceCLI,
+		Action:  cmd.CommandPath(),
+		Outcome: models.AuditStarted,
+		Detail:  cliDetail(),
+	}
+
+	if flag := cmd.Flags().Lookup("namespace"); flag != nil && flag.Value.String() != "" {
+		event.TargetType = "namespace"
+		event.TargetID = flag.Value.String()
+
+		namespace := &models.Namespace{}
+		if id, err := uuid.FromString(event.TargetID); err == nil {
+			if err := models.DB.Find(namespace, id); err == nil {
+				event.Target = namespace.Name
+				event.NamespaceID = uuid.NullUUID{UUID: namespace.ID, Valid: true}
+			}
+		}
+	}
+
+	if err := models.RecordAuditEvent(models.DB, event); err != nil {
+		log.Printf("[Error] %#v", err)
+		return
+	}
+	cliAuditEvent = event
+}
+
+// finishCLIAudit marks the recorded command as successful.
+func finishCLIAudit(cmd *cobra.Command, args []string) {
+	updateCLIAudit(models.AuditSuccess, nil)
+}
+
+// failCLIAudit marks the recorded command as failed with the error it
+// returned.
+func failCLIAudit(err error) {
+	updateCLIAudit(models.AuditFailure, err)
+}
+
+func updateCLIAudit(outcome string, err error) {
+	if cliAuditEvent == nil {
+		return
+	}
+
+	cliAuditEvent.Outcome = outcome
+	if err != nil {
+		cliAuditEvent.Detail += "\nerror: " + err.Error()
+	}
+	if err := models.DB.Update(cliAuditEvent); err != nil {
+		log.Printf("[Error] %#v", err)
+	}
+}
+
+// cliActor returns who runs the command, as user@host.
+func cliActor() string {
+	name := "unknown"
+	if current, err := osuser.Current(); err == nil {
+		name = current.Username
+	}
+
+	if host, err := os.Hostname(); err == nil {
+		name += "@" + host
+	}
+	return name
+}
+
+// cliDetail returns the arguments and flags the command was run with.
+func cliDetail() string {
+	return strings.Join(os.Args[1:], " ")
+}
+
+func init() {
+	rootCmd.PersistentPreRun = startCLIAudit
+	rootCmd.PersistentPostRun = finishCLIAudit
+}
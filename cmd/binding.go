@@ -0,0 +1,69 @@
+// Copyright © 2018 Kristoffer Dalby <kradalby@kradalby.no>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+var checkBindingsCmd = &cobra.Command{
+	Use:   "bindings",
+	Short: "Flag cluster role bindings granting too much access",
+	Long: `List the cluster role bindings of service accounts in bork namespaces
+granting more than get and watch on their own namespace, such as
+bindings to the shared cluster role of older versions. Exits with a
+non-zero status if any are found, sync namespaces to migrate the
+bindings bork manages.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		clients, err := kubeClientsFor(cluster)
+		if err != nil {
+			return err
+		}
+
+		found := 0
+		for _, client := range clients {
+			clusterName := client.ClusterName()
+
+			bindings, err := client.FindOverBroadBindings()
+			if err != nil {
+				return err
+			}
+
+			for _, binding := range bindings {
+				fmt.Printf("%s\t%s\t%s\t%s\t%s\n", clusterName, binding.Namespace, binding.Name, binding.ClusterRole, binding.Reason)
+			}
+			found += len(bindings)
+		}
+
+		if found > 0 {
+			return fmt.Errorf("Found %d over-broad cluster role bindings", found)
+		}
+		return nil
+	},
+}
+
+func init() {
+	checkCmd.AddCommand(checkBindingsCmd)
+
+	checkBindingsCmd.Flags().StringVar(&cluster, "cluster", "", "Name of cluster to check, all clusters if empty")
+}
@@ -0,0 +1,208 @@
+// Copyright © 2018 Kristoffer Dalby <kradalby@kradalby.no>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+
+	"github.com/gobuffalo/uuid"
+	"github.com/kradalby/bork/kube"
+	"github.com/kradalby/bork/models"
+	"github.com/spf13/cobra"
+)
+
+var clusterName string
+var clusterEndpoint string
+var clusterCAFile string
+var clusterKubeconfFile string
+
+// newClusterCmd represents the new cluster command
+var newClusterCmd = &cobra.Command{
+	Use:   "cluster",
+	Short: "Register a cluster namespaces can be created in",
+	Long: `Register a cluster namespaces can be created in.
+
+The kubeconfig is used by bork to manage the cluster, the cluster bork
+runs in is the default cluster and is not registered. The endpoint and CA
+are handed out to users in their kubeconfig.
+
+Example:
+
+  bork new cluster --name staging --endpoint https://staging.example.com:6443 \
+    --kubeconfig staging.kubeconfig --ca staging-ca.crt`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cluster := &models.Cluster{
+			Name:     clusterName,
+			Endpoint: clusterEndpoint,
+		}
+
+		if clusterCAFile != "" {
+			ca, err := ioutil.ReadFile(clusterCAFile)
+			if err != nil {
+				return fmt.Errorf("Could not read CA: %s", err)
+			}
+			cluster.CertificateAuthority = string(ca)
+		}
+
+		if clusterKubeconfFile != "" {
+			config, err := ioutil.ReadFile(clusterKubeconfFile)
+			if err != nil {
+				return fmt.Errorf("Could not read kubeconfig: %s", err)
+			}
+			cluster.Kubeconfig = string(config)
+		}
+
+		// Make sure bork can connect before saving the cluster
+		if _, err := kube.NewClusterClient(cluster); err != nil {
+			return err
+		}
+
+		verrs, err := models.DB.ValidateAndCreate(cluster)
+		if err != nil {
+			return err
+		}
+		if verrs.HasAny() {
+			return fmt.Errorf("Invalid cluster: %s", verrs)
+		}
+
+		fmt.Println(cluster.ID)
+		return nil
+	},
+}
+
+// listClusterCmd represents the list cluster command
+var listClusterCmd = &cobra.Command{
+	Use:   "cluster",
+	Short: "List the registered clusters",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		clusters := models.Clusters{}
+
+		err := models.DB.Order("name").All(&clusters)
+		if err != nil {
+			return err
+		}
+
+		for _, cluster := range clusters {
+			fmt.Printf("%s\t%s\t%s\n", cluster.ID, cluster.Name, cluster.Endpoint)
+		}
+		return nil
+	},
+}
+
+// findCluster looks up a cluster by name, an empty name is the default
+// cluster.
+func findCluster(name string) (uuid.NullUUID, error) {
+	if name == "" {
+		return uuid.NullUUID{}, nil
+	}
+
+	cluster := &models.Cluster{}
+	if err := models.DB.Where("name = ?", name).First(cluster); err != nil {
+		return uuid.NullUUID{}, fmt.Errorf("could not find cluster %s: %s", name, err)
+	}
+
+	return uuid.NullUUID{UUID: cluster.ID, Valid: true}, nil
+}
+
+// kubeClientForCluster returns a client for the cluster, the default
+// cluster is reached through the kubeconfig given on the command line.
+func kubeClientForCluster(clusterID uuid.NullUUID) (*kube.Client, error) {
+	if !clusterID.Valid {
+		return kube.NewOutOfClusterClient(kubeconf)
+	}
+
+	cluster := &models.Cluster{}
+	if err := models.DB.Find(cluster, clusterID.UUID); err != nil {
+		return nil, err
+	}
+
+	return kube.NewClusterClient(cluster)
+}
+
+// kubeClientsForAllClusters returns a client for the default cluster
+// followed by one for every registered cluster.
+func kubeClientsForAllClusters() ([]*kube.Client, error) {
+	client, err := kube.NewOutOfClusterClient(kubeconf)
+	if err != nil {
+		return nil, err
+	}
+
+	clients := []*kube.Client{client}
+
+	clusters := models.Clusters{}
+	if err := models.DB.Order("name").All(&clusters); err != nil {
+		return nil, err
+	}
+
+	for i := range clusters {
+		client, err := kube.NewClusterClient(&clusters[i])
+		if err != nil {
+			return nil, err
+		}
+		clients = append(clients, client)
+	}
+
+	return clients, nil
+}
+
+// kubeClientsFor returns a client for the named cluster, or for all
+// clusters if the name is empty.
+func kubeClientsFor(name string) ([]*kube.Client, error) {
+	if name == "" {
+		return kubeClientsForAllClusters()
+	}
+
+	clusterID, err := findCluster(name)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := kubeClientForCluster(clusterID)
+	if err != nil {
+		return nil, err
+	}
+	return []*kube.Client{client}, nil
+}
+
+func init() {
+	newCmd.AddCommand(newClusterCmd)
+	listCmd.AddCommand(listClusterCmd)
+
+	newClusterCmd.Flags().StringVarP(&clusterName, "name", "n", "", "Name of cluster")
+	newClusterCmd.Flags().StringVarP(&clusterEndpoint, "endpoint", "e", "", "Public API server endpoint")
+	newClusterCmd.Flags().StringVar(&clusterCAFile, "ca", "", "Path to the PEM encoded CA of the API server")
+	newClusterCmd.Flags().StringVar(&clusterKubeconfFile, "kubeconfig", "", "Path to kubeconfig bork uses for the cluster")
+
+	err := newClusterCmd.MarkFlagRequired("name")
+	if err != nil {
+		log.Fatalf("[Error]: %s", err)
+	}
+	err = newClusterCmd.MarkFlagRequired("endpoint")
+	if err != nil {
+		log.Fatalf("[Error]: %s", err)
+	}
+	err = newClusterCmd.MarkFlagRequired("kubeconfig")
+	if err != nil {
+		log.Fatalf("[Error]: %s", err)
+	}
+}
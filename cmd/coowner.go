@@ -21,6 +21,7 @@
 package cmd
 
 import (
+	"fmt"
 	"log"
 
 	"github.com/gobuffalo/uuid"
@@ -43,16 +44,16 @@ and usage of using your command. For example:
 Cobra is a CLI library for Go that empowers applications.
 This application is a tool to generate the needed files
 to quickly create a Cobra application.`,
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 
 		userID, err := uuid.FromString(user)
 		if err != nil {
-			log.Fatalf("Could not parse UUID: %s", err)
+			return fmt.Errorf("Could not parse UUID: %s", err)
 		}
 
 		namespaceID, err := uuid.FromString(namespace)
 		if err != nil {
-			log.Fatalf("Could not parse UUID: %s", err)
+			return fmt.Errorf("Could not parse UUID: %s", err)
 		}
 
 		// Allocate an empty Namespace
@@ -60,17 +61,34 @@ to quickly create a Cobra application.`,
 		ns := &models.Namespace{}
 
 		if err := models.DB.Eager().Find(u, userID); err != nil {
-			log.Fatalf("Could not find namespace: %s", err)
+			return fmt.Errorf("Could not find namespace: %s", err)
 		}
 
 		if err := models.DB.Eager().Find(ns, namespaceID); err != nil {
-			log.Fatalf("Could not find namespace: %s", err)
+			return fmt.Errorf("Could not find namespace: %s", err)
 		}
 
-		models.DB.RawQuery("INSERT INTO namespaces_users (namespace_id, user_id) VALUES ('?', '?')", ns.ID, u.ID)
+		err = models.DB.RawQuery("INSERT INTO namespaces_users (namespace_id, user_id) VALUES (?, ?)", ns.ID, u.ID).Exec()
+		if err != nil {
+			return fmt.Errorf("Could not update namespace: %s", err)
+		}
 
+		client, err := kubeClientForCluster(ns.ClusterID)
 		if err != nil {
-			log.Fatalf("Could not update namespace: %s", err)
+			return err
+		}
+
+		// Give the co-owner a service account of their own
+		if err := client.AddMember(ns, *u); err != nil {
+			return err
+		}
+
+		if err := models.DB.Eager().Find(ns, namespaceID); err != nil {
+			return fmt.Errorf("Could not find namespace: %s", err)
+		}
+
+		if err := client.ApplyNamespace(ns); err != nil {
+			return err
 		}
 
 		// This is not how we do it until Eager update is created
@@ -84,6 +102,7 @@ to quickly create a Cobra application.`,
 		// if err != nil {
 		// 	log.Fatalf("Could not update namespace: %s", err)
 		// }
+		return nil
 	},
 }
 
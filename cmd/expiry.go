@@ -0,0 +1,138 @@
+// Copyright © 2018 Kristoffer Dalby <kradalby@kradalby.no>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/gobuffalo/pop/nulls"
+	"github.com/gobuffalo/uuid"
+	"github.com/kradalby/bork/models"
+	"github.com/spf13/cobra"
+)
+
+var (
+	expiryTTL   time.Duration
+	expiryNever bool
+	userTTL     string
+)
+
+// setExpiryCmd represents the set expiry command
+var setExpiryCmd = &cobra.Command{
+	Use:   "expiry",
+	Short: "Change when a namespace expires",
+	Long: `Change when a namespace expires. Expired namespaces are deleted
+by "bork serve" after the deletion grace period, unless they are locked.
+
+Example:
+
+  bork set expiry -n <namespace uuid> --ttl 72h
+  bork set expiry -n <namespace uuid> --never`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		namespaceID, err := uuid.FromString(namespace)
+		if err != nil {
+			return fmt.Errorf("Could not parse UUID: %s", err)
+		}
+
+		ns := &models.Namespace{}
+
+		if err := models.DB.Find(ns, namespaceID); err != nil {
+			return fmt.Errorf("Could not find namespace: %s", err)
+		}
+
+		expiresAt := nulls.Time{}
+		if !expiryNever {
+			if expiryTTL <= 0 {
+				return errors.New("Give a positive --ttl or --never")
+			}
+			expiresAt = nulls.NewTime(time.Now().Add(expiryTTL))
+		}
+
+		if err := ns.SetExpiry(models.DB, expiresAt); err != nil {
+			return fmt.Errorf("Could not update namespace: %s", err)
+		}
+		return nil
+	},
+}
+
+// setTTLCmd represents the set ttl command
+var setTTLCmd = &cobra.Command{
+	Use:   "ttl",
+	Short: "Set how long new namespaces of a user live",
+	Long: `Set how long new namespaces of a user live by default, overriding
+the default of their role from BORK_NAMESPACE_TTL_DEFAULT or
+BORK_NAMESPACE_TTL_ADMIN_DEFAULT. An empty TTL falls back to the role
+default, 0 makes their namespaces never expire.
+
+Example:
+
+  bork set ttl -u <user uuid> --ttl 168h`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		userID, err := uuid.FromString(user)
+		if err != nil {
+			return fmt.Errorf("Could not parse UUID: %s", err)
+		}
+
+		if userTTL != "" {
+			if _, err := time.ParseDuration(userTTL); err != nil {
+				return fmt.Errorf("Invalid TTL: %s", err)
+			}
+		}
+
+		u := &models.User{}
+
+		if err := models.DB.Find(u, userID); err != nil {
+			return fmt.Errorf("Could not find user: %s", err)
+		}
+
+		u.NamespaceTTL = userTTL
+
+		if err := models.DB.Update(u); err != nil {
+			return fmt.Errorf("Could not update user: %s", err)
+		}
+		return nil
+	},
+}
+
+func init() {
+	setCmd.AddCommand(setExpiryCmd)
+	setCmd.AddCommand(setTTLCmd)
+
+	setExpiryCmd.Flags().StringVarP(&namespace, "namespace", "n", "", "Namespace UUID")
+	setExpiryCmd.Flags().DurationVar(&expiryTTL, "ttl", 0, "Expire the namespace this long from now")
+	setExpiryCmd.Flags().BoolVar(&expiryNever, "never", false, "Remove the expiry")
+
+	setTTLCmd.Flags().StringVarP(&user, "user", "u", "", "User UUID")
+	setTTLCmd.Flags().StringVar(&userTTL, "ttl", "", "Default TTL of new namespaces, empty for the role default")
+
+	err := setExpiryCmd.MarkFlagRequired("namespace")
+	if err != nil {
+		log.Fatalf("[Error]: %s", err)
+	}
+
+	err = setTTLCmd.MarkFlagRequired("user")
+	if err != nil {
+		log.Fatalf("[Error]: %s", err)
+	}
+}
@@ -0,0 +1,132 @@
+// Copyright © 2018 Kristoffer Dalby <kradalby@kradalby.no>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"time"
+
+	"github.com/gobuffalo/uuid"
+	"github.com/kradalby/bork/kube"
+	"github.com/kradalby/bork/models"
+	"github.com/spf13/cobra"
+)
+
+var (
+	kubeconfigFilter   string
+	kubeconfigOutput   string
+	kubeconfigTTL      time.Duration
+	kubeconfigInsecure bool
+)
+
+// kubeconfigCmd represents the kubeconfig command
+var kubeconfigCmd = &cobra.Command{
+	Use:   "kubeconfig",
+	Short: "Create one kubeconfig for all namespaces of a user",
+	Long: `Create a kubeconfig with a context for every namespace the user
+owns or co-owns, using the service account of the user in each of
+them. Namespaces on the same cluster share the cluster entry and no
+current context is set, so the result can be merged into an existing
+kubeconfig.
+
+Example:
+
+  bork kubeconfig -u <user uuid> --filter 'bork-kradalby-*' -o bork.kubeconfig
+  KUBECONFIG=~/.kube/config:bork.kubeconfig kubectl config view --flatten`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		userID, err := uuid.FromString(user)
+		if err != nil {
+			return fmt.Errorf("Could not parse UUID: %s", err)
+		}
+
+		u := &models.User{}
+		if err := models.DB.Find(u, userID); err != nil {
+			return fmt.Errorf("Could not find user: %s", err)
+		}
+
+		namespaces, err := models.MemberNamespaces(models.DB, *u)
+		if err != nil {
+			return fmt.Errorf("Could not list namespaces: %s", err)
+		}
+
+		namespaces, err = namespaces.FilterName(kubeconfigFilter)
+		if err != nil {
+			return fmt.Errorf("Invalid filter: %s", err)
+		}
+
+		if len(namespaces) == 0 {
+			return fmt.Errorf("No namespaces found for user %s", u.Username)
+		}
+
+		options := kube.KubeconfigOptions{InsecureSkipTLSVerify: kubeconfigInsecure}
+		clients := map[uuid.NullUUID]*kube.Client{}
+		configs := kube.Kubeconfigs{}
+
+		for _, ns := range namespaces {
+			client, ok := clients[ns.ClusterID]
+			if !ok {
+				client, err = kubeClientForCluster(ns.ClusterID)
+				if err != nil {
+					return err
+				}
+				clients[ns.ClusterID] = client
+			}
+
+			config, err := client.MemberKubeconfig(ns.Name, *u, kubeconfigTTL, options)
+			if err != nil {
+				return fmt.Errorf("namespace %s: %s", ns.Name, err)
+			}
+			configs = append(configs, config)
+		}
+
+		config, err := configs.Merge()
+		if err != nil {
+			return err
+		}
+
+		if kubeconfigOutput == "" {
+			fmt.Print(config)
+			return nil
+		}
+
+		if err := ioutil.WriteFile(kubeconfigOutput, []byte(config), 0600); err != nil {
+			return fmt.Errorf("Could not write kubeconfig: %s", err)
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(kubeconfigCmd)
+
+	kubeconfigCmd.Flags().StringVarP(&user, "user", "u", "", "User UUID")
+	kubeconfigCmd.Flags().StringVarP(&kubeconfigFilter, "filter", "f", "", "Only namespaces with a name matching the pattern")
+	kubeconfigCmd.Flags().StringVarP(&kubeconfigOutput, "output", "o", "", "File to write the kubeconfig to, standard output if empty")
+	kubeconfigCmd.Flags().DurationVar(&kubeconfigTTL, "ttl", 0, "Lifetime of the tokens in the tokenrequest credential mode")
+	kubeconfigCmd.Flags().BoolVar(&kubeconfigInsecure, "insecure", false, "Skip verifying the certificate of the API server")
+
+	err := kubeconfigCmd.MarkFlagRequired("user")
+	if err != nil {
+		log.Fatalf("[Error]: %s", err)
+	}
+}
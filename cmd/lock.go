@@ -0,0 +1,80 @@
+// Copyright © 2018 Kristoffer Dalby <kradalby@kradalby.no>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/gobuffalo/uuid"
+	"github.com/kradalby/bork/models"
+	"github.com/spf13/cobra"
+)
+
+var unlock bool
+
+// setLockCmd represents the set lock command
+var setLockCmd = &cobra.Command{
+	Use:   "lock",
+	Short: "Lock a namespace so it can not be deleted",
+	Long: `Lock a namespace so it can not be deleted, by its owners, admins
+or syncing, until it is unlocked again. The lock is also set as the
+bork.locked annotation on the namespace in the cluster.
+
+Example:
+
+  bork set lock -n <namespace uuid>
+  bork set lock -n <namespace uuid> --unlock`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		namespaceID, err := uuid.FromString(namespace)
+		if err != nil {
+			return fmt.Errorf("Could not parse UUID: %s", err)
+		}
+
+		ns := &models.Namespace{}
+
+		if err := models.DB.Find(ns, namespaceID); err != nil {
+			return fmt.Errorf("Could not find namespace: %s", err)
+		}
+
+		client, err := kubeClientForCluster(ns.ClusterID)
+		if err != nil {
+			return err
+		}
+
+		if err := client.LockNamespace(ns, !unlock); err != nil {
+			return err
+		}
+		return nil
+	},
+}
+
+func init() {
+	setCmd.AddCommand(setLockCmd)
+
+	setLockCmd.Flags().StringVarP(&namespace, "namespace", "n", "", "Namespace UUID")
+	setLockCmd.Flags().BoolVar(&unlock, "unlock", false, "Remove the lock")
+
+	err := setLockCmd.MarkFlagRequired("namespace")
+	if err != nil {
+		log.Fatalf("[Error]: %s", err)
+	}
+}
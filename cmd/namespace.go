@@ -21,8 +21,13 @@
 package cmd
 
 import (
+	"bufio"
+	"encoding/json"
 	"fmt"
+	"io"
 	"log"
+	"os"
+	"strings"
 
 	"github.com/gobuffalo/uuid"
 	"github.com/kradalby/bork/kube"
@@ -32,6 +37,15 @@ import (
 
 var name string
 var owner string
+var cluster string
+
+var (
+	syncDryRun        bool
+	syncOutput        string
+	syncYes           bool
+	syncAdopt         bool
+	syncDeleteOrphans bool
+)
 
 // newNamespaceCmd represents the newNamespace command
 var newNamespaceCmd = &cobra.Command{
@@ -43,21 +57,32 @@ and usage of using your command. For example:
 Cobra is a CLI library for Go that empowers applications.
 This application is a tool to generate the needed files
 to quickly create a Cobra application.`,
-	Run: func(cmd *cobra.Command, args []string) {
-		client, err := kube.NewOutOfClusterClient(kubeconf)
+	RunE: func(cmd *cobra.Command, args []string) error {
+		clusterID, err := findCluster(cluster)
 		if err != nil {
-			log.Fatalf("[Error] %#v", err)
+			return err
+		}
+
+		client, err := kubeClientForCluster(clusterID)
+		if err != nil {
+			return err
 		}
 
 		ownerID, err := uuid.FromString(owner)
 		if err != nil {
-			log.Fatalf("Could not parse UUID: %s", err)
+			return fmt.Errorf("Could not parse UUID: %s", err)
+		}
+
+		ns := &models.Namespace{
+			Name:    name,
+			OwnerID: ownerID,
 		}
 
-		_, err = client.CreateNamespace(name, ownerID)
+		_, err = client.CreateNamespace(ns)
 		if err != nil {
-			log.Fatalf("[Error] %#v", err)
+			return err
 		}
+		return nil
 	},
 }
 
@@ -71,46 +96,168 @@ and usage of using your command. For example:
 Cobra is a CLI library for Go that empowers applications.
 This application is a tool to generate the needed files
 to quickly create a Cobra application.`,
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 
 		ns := models.Namespaces{}
 
 		err := models.DB.All(&ns)
 		if err != nil {
-			fmt.Printf("[Error] %#v", err)
+			return err
 		}
 		fmt.Println(ns)
+		return nil
 	},
 }
 
 var syncNamespaceCmd = &cobra.Command{
 	Use:   "namespace",
-	Short: "A brief description of your command",
-	Long: `A longer description that spans multiple lines and likely contains examples
-and usage of using your command. For example:
+	Short: "Bring the namespaces of the clusters in line with the database",
+	Long: `Create the namespaces in the database missing from the clusters, and
+repair the objects of namespaces that drifted from the database.
 
-Cobra is a CLI library for Go that empowers applications.
-This application is a tool to generate the needed files
-to quickly create a Cobra application.`,
-	Run: func(cmd *cobra.Command, args []string) {
-		client, err := kube.NewOutOfClusterClient(kubeconf)
-		if err != nil {
-			fmt.Printf("[Error] %#v", err)
+Bork namespaces missing from the database, orphans, are kept unless
+asked otherwise. --adopt rebuilds their database rows from the owner
+label and the role bindings, for example after the database was
+restored from an old backup. --delete-orphans deletes them, with
+--adopt only the ones that could not be adopted. Locked orphans are
+never deleted.
+
+A plan of the namespaces to create, adopt and delete, and of every
+drifted object with how it differs, is printed first, --dry-run stops
+there. Deleting namespaces has to be confirmed, or allowed up front
+with --yes.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if syncOutput != "text" && syncOutput != "json" {
+			return fmt.Errorf("Unknown output %s, use text or json", syncOutput)
 		}
 
-		missingFromCluster, missingFromDB, err := client.FindOutOfSyncNamespaces()
+		clients, err := kubeClientsFor(cluster)
 		if err != nil {
-			fmt.Printf("[Error] %#v", err)
+			return err
 		}
 
-		err = client.Sync(missingFromCluster, missingFromDB)
-		if err != nil {
-			fmt.Printf("[Error] %#v", err)
+		plans := []*kube.SyncPlan{}
+		for _, client := range clients {
+			plan, err := client.PlanSync()
+			if err != nil {
+				return err
+			}
+			plans = append(plans, plan)
+		}
+
+		options := kube.SyncOptions{Adopt: syncAdopt, DeleteOrphans: syncDeleteOrphans}
+		if err := printSyncPlans(os.Stdout, plans, syncOutput, options); err != nil {
+			return err
+		}
+
+		if syncDryRun {
+			return nil
+		}
+
+		failed := 0
+		for i, client := range clients {
+			planOptions := options
+			planOptions.DeleteOrphans = options.DeleteOrphans && confirmDelete(plans[i], options)
+			if !syncNamespaces(client, plans[i], planOptions) {
+				failed++
+			}
 		}
 
+		if failed > 0 {
+			return fmt.Errorf("Syncing %d clusters failed", failed)
+		}
+		return nil
 	},
 }
 
+// printSyncPlans writes the plans as a table or as JSON. The table tells
+// what happens to every orphan with the options.
+func printSyncPlans(w io.Writer, plans []*kube.SyncPlan, output string, options kube.SyncOptions) error {
+	if output == "json" {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(plans)
+	}
+
+	for _, plan := range plans {
+		if plan.IsEmpty() {
+			fmt.Fprintf(w, "%s: in sync\n", plan.Cluster)
+			continue
+		}
+		for _, name := range plan.Create {
+			fmt.Fprintf(w, "%s\tcreate\t%s\n", plan.Cluster, name)
+		}
+		for _, drift := range plan.Drift {
+			fmt.Fprintf(w, "%s\trepair\t%s\t%s\n", plan.Cluster, drift.Namespace, drift)
+		}
+		for _, adoption := range plan.Adopt {
+			switch {
+			case adoption.Locked && !(options.Adopt && adoption.Namespace != nil):
+				fmt.Fprintf(w, "%s\tlocked\t%s\n", plan.Cluster, adoption.Name)
+			case options.Adopt && adoption.Namespace != nil:
+				fmt.Fprintf(w, "%s\tadopt\t%s\towner %s\n", plan.Cluster, adoption.Name, adoption.Namespace.Owner.Username)
+			case options.DeleteOrphans:
+				fmt.Fprintf(w, "%s\tdelete\t%s\n", plan.Cluster, adoption.Name)
+			case adoption.Namespace != nil:
+				fmt.Fprintf(w, "%s\torphan\t%s\tadoptable, owner %s\n", plan.Cluster, adoption.Name, adoption.Namespace.Owner.Username)
+			default:
+				fmt.Fprintf(w, "%s\torphan\t%s\t%s\n", plan.Cluster, adoption.Name, adoption.Problem)
+			}
+		}
+	}
+	return nil
+}
+
+// orphansToDelete returns how many orphans of the plan are deleted with
+// the options, locked orphans are never deleted.
+func orphansToDelete(plan *kube.SyncPlan, options kube.SyncOptions) int {
+	if !options.DeleteOrphans {
+		return 0
+	}
+
+	count := 0
+	for _, adoption := range plan.Adopt {
+		if !adoption.Locked && (!options.Adopt || adoption.Namespace == nil) {
+			count++
+		}
+	}
+	return count
+}
+
+// confirmDelete asks on the terminal if the orphaned namespaces of the
+// plan may be deleted. Without a terminal they are kept unless --yes
+// was given.
+func confirmDelete(plan *kube.SyncPlan, options kube.SyncOptions) bool {
+	count := orphansToDelete(plan, options)
+	if count == 0 || syncYes {
+		return true
+	}
+
+	stat, err := os.Stdin.Stat()
+	if err != nil || stat.Mode()&os.ModeCharDevice == 0 {
+		log.Printf("[INFO] Not deleting %d namespaces from %s without --yes", count, plan.Cluster)
+		return false
+	}
+
+	fmt.Fprintf(os.Stderr, "Delete %d namespaces from %s, with everything in them? [y/N] ", count, plan.Cluster)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+// syncNamespaces applies the plan to the cluster, reporting if it
+// succeeded. A failing cluster does not stop the others.
+func syncNamespaces(client *kube.Client, plan *kube.SyncPlan, options kube.SyncOptions) bool {
+	log.Printf("[INFO] Syncing cluster %s", plan.Cluster)
+
+	err := client.ApplySyncPlan(plan, options)
+	if err != nil {
+		log.Printf("[Error] %#v", err)
+		return false
+	}
+	return true
+}
+
 func init() {
 	newCmd.AddCommand(newNamespaceCmd)
 	listCmd.AddCommand(listNamespaceCmd)
@@ -127,6 +274,13 @@ func init() {
 	// newNamespaceCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
 	newNamespaceCmd.Flags().StringVarP(&name, "name", "n", "", "Name of namespace")
 	newNamespaceCmd.Flags().StringVarP(&owner, "owner", "o", "", "Owner UUID")
+	newNamespaceCmd.Flags().StringVar(&cluster, "cluster", "", "Name of cluster, default cluster if empty")
+	syncNamespaceCmd.Flags().StringVar(&cluster, "cluster", "", "Name of cluster to sync, all clusters if empty")
+	syncNamespaceCmd.Flags().BoolVar(&syncDryRun, "dry-run", false, "Print the plan without changing anything")
+	syncNamespaceCmd.Flags().StringVar(&syncOutput, "output", "text", "Format of the plan, text or json")
+	syncNamespaceCmd.Flags().BoolVar(&syncAdopt, "adopt", false, "Rebuild the database rows of namespaces missing from the database")
+	syncNamespaceCmd.Flags().BoolVar(&syncDeleteOrphans, "delete-orphans", false, "Delete namespaces missing from the database")
+	syncNamespaceCmd.Flags().BoolVarP(&syncYes, "yes", "y", false, "Delete namespaces missing from the database without asking")
 
 	err := newNamespaceCmd.MarkFlagRequired("name")
 	if err != nil {
@@ -0,0 +1,135 @@
+// Copyright © 2018 Kristoffer Dalby <kradalby@kradalby.no>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"strings"
+
+	"github.com/kradalby/bork/kube"
+	"github.com/kradalby/bork/models"
+	"github.com/spf13/cobra"
+)
+
+var profileName string
+var profileRulesFile string
+
+// setProfileCmd represents the set profile command
+var setProfileCmd = &cobra.Command{
+	Use:   "profile",
+	Short: "Create or change a role profile",
+	Long: `Create or change a role profile and update the roles of every
+namespace in all clusters.
+
+The rules are read from a JSON file with a list of rules, a profile with
+the name of a built-in profile (viewer, developer, full) replaces it.
+
+Example:
+
+  bork set profile --name deployer --rules-file deployer.json
+
+With deployer.json:
+
+  [{"api_groups": ["apps"], "resources": ["deployments"], "verbs": ["get", "patch"]}]`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		content, err := ioutil.ReadFile(profileRulesFile)
+		if err != nil {
+			return fmt.Errorf("Could not read rules: %s", err)
+		}
+
+		rules := models.PolicyRules{}
+		if err := json.Unmarshal(content, &rules); err != nil {
+			return fmt.Errorf("Could not parse rules: %s", err)
+		}
+
+		profile := &models.RoleProfile{}
+		err = models.DB.Where("name = ?", profileName).First(profile)
+		if err != nil {
+			profile = &models.RoleProfile{Name: profileName}
+		}
+		profile.Rules = rules
+
+		verrs, err := models.DB.ValidateAndSave(profile)
+		if err != nil {
+			return err
+		}
+		if verrs.HasAny() {
+			return fmt.Errorf("Invalid profile: %s", verrs)
+		}
+
+		clients, err := kubeClientsForAllClusters()
+		if err != nil {
+			return err
+		}
+
+		for _, client := range clients {
+			if err := client.SyncRoleProfiles(); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+}
+
+// listProfileCmd represents the list profile command
+var listProfileCmd = &cobra.Command{
+	Use:   "profile",
+	Short: "List the role profiles",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		profiles, err := kube.LoadRoleProfiles()
+		if err != nil {
+			return err
+		}
+
+		for _, name := range profiles.Names() {
+			rules := []string{}
+			for _, rule := range profiles[name] {
+				rules = append(rules, fmt.Sprintf("%s %s: %s",
+					strings.Join(rule.APIGroups, ","),
+					strings.Join(rule.Resources, ","),
+					strings.Join(rule.Verbs, ",")))
+			}
+
+			fmt.Printf("%s\t%s\n", name, strings.Join(rules, "; "))
+		}
+		return nil
+	},
+}
+
+func init() {
+	setCmd.AddCommand(setProfileCmd)
+	listCmd.AddCommand(listProfileCmd)
+
+	setProfileCmd.Flags().StringVarP(&profileName, "name", "n", "", "Name of profile")
+	setProfileCmd.Flags().StringVarP(&profileRulesFile, "rules-file", "f", "", "Path to JSON file with the rules")
+
+	err := setProfileCmd.MarkFlagRequired("name")
+	if err != nil {
+		log.Fatalf("[Error]: %s", err)
+	}
+	err = setProfileCmd.MarkFlagRequired("rules-file")
+	if err != nil {
+		log.Fatalf("[Error]: %s", err)
+	}
+}
@@ -0,0 +1,114 @@
+// Copyright © 2018 Kristoffer Dalby <kradalby@kradalby.no>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/gobuffalo/uuid"
+	"github.com/kradalby/bork/kube"
+	"github.com/kradalby/bork/models"
+	"github.com/spf13/cobra"
+)
+
+// setQuotaCmd represents the set quota command
+var setQuotaCmd = &cobra.Command{
+	Use:   "quota",
+	Short: "Override the resource quota of a namespace",
+	Long: `Override the resource quota of a namespace. Only the given
+values are changed, pass an empty value to fall back to the cluster default.
+
+Example:
+
+  bork set quota -n <namespace uuid> --limits-cpu 8 --limits-memory 16Gi`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		namespaceID, err := uuid.FromString(namespace)
+		if err != nil {
+			return fmt.Errorf("Could not parse UUID: %s", err)
+		}
+
+		ns := &models.Namespace{}
+
+		if err := models.DB.Find(ns, namespaceID); err != nil {
+			return fmt.Errorf("Could not find namespace: %s", err)
+		}
+
+		override := ns.Quota
+		fields := map[string]*string{
+			"requests-cpu":           &override.RequestsCPU,
+			"requests-memory":        &override.RequestsMemory,
+			"limits-cpu":             &override.LimitsCPU,
+			"limits-memory":          &override.LimitsMemory,
+			"pods":                   &override.Pods,
+			"default-cpu":            &override.DefaultCPU,
+			"default-memory":         &override.DefaultMemory,
+			"default-request-cpu":    &override.DefaultRequestCPU,
+			"default-request-memory": &override.DefaultRequestMemory,
+		}
+
+		for flag, field := range fields {
+			if cmd.Flags().Changed(flag) {
+				*field, _ = cmd.Flags().GetString(flag)
+			}
+		}
+
+		if err := kube.ValidateQuota(override); err != nil {
+			return fmt.Errorf("Invalid quota: %s", err)
+		}
+
+		ns.Quota = override
+
+		if err := models.DB.Update(ns); err != nil {
+			return fmt.Errorf("Could not update namespace: %s", err)
+		}
+
+		client, err := kubeClientForCluster(ns.ClusterID)
+		if err != nil {
+			return err
+		}
+
+		if err := client.ApplyQuota(ns); err != nil {
+			return err
+		}
+		return nil
+	},
+}
+
+func init() {
+	setCmd.AddCommand(setQuotaCmd)
+
+	setQuotaCmd.Flags().StringVarP(&namespace, "namespace", "n", "", "Namespace UUID")
+	setQuotaCmd.Flags().String("requests-cpu", "", "Total CPU requests")
+	setQuotaCmd.Flags().String("requests-memory", "", "Total memory requests")
+	setQuotaCmd.Flags().String("limits-cpu", "", "Total CPU limits")
+	setQuotaCmd.Flags().String("limits-memory", "", "Total memory limits")
+	setQuotaCmd.Flags().String("pods", "", "Maximum number of pods")
+	setQuotaCmd.Flags().String("default-cpu", "", "Default CPU limit per container")
+	setQuotaCmd.Flags().String("default-memory", "", "Default memory limit per container")
+	setQuotaCmd.Flags().String("default-request-cpu", "", "Default CPU request per container")
+	setQuotaCmd.Flags().String("default-request-memory", "", "Default memory request per container")
+
+	err := setQuotaCmd.MarkFlagRequired("namespace")
+	if err != nil {
+		log.Fatalf("[Error]: %s", err)
+	}
+}
@@ -22,8 +22,8 @@ package cmd
 
 import (
 	"fmt"
+	"log"
 	"os"
-
 	"path/filepath"
 
 	"github.com/gobuffalo/envy"
@@ -50,14 +50,18 @@ to quickly create a Cobra application.`,
 	// Uncomment the following line if your bare application
 	// has an action associated with it:
 	//	Run: func(cmd *cobra.Command, args []string) { },
+
+	// Errors of commands are logged by Execute, after they are audited
+	SilenceErrors: true,
+	SilenceUsage:  true,
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
 	if err := rootCmd.Execute(); err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+		failCLIAudit(err)
+		log.Fatalf("[Error] %s", err)
 	}
 }
 
@@ -101,6 +105,6 @@ func initConfig() {
 
 	// If a config file is found, read it in.
 	if err := viper.ReadInConfig(); err == nil {
-		fmt.Println("Using config file:", viper.ConfigFileUsed())
+		fmt.Fprintln(os.Stderr, "Using config file:", viper.ConfigFileUsed())
 	}
 }
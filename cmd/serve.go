@@ -26,6 +26,7 @@ import (
 	"github.com/spf13/cobra"
 
 	"github.com/kradalby/bork/actions"
+	"github.com/kradalby/bork/kube"
 )
 
 // serveCmd represents the serve command
@@ -39,7 +40,21 @@ Cobra is a CLI library for Go that empowers applications.
 This application is a tool to generate the needed files
 to quickly create a Cobra application.`,
 	Run: func(cmd *cobra.Command, args []string) {
+		if err := kube.ValidateNetworkPolicyConfig(); err != nil {
+			log.Fatalf("[Error] %s", err)
+		}
+
 		app := actions.App(kubeconf)
+
+		stop := make(chan struct{})
+		defer close(stop)
+		if err := actions.StartControllers(stop); err != nil {
+			log.Fatalf("[Error] %#v", err)
+		}
+		if err := actions.StartDeleters(stop); err != nil {
+			log.Fatalf("[Error] %#v", err)
+		}
+
 		if err := app.Serve(); err != nil {
 			log.Fatal(err)
 		}
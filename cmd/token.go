@@ -0,0 +1,110 @@
+// Copyright © 2018 Kristoffer Dalby <kradalby@kradalby.no>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/gobuffalo/uuid"
+	"github.com/kradalby/bork/models"
+	"github.com/spf13/cobra"
+)
+
+// rotateTokenCmd represents the rotate token command
+var rotateTokenCmd = &cobra.Command{
+	Use:   "token",
+	Short: "Replace the service account tokens of a namespace",
+	Long: `Issue new service account tokens for a namespace and revoke the
+old ones, of the namespace service account and of every owner and
+co-owner, or only of the member given with --user. Kubeconfigs
+downloaded before the rotation stop working.
+
+Example:
+
+  bork rotate token -n <namespace uuid>
+  bork rotate token -n <namespace uuid> -u <user uuid>`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		namespaceID, err := uuid.FromString(namespace)
+		if err != nil {
+			return fmt.Errorf("Could not parse UUID: %s", err)
+		}
+
+		ns := &models.Namespace{}
+
+		if err := models.DB.Eager().Find(ns, namespaceID); err != nil {
+			return fmt.Errorf("Could not find namespace: %s", err)
+		}
+
+		client, err := kubeClientForCluster(ns.ClusterID)
+		if err != nil {
+			return err
+		}
+
+		if user == "" {
+			if err := client.RotateAllTokens(ns); err != nil {
+				return err
+			}
+
+			fmt.Printf("Rotated all tokens of namespace %s\n", ns.Name)
+			return nil
+		}
+
+		userID, err := uuid.FromString(user)
+		if err != nil {
+			return fmt.Errorf("Could not parse UUID: %s", err)
+		}
+
+		member, ok := memberOf(ns, userID)
+		if !ok {
+			return fmt.Errorf("User %s is not an owner or co-owner of namespace %s", userID, ns.Name)
+		}
+
+		if _, err := client.RotateMemberToken(ns.Name, member); err != nil {
+			return err
+		}
+
+		fmt.Printf("Rotated token of %s in namespace %s\n", member.Username, ns.Name)
+		return nil
+	},
+}
+
+// memberOf returns the owner or co-owner of the namespace with the ID.
+func memberOf(namespace *models.Namespace, userID uuid.UUID) (models.User, bool) {
+	for _, member := range namespace.Users() {
+		if member.ID == userID {
+			return member, true
+		}
+	}
+	return models.User{}, false
+}
+
+func init() {
+	rotateCmd.AddCommand(rotateTokenCmd)
+
+	rotateTokenCmd.Flags().StringVarP(&namespace, "namespace", "n", "", "Namespace UUID")
+	rotateTokenCmd.Flags().StringVarP(&user, "user", "u", "", "Only rotate the token of this owner or co-owner")
+
+	err := rotateTokenCmd.MarkFlagRequired("namespace")
+	if err != nil {
+		log.Fatalf("[Error]: %s", err)
+	}
+}
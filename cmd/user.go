@@ -45,7 +45,7 @@ and usage of using your command. For example:
 Cobra is a CLI library for Go that empowers applications.
 This application is a tool to generate the needed files
 to quickly create a Cobra application.`,
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 
 		user := models.User{
 			Username:  username,
@@ -55,12 +55,8 @@ to quickly create a Cobra application.`,
 			IsAdmin:   admin,
 			IsActive:  active,
 		}
-		log.Println(user)
 
-		err := models.DB.Create(&user)
-		if err != nil {
-			log.Printf("[Error] %#v", err)
-		}
+		return models.DB.Create(&user)
 	},
 }
 
@@ -74,15 +70,16 @@ and usage of using your command. For example:
 Cobra is a CLI library for Go that empowers applications.
 This application is a tool to generate the needed files
 to quickly create a Cobra application.`,
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 
 		users := models.Users{}
 
 		err := models.DB.All(&users)
 		if err != nil {
-			log.Printf("[Error] %#v", err)
+			return err
 		}
 		log.Println(users)
+		return nil
 	},
 }
 
@@ -62,6 +62,11 @@ config id =
     url [ "namespaces", ID.toString id, "config" ] []
 
 
+deployAuth : ID -> Endpoint
+deployAuth id =
+    url [ "namespaces", ID.toString id, "deploy", "auth" ] []
+
+
 prefix : Endpoint
 prefix =
     url [ "namespaces", "prefix" ] []
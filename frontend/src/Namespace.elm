@@ -21,6 +21,7 @@ module Namespace
         , certificate
         , certificateB64
         , auth
+        , deployAuth
         , config
         , prefix
         , validate
@@ -234,3 +235,15 @@ auth ident =
         (Decode.field "endpoint" Decode.string)
         (Decode.field "token" Decode.string)
         |> Api.get (Api.Namespace.auth ident)
+
+
+{-| The credentials of the deploy service account, for CI pipelines.
+-}
+deployAuth : ID -> Http.Request Auth
+deployAuth ident =
+    Decode.map4 Auth
+        (Decode.field "certificate" Decode.string)
+        (Decode.field "certificate_b64" Decode.string)
+        (Decode.field "endpoint" Decode.string)
+        (Decode.field "token" Decode.string)
+        |> Api.get (Api.Namespace.deployAuth ident)
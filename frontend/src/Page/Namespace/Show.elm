@@ -83,7 +83,7 @@ init session id =
             |> Http.toTask
             |> Task.mapError (Tuple.pair id)
             |> Task.attempt CompletedNamespaceLoad
-        , Namespace.auth id
+        , Namespace.deployAuth id
             |> Http.toTask
             |> Task.mapError (Tuple.pair id)
             |> Task.attempt CompletedAuthLoad
@@ -0,0 +1,307 @@
+package kube
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/gobuffalo/pop"
+	"github.com/gobuffalo/uuid"
+	"github.com/kradalby/bork/models"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	kubernetesErrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// A bork namespace in the cluster without a database row, for example
+// after restoring an old backup, can be adopted instead of deleted. Its
+// row is rebuilt from the labels bork put on it and from the subjects
+// of its role bindings, matched to existing users.
+
+// Adoption is an orphaned namespace and the database row rebuilt from
+// its objects.
+type Adoption struct {
+	Name      string            `json:"name"`
+	Namespace *models.Namespace `json:"namespace,omitempty"`
+	// Problem tells why the namespace can not be adopted
+	Problem string `json:"problem,omitempty"`
+	// Locked orphans are never deleted
+	Locked bool `json:"locked"`
+}
+
+// AdoptNamespaces stores the rebuilt rows of the adoptions. Only the
+// database is written, syncing again repairs what differs in the
+// cluster.
+func AdoptNamespaces(tx *pop.Connection, adoptions []Adoption) error {
+	for _, adoption := range adoptions {
+		if adoption.Namespace == nil {
+			continue
+		}
+		if err := adoption.Namespace.CreateWithCoOwners(tx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// planAdoptions rebuilds the rows of the orphaned namespaces.
+func (c *Client) planAdoptions(orphans []corev1.Namespace, users models.Users) ([]Adoption, error) {
+	adoptions := []Adoption{}
+	for _, orphan := range orphans {
+		namespace, problem, err := c.rebuildNamespace(orphan, users)
+		if err != nil {
+			return nil, err
+		}
+		adoptions = append(adoptions, Adoption{
+			Name:      orphan.Name,
+			Namespace: namespace,
+			Problem:   problem,
+			Locked:    isLocked(&orphan),
+		})
+	}
+	return adoptions, nil
+}
+
+// rebuildNamespace returns the row of a namespace, or why it can not be
+// rebuilt.
+func (c *Client) rebuildNamespace(orphan corev1.Namespace, users models.Users) (*models.Namespace, string, error) {
+	usersByID := map[string]models.User{}
+	for _, user := range users {
+		usersByID[user.ID.String()] = user
+	}
+
+	namespace := &models.Namespace{
+		Name:               orphan.Name,
+		ClusterID:          c.ClusterID(),
+		MemberRoleProfiles: models.MemberRoleProfiles{},
+		Locked:             isLocked(&orphan),
+	}
+
+	namespace.ID = uuid.Must(uuid.NewV4())
+	if id, err := uuid.FromString(orphan.Labels[namespaceIDLabel]); err == nil {
+		namespace.ID = id
+	}
+
+	profile, err := c.boundRoleProfile(orphan.Name, getRoleBindingName(orphan.Name))
+	if err != nil {
+		return nil, "", err
+	}
+	if profile != "" && profile != DefaultRoleProfile() {
+		namespace.RoleProfile = profile
+	}
+
+	members, err := c.boundMembers(orphan.Name, users)
+	if err != nil {
+		return nil, "", err
+	}
+
+	coOwnerIDs := []string{}
+	if annotation := orphan.Annotations[coOwnersAnnotation]; annotation != "" {
+		coOwnerIDs = strings.Split(annotation, ",")
+	}
+
+	owner, ok := usersByID[orphan.Labels[ownerLabel]]
+	if !ok {
+		// Without the label the owner is the only member not listed as
+		// a co-owner
+		candidates := []models.User{}
+		for id := range members {
+			if !contains(coOwnerIDs, id) {
+				candidates = append(candidates, usersByID[id])
+			}
+		}
+		if len(candidates) != 1 {
+			return nil, fmt.Sprintf("owner %q is not a user and could not be found from the bindings", orphan.Labels[ownerLabel]), nil
+		}
+		owner = candidates[0]
+	}
+	namespace.Owner = owner
+	namespace.OwnerID = owner.ID
+
+	// Co-owners in the order of the annotation, then the other members
+	coOwners := models.Users{}
+	for _, id := range coOwnerIDs {
+		if user, ok := usersByID[id]; ok && user.ID != owner.ID {
+			coOwners = append(coOwners, user)
+		}
+	}
+	others := models.Users{}
+	for id := range members {
+		if id != owner.ID.String() && !contains(coOwnerIDs, id) {
+			others = append(others, usersByID[id])
+		}
+	}
+	sort.Slice(others, func(i, j int) bool { return others[i].Username < others[j].Username })
+	namespace.CoOwners = append(coOwners, others...)
+
+	for _, user := range namespace.CoOwners {
+		if profile := members[user.ID.String()]; profile != "" && profile != RoleProfileFor(namespace) {
+			namespace.MemberRoleProfiles[user.ID.String()] = profile
+		}
+	}
+
+	quota, err := c.rebuildQuota(orphan.Name)
+	if err != nil {
+		return nil, "", err
+	}
+	namespace.Quota = quota
+
+	preset, err := c.rebuildNetworkPolicy(namespace)
+	if err != nil {
+		return nil, "", err
+	}
+	if preset != DefaultNetworkPolicy() {
+		namespace.NetworkPolicy = preset
+	}
+
+	return namespace, "", nil
+}
+
+// boundMembers returns the IDs of the users bound in the namespace,
+// through their labelled service account binding or OIDC identity, with
+// the profile they are bound to.
+func (c *Client) boundMembers(namespace string, users models.Users) (map[string]string, error) {
+	bindings, err := c.client.RbacV1().RoleBindings(namespace).List(metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	members := map[string]string{}
+	for _, binding := range bindings.Items {
+		profile := strings.TrimPrefix(binding.RoleRef.Name, namespace+"-")
+
+		for _, user := range users {
+			for _, subject := range binding.Subjects {
+				member := binding.Labels[memberLabel] == user.ID.String() ||
+					subject.Kind == "ServiceAccount" && subject.Name == getMemberServiceAccountName(namespace, user)
+				identity := subject.Kind == "User" && subject.Name != "" && subject.Name == OIDCUsername(user)
+				if !member && !identity {
+					continue
+				}
+
+				// The service account binding of a member names the
+				// profile of that member only
+				if _, seen := members[user.ID.String()]; !seen || member {
+					members[user.ID.String()] = profile
+				}
+			}
+		}
+	}
+	return members, nil
+}
+
+// boundRoleProfile returns the profile the role binding refers to, or
+// an empty string if there is no such binding.
+func (c *Client) boundRoleProfile(namespace string, bindingName string) (string, error) {
+	binding, err := c.client.RbacV1().RoleBindings(namespace).Get(bindingName, metav1.GetOptions{})
+	if kubernetesErrors.IsNotFound(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimPrefix(binding.RoleRef.Name, namespace+"-"), nil
+}
+
+// rebuildQuota returns the quota override giving the ResourceQuota and
+// LimitRange in the cluster, the values that differ from the defaults.
+func (c *Client) rebuildQuota(namespace string) (models.Quota, error) {
+	actual := models.Quota{}
+
+	quota, err := c.client.CoreV1().ResourceQuotas(namespace).Get(getResourceQuotaName(namespace), metav1.GetOptions{})
+	if err != nil && !kubernetesErrors.IsNotFound(err) {
+		return actual, err
+	}
+	if err == nil {
+		actual.RequestsCPU = quantityString(quota.Spec.Hard, corev1.ResourceRequestsCPU)
+		actual.RequestsMemory = quantityString(quota.Spec.Hard, corev1.ResourceRequestsMemory)
+		actual.LimitsCPU = quantityString(quota.Spec.Hard, corev1.ResourceLimitsCPU)
+		actual.LimitsMemory = quantityString(quota.Spec.Hard, corev1.ResourceLimitsMemory)
+		actual.Pods = quantityString(quota.Spec.Hard, corev1.ResourcePods)
+	}
+
+	limits, err := c.client.CoreV1().LimitRanges(namespace).Get(getLimitRangeName(namespace), metav1.GetOptions{})
+	if err != nil && !kubernetesErrors.IsNotFound(err) {
+		return actual, err
+	}
+	if err == nil {
+		for _, limit := range limits.Spec.Limits {
+			if limit.Type != corev1.LimitTypeContainer {
+				continue
+			}
+			actual.DefaultCPU = quantityString(limit.Default, corev1.ResourceCPU)
+			actual.DefaultMemory = quantityString(limit.Default, corev1.ResourceMemory)
+			actual.DefaultRequestCPU = quantityString(limit.DefaultRequest, corev1.ResourceCPU)
+			actual.DefaultRequestMemory = quantityString(limit.DefaultRequest, corev1.ResourceMemory)
+		}
+	}
+
+	defaults := DefaultQuota()
+	override := models.Quota{}
+	for _, field := range []struct {
+		actual   string
+		fallback string
+		override *string
+	}{
+		{actual.RequestsCPU, defaults.RequestsCPU, &override.RequestsCPU},
+		{actual.RequestsMemory, defaults.RequestsMemory, &override.RequestsMemory},
+		{actual.LimitsCPU, defaults.LimitsCPU, &override.LimitsCPU},
+		{actual.LimitsMemory, defaults.LimitsMemory, &override.LimitsMemory},
+		{actual.Pods, defaults.Pods, &override.Pods},
+		{actual.DefaultCPU, defaults.DefaultCPU, &override.DefaultCPU},
+		{actual.DefaultMemory, defaults.DefaultMemory, &override.DefaultMemory},
+		{actual.DefaultRequestCPU, defaults.DefaultRequestCPU, &override.DefaultRequestCPU},
+		{actual.DefaultRequestMemory, defaults.DefaultRequestMemory, &override.DefaultRequestMemory},
+	} {
+		if field.actual != "" && !sameQuantity(field.actual, field.fallback) {
+			*field.override = field.actual
+		}
+	}
+
+	return override, nil
+}
+
+// rebuildNetworkPolicy returns the preset matching the NetworkPolicy in
+// the cluster, or the default if none does.
+func (c *Client) rebuildNetworkPolicy(namespace *models.Namespace) (string, error) {
+	policy, err := c.client.NetworkingV1().NetworkPolicies(namespace.Name).Get(getNetworkPolicyName(namespace.Name), metav1.GetOptions{})
+	if kubernetesErrors.IsNotFound(err) {
+		return DefaultNetworkPolicy(), nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	for _, preset := range NetworkPolicyPresets() {
+		desired, err := buildNetworkPolicy(namespace, preset)
+		if err != nil {
+			return "", err
+		}
+		if equality.Semantic.DeepEqual(desired.Spec, policy.Spec) {
+			return preset, nil
+		}
+	}
+	return DefaultNetworkPolicy(), nil
+}
+
+func quantityString(list corev1.ResourceList, name corev1.ResourceName) string {
+	quantity, ok := list[name]
+	if !ok {
+		return ""
+	}
+	return quantity.String()
+}
+
+func sameQuantity(a string, b string) bool {
+	qa, err := resource.ParseQuantity(a)
+	if err != nil {
+		return a == b
+	}
+	qb, err := resource.ParseQuantity(b)
+	if err != nil {
+		return a == b
+	}
+	return qa.Cmp(qb) == 0
+}
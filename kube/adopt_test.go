@@ -0,0 +1,102 @@
+package kube
+
+import (
+	"testing"
+
+	"github.com/gobuffalo/uuid"
+	"github.com/kradalby/bork/models"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// adoptableNamespace provisions a namespace with settings that differ
+// from the defaults, and returns it with its members.
+func adoptableNamespace(t *testing.T, c *Client) (*models.Namespace, models.Users) {
+	t.Helper()
+
+	owner := testUser("owner")
+	coOwner := testUser("co-owner")
+
+	ns := testNamespaceModel()
+	ns.ID = uuid.Must(uuid.NewV4())
+	ns.Owner = owner
+	ns.OwnerID = owner.ID
+	ns.CoOwners = models.Users{coOwner}
+	ns.RoleProfile = RoleProfileFull
+	ns.MemberRoleProfiles = models.MemberRoleProfiles{coOwner.ID.String(): RoleProfileViewer}
+	ns.Quota = models.Quota{Pods: "50", DefaultCPU: "1"}
+	ns.NetworkPolicy = NetworkPolicyOpen
+
+	if err := c.CreateNamespaceWithServiceAccount(ns); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	return ns, models.Users{testUser("stranger"), coOwner, owner}
+}
+
+func TestPlanSyncAdoptsOrphans(t *testing.T) {
+	c, _ := newTestClient()
+	ns, users := adoptableNamespace(t, c)
+
+	plan, err := c.planSync(models.Namespaces{}, users)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(plan.Adopt) != 1 || plan.Adopt[0].Namespace == nil {
+		t.Fatalf("expected %s to be adoptable, got %#v", testNamespace, plan.Adopt)
+	}
+
+	adopted := plan.Adopt[0].Namespace
+	if adopted.ID != ns.ID || adopted.OwnerID != ns.OwnerID {
+		t.Errorf("expected the ID and owner to be kept, got %s owned by %s", adopted.ID, adopted.OwnerID)
+	}
+	if len(adopted.CoOwners) != 1 || adopted.CoOwners[0].ID != ns.CoOwners[0].ID {
+		t.Errorf("expected the co-owner to be found, got %v", adopted.CoOwners)
+	}
+	if adopted.RoleProfile != ns.RoleProfile || adopted.MemberRoleProfiles[ns.CoOwners[0].ID.String()] != RoleProfileViewer {
+		t.Errorf("expected the role profiles to be found, got %q %v", adopted.RoleProfile, adopted.MemberRoleProfiles)
+	}
+	if adopted.Quota != ns.Quota {
+		t.Errorf("expected quota %#v, got %#v", ns.Quota, adopted.Quota)
+	}
+	if adopted.NetworkPolicy != ns.NetworkPolicy {
+		t.Errorf("expected network policy %q, got %q", ns.NetworkPolicy, adopted.NetworkPolicy)
+	}
+
+	drift, err := c.FindDrift(adopted)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(drift) != 0 {
+		t.Errorf("expected the adopted namespace not to drift, got %v", drift)
+	}
+}
+
+func TestPlanSyncFindsOwnerFromBindings(t *testing.T) {
+	c, _ := newTestClient()
+	ns, users := adoptableNamespace(t, c)
+
+	namespaces := c.client.CoreV1().Namespaces()
+	cluster, err := namespaces.Get(testNamespace, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	delete(cluster.Labels, ownerLabel)
+	if _, err := namespaces.Update(cluster); err != nil {
+		t.Fatal(err)
+	}
+
+	plan, err := c.planSync(models.Namespaces{}, users)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(plan.Adopt) != 1 || plan.Adopt[0].Namespace == nil || plan.Adopt[0].Namespace.OwnerID != ns.OwnerID {
+		t.Errorf("expected the owner to be found from the bindings, got %#v", plan.Adopt)
+	}
+
+	plan, err = c.planSync(models.Namespaces{}, models.Users{})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(plan.Adopt) != 1 || plan.Adopt[0].Namespace != nil || plan.Adopt[0].Problem == "" {
+		t.Errorf("expected a namespace without known users not to be adoptable, got %#v", plan.Adopt)
+	}
+}
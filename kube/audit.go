@@ -0,0 +1,39 @@
+package kube
+
+import (
+	"log"
+
+	"github.com/gobuffalo/uuid"
+	"github.com/kradalby/bork/models"
+)
+
+// systemAuditEvent returns the audit event of bork acting on the
+// namespace on its own, failed if err is not nil.
+func systemAuditEvent(action string, namespace *models.Namespace, detail string, err error) *models.AuditEvent {
+	event := &models.AuditEvent{
+		Actor:       models.SystemActor,
+		Source:      models.AuditSourceSystem,
+		Action:      action,
+		TargetType:  "namespace",
+		TargetID:    namespace.ID.String(),
+		Target:      namespace.Name,
+		NamespaceID: uuid.NullUUID{UUID: namespace.ID, Valid: true},
+		Outcome:     models.AuditSuccess,
+		Detail:      detail,
+	}
+
+	if err != nil {
+		event.Outcome = models.AuditFailure
+		event.Detail += "\nerror: " + err.Error()
+	}
+	return event
+}
+
+// recordSystemAudit records bork acting on the namespace on its own. A
+// failure to record is logged, it does not stop the action.
+func recordSystemAudit(action string, namespace *models.Namespace, detail string, err error) {
+	event := systemAuditEvent(action, namespace, detail, err)
+	if recordErr := models.RecordAuditEvent(models.DB, event); recordErr != nil {
+		log.Printf("[Error] %#v", recordErr)
+	}
+}
@@ -0,0 +1,29 @@
+package kube
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/kradalby/bork/models"
+)
+
+func TestSystemAuditEvent(t *testing.T) {
+	ns := testNamespaceModel()
+
+	event := systemAuditEvent("DeletePendingNamespaces", ns, "grace period ended", nil)
+	if event.Actor != models.SystemActor || event.Source != models.AuditSourceSystem || event.ActorID.Valid {
+		t.Errorf("expected an event of the system, got %#v", event)
+	}
+	if event.Target != testNamespace || event.TargetID != ns.ID.String() || !event.NamespaceID.Valid {
+		t.Errorf("expected the namespace as target, got %#v", event)
+	}
+	if event.Outcome != models.AuditSuccess {
+		t.Errorf("expected outcome %s, got %s", models.AuditSuccess, event.Outcome)
+	}
+
+	event = systemAuditEvent("DeletePendingNamespaces", ns, "grace period ended", errors.New("namespace is locked"))
+	if event.Outcome != models.AuditFailure || !strings.HasSuffix(event.Detail, "error: namespace is locked") {
+		t.Errorf("expected a failure with the error, got %s: %q", event.Outcome, event.Detail)
+	}
+}
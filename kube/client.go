@@ -1,12 +1,13 @@
 package kube
 
 import (
-	"bytes"
+	"context"
 	b64 "encoding/base64"
 	"errors"
+	"fmt"
 	"log"
 	"strings"
-	"text/template"
+	"time"
 
 	"github.com/gobuffalo/envy"
 	"github.com/gobuffalo/uuid"
@@ -15,78 +16,151 @@ import (
 	rbacv1 "k8s.io/api/rbac/v1"
 	kubernetesErrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/watch"
 	kubernetes "k8s.io/client-go/kubernetes"
 	restclient "k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
 )
 
-var clusterRoleName = "bork-namespaced-cr"
 var ENV = envy.Get("GO_ENV", "development")
 
+// Client manages the bork objects in a Kubernetes cluster
 type Client struct {
-	client *kubernetes.Clientset
+	client kubernetes.Interface
 	config *restclient.Config
+	// cluster is nil for the default cluster
+	cluster *models.Cluster
+	// roleProfiles returns the profiles a role is kept for in every
+	// namespace
+	roleProfiles func() (RoleProfiles, error)
 }
 
+// NewClient returns a client using the given clientset. The config is
+// used to look up the API server endpoint.
+func NewClient(clientset kubernetes.Interface, config *restclient.Config) *Client {
+	return &Client{
+		client:       clientset,
+		config:       config,
+		roleProfiles: LoadRoleProfiles,
+	}
+}
+
+// NewInClusterClient returns a client using the service account of the
+// pod bork is running in.
 func NewInClusterClient() (*Client, error) {
-	// creates the in-cluster config
 	config, err := restclient.InClusterConfig()
 	if err != nil {
-		panic(err.Error())
+		return nil, fmt.Errorf("loading in-cluster config: %s", err)
 	}
-	// creates the clientset
+
 	clientset, err := kubernetes.NewForConfig(config)
 	if err != nil {
-		panic(err.Error())
-	}
-
-	client := &Client{
-		client: clientset,
-		config: config,
+		return nil, fmt.Errorf("creating clientset: %s", err)
 	}
 
-	return client, nil
+	return NewClient(clientset, config), nil
 }
 
+// NewOutOfClusterClient returns a client using the given kubeconfig.
 func NewOutOfClusterClient(kubeconf string) (*Client, error) {
-
 	config, err := clientcmd.BuildConfigFromFlags("", kubeconf)
 	if err != nil {
-		panic(err.Error())
+		return nil, fmt.Errorf("loading kubeconfig %s: %s", kubeconf, err)
 	}
 
-	// create the clientset
 	clientset, err := kubernetes.NewForConfig(config)
 	if err != nil {
-		panic(err.Error())
+		return nil, fmt.Errorf("creating clientset: %s", err)
 	}
 
-	client := &Client{
-		client: clientset,
-		config: config,
+	return NewClient(clientset, config), nil
+}
+
+// NewClusterClient returns a client for a cluster registered in the
+// database using its kubeconfig. The in-cluster config is left to the
+// default cluster, a registered cluster using it would be managed twice
+// under two cluster IDs, each seeing the namespaces of the other as
+// orphans.
+func NewClusterClient(cluster *models.Cluster) (*Client, error) {
+	if cluster.Kubeconfig == "" {
+		return nil, fmt.Errorf("cluster %s has no kubeconfig", cluster.Name)
 	}
 
+	config, err := clientcmd.RESTConfigFromKubeConfig([]byte(cluster.Kubeconfig))
+	if err != nil {
+		return nil, fmt.Errorf("loading config of cluster %s: %s", cluster.Name, err)
+	}
+
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("creating clientset for cluster %s: %s", cluster.Name, err)
+	}
+
+	client := NewClient(clientset, config)
+	client.cluster = cluster
+
 	return client, nil
 }
 
-func (c *Client) CreateNamespace(name string, ownerID uuid.UUID) (*uuid.UUID, error) {
+// Cluster returns the cluster the client is connected to, nil for the
+// default cluster.
+func (c *Client) Cluster() *models.Cluster {
+	return c.cluster
+}
 
-	err := c.CreateNamespaceWithServiceAccount(name, ownerID)
-	if err != nil {
-		return nil, err
+// ClusterName returns the name of the cluster the client is connected
+// to, "default" for the default cluster.
+func (c *Client) ClusterName() string {
+	if c.cluster == nil {
+		return "default"
 	}
+	return c.cluster.Name
+}
 
-	ns := &models.Namespace{
-		Name:    name,
-		OwnerID: ownerID,
+// ClusterID returns the ID of the cluster as stored on namespaces.
+func (c *Client) ClusterID() uuid.NullUUID {
+	if c.cluster == nil {
+		return uuid.NullUUID{}
 	}
+	return uuid.NullUUID{UUID: c.cluster.ID, Valid: true}
+}
+
+func (c *Client) CreateNamespace(ns *models.Namespace) (*uuid.UUID, error) {
+
+	// The namespace lives on the cluster of this client
+	ns.ClusterID = c.ClusterID()
+
+	// The ID is put on every object, so it is chosen before saving
+	if ns.ID == uuid.Nil {
+		ns.ID = uuid.Must(uuid.NewV4())
+	}
+
+	// The owner is needed to create their service account
+	if ns.Owner.ID == uuid.Nil {
+		if err := models.DB.Find(&ns.Owner, ns.OwnerID); err != nil {
+			return nil, err
+		}
+	}
+
+	steps := c.provisionSteps(ns)
 
 	// Save the namespace in the database
 	// when all other options are successful
-	_, err = models.DB.ValidateAndCreate(ns)
+	steps = append(steps, provisionStep{
+		name: "database",
+		create: func() (bool, error) {
+			verrs, err := models.DB.ValidateAndCreate(ns)
+			if err != nil {
+				return false, err
+			}
+			if verrs.HasAny() {
+				return false, verrs
+			}
+			return true, nil
+		},
+	})
+
+	err := provision(ns.Name, steps)
 	if err != nil {
-		log.Printf("[Error] %#v", err)
 		return nil, err
 	}
 
@@ -102,8 +176,21 @@ func (c *Client) DeleteNamespace(namespaceID uuid.UUID) error {
 		return err
 	}
 
+	if namespace.Locked {
+		return ErrNamespaceLocked
+	}
+
+	// Keep the controller from recreating the objects while they are
+	// removed
+	if err := namespace.SetReconcileStatus(models.DB, models.ReconcileDeleting, nil); err != nil {
+		return err
+	}
+
 	err := c.DeleteNamespaceWithServiceAccount(namespace.Name)
 	if err != nil {
+		if statusErr := namespace.SetReconcileStatus(models.DB, models.ReconcileFailed, err); statusErr != nil {
+			log.Printf("[Error] %#v", statusErr)
+		}
 		return err
 	}
 
@@ -114,236 +201,283 @@ func (c *Client) DeleteNamespace(namespaceID uuid.UUID) error {
 	return nil
 }
 
+// DeleteNamespaceWithServiceAccount removes the namespace and all the
+// objects belonging to it from the cluster. Objects already gone are
+// skipped, so it can be run again after a partial failure. Namespaces
+// locked in the cluster are left alone.
 func (c *Client) DeleteNamespaceWithServiceAccount(name string) error {
-
-	err := c.deleteServiceAccount(name)
-	if err != nil {
-		log.Printf("[Error] %#v", err)
+	if err := c.checkNotLockedInCluster(name); err != nil {
 		return err
 	}
 
-	err = c.deleteRole(name)
+	err := c.deleteManagedObjects(name)
 	if err != nil {
 		log.Printf("[Error] %#v", err)
 		return err
 	}
 
-	err = c.deleteServiceAccountRoleBinding(name)
+	err = c.deleteUnlabelledObjectsIfPresent(name)
 	if err != nil {
 		log.Printf("[Error] %#v", err)
 		return err
 	}
 
-	err = c.deleteServiceAccountClusterRoleBinding(name)
+	// delete the namespace in the kubecluster
+	err = c.deleteNamespaceIfPresent(name)
 	if err != nil {
 		log.Printf("[Error] %#v", err)
 		return err
 	}
 
-	// delete the namespace in the kubecluster
-	err = c.deleteNamespace(name)
+	return nil
+}
+
+// CreateNamespaceWithServiceAccount creates the namespace and all the
+// objects belonging to it in the cluster. If any step fails, the
+// objects created so far are removed again. Creating is applying to a
+// namespace that does not exist yet, see ApplyNamespace.
+func (c *Client) CreateNamespaceWithServiceAccount(namespace *models.Namespace) error {
+	return c.ApplyNamespace(namespace)
+}
+
+// ApplyNamespace brings the namespace and all the objects belonging to
+// it, with their labels and annotations, in line with the database,
+// creating the ones missing. The members must be loaded on the
+// namespace.
+func (c *Client) ApplyNamespace(namespace *models.Namespace) error {
+	return provision(namespace.Name, c.provisionSteps(namespace))
+}
+
+// ensureNamespace creates the namespace or brings the labels and
+// annotations of an existing one up to date. Existing namespaces not
+// created by bork are left alone. The returned bool reports if the
+// namespace was created.
+func (c *Client) ensureNamespace(namespace *models.Namespace) (bool, error) {
+	desired := &corev1.Namespace{
+		ObjectMeta: namespaceObjectMeta(namespace),
+	}
+
+	existing, err := c.client.CoreV1().Namespaces().Get(namespace.Name, metav1.GetOptions{})
+	if kubernetesErrors.IsNotFound(err) {
+		_, err = c.client.CoreV1().Namespaces().Create(desired)
+		return err == nil, err
+	}
 	if err != nil {
-		log.Printf("[Error] %#v", err)
-		return err
+		return false, err
 	}
 
-	return nil
+	if _, ok := existing.Labels[borkLabel]; !ok {
+		return false, fmt.Errorf("namespace %s already exists and is not managed by bork", namespace.Name)
+	}
+
+	// A namespace left behind by another database row or owner is only
+	// taken over by adopting it, never by creating one with its name
+	if !sameLabel(existing.Labels, namespaceIDLabel, desired.Labels) || !sameLabel(existing.Labels, ownerLabel, desired.Labels) {
+		return false, fmt.Errorf("namespace %s already exists and belongs to another bork namespace, adopt it with bork sync namespace --adopt", namespace.Name)
+	}
+
+	if !updateManagedMetadata(&existing.ObjectMeta, desired.ObjectMeta) {
+		return false, nil
+	}
+
+	_, err = c.client.CoreV1().Namespaces().Update(existing)
+	return false, err
 }
 
-func (c *Client) CreateNamespaceWithServiceAccount(name string, owner uuid.UUID) error {
+// deleteManagedObjects removes the service accounts, roles and bindings
+// bork labelled as belonging to the namespace, including the cluster
+// roles and cluster role bindings that would outlive it.
+func (c *Client) deleteManagedObjects(namespace string) error {
+	options := metav1.ListOptions{LabelSelector: namespaceSelector(namespace)}
+	rbac := c.client.RbacV1()
 
-	// Create the namespace in the kubecluster
-	err := c.createNamespace(name, owner)
+	clusterRoleBindings, err := rbac.ClusterRoleBindings().List(options)
 	if err != nil {
-		log.Printf("[Error] %#v", err)
 		return err
 	}
+	for _, binding := range clusterRoleBindings.Items {
+		err := rbac.ClusterRoleBindings().Delete(binding.Name, &metav1.DeleteOptions{})
+		if ignoreNotFound(err) != nil {
+			return err
+		}
+	}
 
-	err = c.createServiceAccount(name)
+	clusterRoles, err := rbac.ClusterRoles().List(options)
 	if err != nil {
-		log.Printf("[Error] %#v", err)
 		return err
 	}
+	for _, clusterRole := range clusterRoles.Items {
+		err := rbac.ClusterRoles().Delete(clusterRole.Name, &metav1.DeleteOptions{})
+		if ignoreNotFound(err) != nil {
+			return err
+		}
+	}
 
-	err = c.createRole(name)
+	roleBindings, err := rbac.RoleBindings(namespace).List(options)
 	if err != nil {
-		log.Printf("[Error] %#v", err)
 		return err
 	}
+	for _, binding := range roleBindings.Items {
+		err := rbac.RoleBindings(namespace).Delete(binding.Name, &metav1.DeleteOptions{})
+		if ignoreNotFound(err) != nil {
+			return err
+		}
+	}
 
-	err = c.createIfNotExistServiceAccountClusterRoleBinding(name)
+	roles, err := rbac.Roles(namespace).List(options)
 	if err != nil {
-		log.Printf("[Error] %#v", err)
 		return err
 	}
+	for _, role := range roles.Items {
+		err := rbac.Roles(namespace).Delete(role.Name, &metav1.DeleteOptions{})
+		if ignoreNotFound(err) != nil {
+			return err
+		}
+	}
 
-	err = c.createServiceAccountRoleBinding(name)
+	serviceAccounts, err := c.client.CoreV1().ServiceAccounts(namespace).List(options)
 	if err != nil {
-		log.Printf("[Error] %#v", err)
 		return err
 	}
+	for _, serviceAccount := range serviceAccounts.Items {
+		err := c.client.CoreV1().ServiceAccounts(namespace).Delete(serviceAccount.Name, &metav1.DeleteOptions{})
+		if ignoreNotFound(err) != nil {
+			return err
+		}
+	}
 
 	return nil
 }
 
-func (c *Client) createNamespace(namespace string, owner uuid.UUID) error {
-
-	_, err := c.client.CoreV1().Namespaces().Create(&corev1.Namespace{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:   namespace,
-			Labels: createLabels(owner.String()),
-		},
-	})
-	return err
-}
-
-func (c *Client) deleteNamespace(namespace string) error {
-	err := c.client.CoreV1().Namespaces().Delete(namespace, &metav1.DeleteOptions{})
-	return err
-}
-
-func (c *Client) createServiceAccount(namespace string) error {
-	serviceAccountName := getServiceAccountName(namespace)
-
-	serviceAccount := &corev1.ServiceAccount{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      serviceAccountName,
-			Namespace: namespace,
-			// Labels:    getLabels(),
-		},
+// deleteUnlabelledObjectsIfPresent removes the objects older versions
+// of bork created without labels, by their names.
+func (c *Client) deleteUnlabelledObjectsIfPresent(namespace string) error {
+	if err := c.deleteServiceAccountIfPresent(namespace); err != nil {
+		return err
 	}
 
-	_, err := c.client.CoreV1().ServiceAccounts(namespace).Create(serviceAccount)
-	if err != nil {
+	if err := c.deleteLegacyRoleIfPresent(namespace); err != nil {
 		return err
 	}
 
-	// Attempt to watch for finished create event
-	w, err := c.client.CoreV1().Secrets(namespace).Watch(metav1.ListOptions{})
-	if err != nil {
+	if err := c.deleteServiceAccountRoleBindingIfPresent(namespace); err != nil {
 		return err
 	}
 
-	for event := range w.ResultChan() {
-		switch event.Type {
-		case watch.Added:
-			secret, ok := event.Object.(*corev1.Secret)
-			if !ok {
-				return errors.New("unexpected object type, not Secret")
-			}
-			secret_owner, ok := secret.Annotations["kubernetes.io/service-account.name"]
-			if ok && (secret_owner == serviceAccountName) {
-				w.Stop()
-				return nil
-			}
-		}
+	if err := c.deleteServiceAccountClusterRoleBindingIfPresent(namespace); err != nil {
+		return err
 	}
 
-	return nil
+	err := c.client.RbacV1().ClusterRoleBindings().Delete(getOIDCClusterRoleBindingName(namespace), &metav1.DeleteOptions{})
+	return ignoreNotFound(err)
 }
 
-func (c *Client) deleteServiceAccount(namespace string) error {
-	err := c.client.CoreV1().ServiceAccounts(namespace).Delete(getServiceAccountName(namespace), &metav1.DeleteOptions{})
-	return err
+func (c *Client) deleteNamespaceIfPresent(namespace string) error {
+	err := c.client.CoreV1().Namespaces().Delete(namespace, &metav1.DeleteOptions{})
+	return ignoreNotFound(err)
 }
 
-func (c *Client) createRole(namespace string) error {
-	roleName := getRoleName(namespace)
+// ensureServiceAccount creates the namespace service account and waits
+// for its token. An existing service account is kept as is, as its
+// token has already been issued.
+func (c *Client) ensureServiceAccount(namespace *models.Namespace) (bool, error) {
+	return c.ensureNamedServiceAccount(namespace, getServiceAccountName(namespace.Name), nil)
+}
 
-	role := rbacv1.Role{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      roleName,
-			Namespace: namespace,
-			// Labels:    getLabels(),
-		},
-		Rules: []rbacv1.PolicyRule{
-			{
-				APIGroups: []string{"", "extensions", "apps"},
-				Resources: []string{"*"},
-				Verbs:     []string{"*"},
-			},
-			{
-				APIGroups: []string{"batch"},
-				Resources: []string{"jobs", "cronjobs"},
-				Verbs:     []string{"*"},
-			},
-		},
+// ensureNamedServiceAccount creates a service account in the namespace
+// and waits for its token. Only the labels and annotations of an
+// existing one are updated.
+func (c *Client) ensureNamedServiceAccount(namespace *models.Namespace, serviceAccountName string, labels map[string]string) (bool, error) {
+	serviceAccounts := c.client.CoreV1().ServiceAccounts(namespace.Name)
+	desired := &corev1.ServiceAccount{
+		ObjectMeta: managedObjectMeta(namespace, serviceAccountName, labels),
 	}
 
-	_, err := c.client.RbacV1().Roles(namespace).Create(&role)
-	return err
-}
+	existing, err := serviceAccounts.Get(serviceAccountName, metav1.GetOptions{})
+	if err == nil {
+		if !updateManagedMetadata(&existing.ObjectMeta, desired.ObjectMeta) {
+			return false, nil
+		}
+		_, err = serviceAccounts.Update(existing)
+		return false, err
+	}
+	if !kubernetesErrors.IsNotFound(err) {
+		return false, err
+	}
 
-func (c *Client) deleteRole(namespace string) error {
-	err := c.client.RbacV1().Roles(namespace).Delete(getRoleName(namespace), &metav1.DeleteOptions{})
-	return err
-}
+	_, err = serviceAccounts.Create(desired)
+	if err != nil {
+		return false, err
+	}
 
-func (c *Client) createIfNotExistServiceAccountClusterRoleBinding(namespace string) error {
-	err := c.createServiceAccountClusterRoleBinding(namespace)
-	if kubernetesErrors.IsAlreadyExists(err) {
-		return nil
+	// Tokens are minted on request, there is no secret to wait for
+	if CredentialMode() == CredentialModeTokenRequest {
+		return true, nil
 	}
-	return err
+
+	ctx, cancel := context.WithTimeout(context.Background(), TokenTimeout())
+	defer cancel()
+
+	return true, c.waitForServiceAccountToken(ctx, namespace.Name, serviceAccountName)
 }
 
-func (c *Client) createServiceAccountClusterRoleBinding(namespace string) error {
-	serviceAccountName := getServiceAccountName(namespace)
-	roleBindingName := getClusterRoleBindingName(namespace)
-	roleBinding := rbacv1.ClusterRoleBinding{
-		ObjectMeta: metav1.ObjectMeta{
-			Name: roleBindingName,
-			// Labels: getLabels(),
-		},
+func (c *Client) deleteServiceAccountIfPresent(namespace string) error {
+	err := c.client.CoreV1().ServiceAccounts(namespace).Delete(getServiceAccountName(namespace), &metav1.DeleteOptions{})
+	return ignoreNotFound(err)
+}
+
+func buildServiceAccountClusterRoleBinding(namespace *models.Namespace) *rbacv1.ClusterRoleBinding {
+	return &rbacv1.ClusterRoleBinding{
+		ObjectMeta: managedClusterObjectMeta(namespace, getClusterRoleBindingName(namespace.Name), nil),
 		Subjects: []rbacv1.Subject{{
-			Name:      serviceAccountName,
+			Name:      getServiceAccountName(namespace.Name),
 			Kind:      "ServiceAccount",
-			Namespace: namespace,
+			Namespace: namespace.Name,
 		}},
 		RoleRef: rbacv1.RoleRef{
 			Kind:     "ClusterRole",
-			Name:     clusterRoleName,
+			Name:     getClusterRoleName(namespace.Name),
 			APIGroup: "rbac.authorization.k8s.io",
 		}}
-	_, err := c.client.RbacV1().ClusterRoleBindings().Create(&roleBinding)
-	return err
 }
 
-func (c *Client) deleteServiceAccountClusterRoleBinding(namespace string) error {
+// ensureServiceAccountClusterRoleBinding creates the cluster role
+// binding or updates the subjects of an existing one. The role
+// reference of a binding cannot be changed, so a binding pointing to
+// the wrong role is replaced.
+func (c *Client) ensureServiceAccountClusterRoleBinding(namespace *models.Namespace) (bool, error) {
+	return c.ensureClusterRoleBinding(buildServiceAccountClusterRoleBinding(namespace))
+}
+
+func (c *Client) deleteServiceAccountClusterRoleBindingIfPresent(namespace string) error {
 	err := c.client.RbacV1().ClusterRoleBindings().Delete(getClusterRoleBindingName(namespace), &metav1.DeleteOptions{})
-	return err
+	return ignoreNotFound(err)
 }
 
-func (c *Client) createServiceAccountRoleBinding(namespace string) error {
-	serviceAccountName := getServiceAccountName(namespace)
-	roleBindingName := getRoleBindingName(namespace)
-	roleName := getRoleName(namespace)
-
-	roleBinding := rbacv1.RoleBinding{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      roleBindingName,
-			Namespace: namespace,
-			// Labels:    getLabels(),
-		},
+func buildServiceAccountRoleBinding(namespace *models.Namespace, profile string) *rbacv1.RoleBinding {
+	return &rbacv1.RoleBinding{
+		ObjectMeta: managedObjectMeta(namespace, getRoleBindingName(namespace.Name), nil),
 		Subjects: []rbacv1.Subject{{
-			Name:      serviceAccountName,
+			Name:      getServiceAccountName(namespace.Name),
 			Kind:      "ServiceAccount",
-			Namespace: namespace,
+			Namespace: namespace.Name,
 		}},
 		RoleRef: rbacv1.RoleRef{
 			Kind:     "Role",
-			Name:     roleName,
+			Name:     getRoleName(namespace.Name, profile),
 			APIGroup: "rbac.authorization.k8s.io",
 		}}
+}
 
-	_, err := c.client.RbacV1().RoleBindings(namespace).Create(&roleBinding)
-
-	return err
+// ensureServiceAccountRoleBinding binds the namespace service account
+// to the role of the profile.
+func (c *Client) ensureServiceAccountRoleBinding(namespace *models.Namespace, profile string) (bool, error) {
+	return c.ensureRoleBinding(buildServiceAccountRoleBinding(namespace, profile))
 }
 
-func (c *Client) deleteServiceAccountRoleBinding(namespace string) error {
+func (c *Client) deleteServiceAccountRoleBindingIfPresent(namespace string) error {
 	err := c.client.RbacV1().RoleBindings(namespace).Delete(getRoleBindingName(namespace), &metav1.DeleteOptions{})
-	return err
+	return ignoreNotFound(err)
 }
 
 func (c *Client) getServiceAccount(namespace string) (*corev1.ServiceAccount, error) {
@@ -357,10 +491,14 @@ func (c *Client) getServiceAccount(namespace string) (*corev1.ServiceAccount, er
 }
 
 func (c *Client) getSecretName(namespace string) (string, error) {
-	sa, err := c.getServiceAccount(namespace)
+	return c.getServiceAccountSecretName(namespace, getServiceAccountName(namespace))
+}
+
+func (c *Client) getServiceAccountSecretName(namespace string, serviceAccountName string) (string, error) {
+	sa, err := c.client.CoreV1().ServiceAccounts(namespace).Get(serviceAccountName, metav1.GetOptions{})
 	if err != nil {
-		log.Printf("[TRACE] Failed getting service account from namespace %s", namespace)
-		return "", nil
+		log.Printf("[TRACE] Failed getting service account %s from namespace %s", serviceAccountName, namespace)
+		return "", err
 	}
 
 	// This should probably be changed
@@ -374,7 +512,11 @@ func (c *Client) getSecretName(namespace string) (string, error) {
 }
 
 func (c *Client) getSecret(namespace string) (*corev1.Secret, error) {
-	secretName, err := c.getSecretName(namespace)
+	return c.getServiceAccountSecret(namespace, getServiceAccountName(namespace))
+}
+
+func (c *Client) getServiceAccountSecret(namespace string, serviceAccountName string) (*corev1.Secret, error) {
+	secretName, err := c.getServiceAccountSecretName(namespace, serviceAccountName)
 	if err != nil {
 		log.Printf("[TRACE] Failed getting secret name from namespace %s", namespace)
 		return nil, err
@@ -389,9 +531,24 @@ func (c *Client) getSecret(namespace string) (*corev1.Secret, error) {
 	return secret, nil
 }
 
+// GetCertificate returns the CA of the API server. The CA configured
+// on the cluster takes precedence over the one in the token secret.
+// Without a token secret, in the tokenrequest credential mode, the CA
+// is looked up elsewhere.
 func (c *Client) GetCertificate(namespace string) (string, error) {
-	secret, err := c.getSecret(namespace)
+	return c.getServiceAccountCertificate(namespace, getServiceAccountName(namespace))
+}
+
+func (c *Client) getServiceAccountCertificate(namespace string, serviceAccountName string) (string, error) {
+	if c.cluster != nil && c.cluster.CertificateAuthority != "" {
+		return c.cluster.CertificateAuthority, nil
+	}
+
+	secret, err := c.getServiceAccountSecret(namespace, serviceAccountName)
 	if err != nil {
+		if CredentialMode() == CredentialModeTokenRequest {
+			return c.getRootCertificate(namespace)
+		}
 		return "", err
 	}
 
@@ -409,81 +566,41 @@ func (c *Client) GetCertificateB64(namespace string) (string, error) {
 	return certB64, nil
 }
 
-func (c *Client) GetToken(namespace string) (string, error) {
-	secret, err := c.getSecret(namespace)
-	if err != nil {
-		return "", err
-	}
-
-	return string(secret.Data["token"]), nil
-}
-
-func (c *Client) CreateConfiguration(namespace string) (string, error) {
-	type Config struct {
-		Namespace   string
-		Endpoint    string
-		Certificate string
-		Token       string
-	}
-
-	config := `apiVersion: v1
-kind: Config
-clusters:
-- cluster:
-    certificate-authority-data: {{.Certificate}}
-    server: {{.Endpoint}}
-  name: cluster
-users:
-- name: {{.Namespace}}-user
-  user:
-    client-key-data: {{.Certificate}}
-    token: {{.Token}}
-contexts:
-- context:
-    cluster: cluster
-    namespace: {{.Namespace}}
-    user: {{.Namespace}}-user
-  name: {{.Namespace}}
-current-context: {{.Namespace}}`
-
-	var endpoint string
-	if ENV == "development" {
-		endpoint = c.GetEndpoint()
-	} else {
-		endpoint = envy.Get("BORK_KUBERNETES_ENDPOINT", "")
-	}
-
-	certificate, err := c.GetCertificateB64(namespace)
-	if err != nil {
-		return "", err
-	}
+// GetToken returns a token of the namespace service account. The TTL is
+// only used in the tokenrequest credential mode, zero is the default.
+func (c *Client) GetToken(namespace string, ttl time.Duration) (string, error) {
+	return c.serviceAccountToken(namespace, getServiceAccountName(namespace), ttl)
+}
 
-	token, err := c.GetToken(namespace)
+// CreateConfiguration returns a kubeconfig using a token of the
+// namespace service account, see GetToken.
+func (c *Client) CreateConfiguration(namespace string, ttl time.Duration, options KubeconfigOptions) (string, error) {
+	token, err := c.GetToken(namespace, ttl)
 	if err != nil {
 		return "", err
 	}
 
-	data := Config{
-		Namespace:   namespace,
-		Endpoint:    endpoint,
-		Certificate: certificate,
-		Token:       token,
-	}
-
-	tmpl := template.Must(template.New("config").Parse(config))
-
-	var generatedTemplate bytes.Buffer
-	if err := tmpl.Execute(&generatedTemplate, data); err != nil {
-		return "", err
-	}
-
-	return generatedTemplate.String(), nil
+	return c.createConfiguration(namespace, tokenCredentials(getServiceAccountName(namespace), token), options)
 }
 
 func (c *Client) GetEndpoint() string {
 	return c.config.Host
 }
 
+// Endpoint returns the public address of the API server handed out to
+// users. For the default cluster it is BORK_KUBERNETES_ENDPOINT, or the
+// address bork connects to when running in development.
+func (c *Client) Endpoint() string {
+	if c.cluster != nil {
+		return c.cluster.Endpoint
+	}
+
+	if ENV == "development" {
+		return c.GetEndpoint()
+	}
+	return envy.Get("BORK_KUBERNETES_ENDPOINT", "")
+}
+
 func getServiceAccountName(namespace string) string {
 	return namespace + "-user"
 }
@@ -496,13 +613,29 @@ func getClusterRoleBindingName(namespace string) string {
 	return namespace + "-user-clusterrole-binding"
 }
 
-func getRoleName(namespace string) string {
-	return namespace + "-user-full-access"
+// getRoleName returns the name of the role of a profile in the
+// namespace.
+func getRoleName(namespace string, profile string) string {
+	return namespace + "-" + profile
 }
 
-func createLabels(owner string) map[string]string {
-	return map[string]string{
-		"bork":       "true",
-		"bork.owner": owner,
+func getResourceQuotaName(namespace string) string {
+	return namespace + "-quota"
+}
+
+func getLimitRangeName(namespace string) string {
+	return namespace + "-limits"
+}
+
+func getNetworkPolicyName(namespace string) string {
+	return namespace + "-network-policy"
+}
+
+// ignoreNotFound turns NotFound errors into nil, making deletes
+// idempotent.
+func ignoreNotFound(err error) error {
+	if kubernetesErrors.IsNotFound(err) {
+		return nil
 	}
+	return err
 }
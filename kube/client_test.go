@@ -0,0 +1,243 @@
+package kube
+
+import (
+	b64 "encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/gobuffalo/uuid"
+	"github.com/kradalby/bork/models"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestProvisionIsIdempotent(t *testing.T) {
+	c, _ := newTestClient()
+	ns := testNamespaceModel()
+
+	for i := 0; i < 2; i++ {
+		if err := c.CreateNamespaceWithServiceAccount(ns); err != nil {
+			t.Fatalf("run %d: unexpected error: %s", i, err)
+		}
+	}
+
+	if remaining := remainingObjects(t, c, testNamespace); len(remaining) != 9 {
+		t.Errorf("expected all 9 objects to exist, got %v", remaining)
+	}
+}
+
+func TestApplyNamespaceWithoutChangesDoesNotUpdate(t *testing.T) {
+	c, clientset := newTestClient()
+	ns := testNamespaceModel()
+
+	if err := c.CreateNamespaceWithServiceAccount(ns); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	clientset.ClearActions()
+
+	if err := c.ApplyNamespace(ns); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	for _, action := range clientset.Actions() {
+		if action.GetVerb() == "update" || action.GetVerb() == "create" {
+			t.Errorf("expected nothing to be written, got %s %s", action.GetVerb(), action.GetResource().Resource)
+		}
+	}
+}
+
+func TestProvisionKeepsExistingObjectsOnFailure(t *testing.T) {
+	ns := testNamespaceModel()
+	c, clientset := newTestClient(&corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   testNamespace,
+			Labels: managedLabels(ns, nil),
+		},
+	})
+	failOn(clientset, "create", "rolebindings")
+
+	if err := c.CreateNamespaceWithServiceAccount(ns); err == nil {
+		t.Fatal("expected provisioning to fail")
+	}
+
+	remaining := remainingObjects(t, c, testNamespace)
+	if len(remaining) != 1 || remaining[0] != "namespace" {
+		t.Errorf("expected only the existing namespace to be left, got %v", remaining)
+	}
+}
+
+func TestEnsureRolesResetsRules(t *testing.T) {
+	modified := buildRole(testNamespaceModel(), RoleProfileViewer, []rbacv1.PolicyRule{{
+		APIGroups: []string{"*"},
+		Resources: []string{"*"},
+		Verbs:     []string{"*"},
+	}})
+
+	c, _ := newTestClient(modified)
+
+	if _, err := c.ensureRoles(testNamespaceModel()); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	role, err := c.client.RbacV1().Roles(testNamespace).Get(getRoleName(testNamespace, RoleProfileViewer), metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !equality.Semantic.DeepEqual(role.Rules, BuiltinRoleProfiles()[RoleProfileViewer]) {
+		t.Errorf("expected rules to be reset, got %#v", role.Rules)
+	}
+}
+
+func TestEnsureRoleBindingReplacesRoleRef(t *testing.T) {
+	modified := buildServiceAccountRoleBinding(testNamespaceModel(), RoleProfileDeveloper)
+	modified.RoleRef.Kind = "ClusterRole"
+	modified.RoleRef.Name = "cluster-admin"
+	modified.Subjects = nil
+
+	c, _ := newTestClient(modified)
+
+	if _, err := c.ensureServiceAccountRoleBinding(testNamespaceModel(), RoleProfileDeveloper); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	binding, err := c.client.RbacV1().RoleBindings(testNamespace).Get(getRoleBindingName(testNamespace), metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	desired := buildServiceAccountRoleBinding(testNamespaceModel(), RoleProfileDeveloper)
+	if binding.RoleRef != desired.RoleRef {
+		t.Errorf("expected role ref %#v, got %#v", desired.RoleRef, binding.RoleRef)
+	}
+	if !equality.Semantic.DeepEqual(binding.Subjects, desired.Subjects) {
+		t.Errorf("expected subjects %#v, got %#v", desired.Subjects, binding.Subjects)
+	}
+}
+
+func TestDeleteIsIdempotent(t *testing.T) {
+	c, _ := newTestClient()
+
+	if err := c.CreateNamespaceWithServiceAccount(testNamespaceModel()); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := c.DeleteNamespaceWithServiceAccount(testNamespace); err != nil {
+			t.Fatalf("run %d: unexpected error: %s", i, err)
+		}
+	}
+}
+
+func TestCredentials(t *testing.T) {
+	c, _ := newTestClient()
+
+	if err := c.CreateNamespaceWithServiceAccount(testNamespaceModel()); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	token, err := c.GetToken(testNamespace, 0)
+	if err != nil {
+		t.Fatalf("unexpected error getting token: %s", err)
+	}
+	if expected := "token-" + getServiceAccountName(testNamespace); token != expected {
+		t.Errorf("expected token %q, got %q", expected, token)
+	}
+
+	certificate, err := c.GetCertificateB64(testNamespace)
+	if err != nil {
+		t.Fatalf("unexpected error getting certificate: %s", err)
+	}
+	if certificate != b64.StdEncoding.EncodeToString([]byte("certificate")) {
+		t.Errorf("unexpected certificate %q", certificate)
+	}
+
+	config, err := c.CreateConfiguration(testNamespace, 0, KubeconfigOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error creating configuration: %s", err)
+	}
+
+	for _, expected := range []string{
+		"server: " + testEndpoint,
+		"token: " + token,
+		"certificate-authority-data: " + certificate,
+		"namespace: " + testNamespace,
+	} {
+		if !strings.Contains(config, expected) {
+			t.Errorf("expected configuration to contain %q, got:\n%s", expected, config)
+		}
+	}
+}
+
+func TestCredentialsMissingServiceAccount(t *testing.T) {
+	c, _ := newTestClient()
+
+	if _, err := c.GetToken(testNamespace, 0); err == nil {
+		t.Error("expected an error for a namespace without service account")
+	}
+}
+
+func TestClusterCredentials(t *testing.T) {
+	c, _ := newTestClient()
+	c.cluster = &models.Cluster{
+		ID:                   uuid.Must(uuid.NewV4()),
+		Name:                 "staging",
+		Endpoint:             "https://staging.example.com:6443",
+		CertificateAuthority: "staging-ca",
+	}
+
+	if err := c.CreateNamespaceWithServiceAccount(testNamespaceModel()); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if !c.ClusterID().Valid || c.ClusterID().UUID != c.cluster.ID {
+		t.Errorf("expected cluster ID %s, got %v", c.cluster.ID, c.ClusterID())
+	}
+
+	config, err := c.CreateConfiguration(testNamespace, 0, KubeconfigOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error creating configuration: %s", err)
+	}
+
+	for _, expected := range []string{
+		"server: https://staging.example.com:6443",
+		"name: bork-staging",
+		"certificate-authority-data: " + b64.StdEncoding.EncodeToString([]byte("staging-ca")),
+	} {
+		if !strings.Contains(config, expected) {
+			t.Errorf("expected configuration to contain %q, got:\n%s", expected, config)
+		}
+	}
+}
+
+func TestProvisionRefusesNamespaceOfAnotherOwner(t *testing.T) {
+	orphan := testNamespaceModel()
+	c, _ := newTestClient(&corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   testNamespace,
+			Labels: managedLabels(orphan, nil),
+		},
+	})
+
+	ns := testNamespaceModel()
+	ns.ID = uuid.Must(uuid.NewV4())
+	if err := c.CreateNamespaceWithServiceAccount(ns); err == nil {
+		t.Fatal("expected a namespace of another owner to be refused")
+	}
+
+	existing, err := c.client.CoreV1().Namespaces().Get(testNamespace, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if existing.Labels[ownerLabel] != orphan.OwnerID.String() {
+		t.Errorf("expected the owner to be kept, got %s", existing.Labels[ownerLabel])
+	}
+}
+
+func TestNewClusterClientRequiresKubeconfig(t *testing.T) {
+	if _, err := NewClusterClient(&models.Cluster{Name: "staging"}); err == nil {
+		t.Error("expected a cluster without a kubeconfig to be refused")
+	}
+}
@@ -0,0 +1,230 @@
+package kube
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/kradalby/bork/models"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	kubernetesErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Service accounts and members can read the namespace object they
+// belong to, and nothing else at the cluster level. Every namespace
+// gets a cluster role limited to its own namespace by resourceNames.
+// Older versions bound everyone to the shared legacyClusterRoleName,
+// which allows all verbs on all namespaces. Syncing repairs those
+// bindings as drift.
+
+// legacyClusterRoleName is the shared cluster role of older versions
+const legacyClusterRoleName = "bork-namespaced-cr"
+
+// namespaceVerbs are the verbs a namespace cluster role grants, the
+// only ones that honour resourceNames for namespaces
+var namespaceVerbs = []string{"get", "watch"}
+
+func (c *Client) ensureClusterRole(namespace *models.Namespace) (bool, error) {
+	desired := buildClusterRole(namespace)
+	clusterRoles := c.client.RbacV1().ClusterRoles()
+
+	existing, err := clusterRoles.Get(desired.Name, metav1.GetOptions{})
+	if kubernetesErrors.IsNotFound(err) {
+		_, err = clusterRoles.Create(desired)
+		return err == nil, err
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if _, ok := existing.Labels[borkLabel]; !ok || !sameLabel(existing.Labels, namespaceLabel, desired.Labels) {
+		return false, fmt.Errorf("cluster role %s already exists and is not managed by bork for namespace %s", desired.Name, namespace.Name)
+	}
+
+	metadataChanged := updateManagedMetadata(&existing.ObjectMeta, desired.ObjectMeta)
+	if !metadataChanged && equality.Semantic.DeepEqual(existing.Rules, desired.Rules) {
+		return false, nil
+	}
+
+	existing.Rules = desired.Rules
+	_, err = clusterRoles.Update(existing)
+	return false, err
+}
+
+func (c *Client) deleteClusterRoleIfPresent(namespace string) error {
+	err := c.client.RbacV1().ClusterRoles().Delete(getClusterRoleName(namespace), &metav1.DeleteOptions{})
+	return ignoreNotFound(err)
+}
+
+// DeleteLegacyClusterRole deletes the shared cluster role of older
+// versions once no cluster role binding refers to it any more. The
+// returned bool reports if it was deleted.
+func (c *Client) DeleteLegacyClusterRole() (bool, error) {
+	bindings, err := c.client.RbacV1().ClusterRoleBindings().List(metav1.ListOptions{})
+	if err != nil {
+		return false, err
+	}
+
+	for _, binding := range bindings.Items {
+		if binding.RoleRef.Kind == "ClusterRole" && binding.RoleRef.Name == legacyClusterRoleName {
+			log.Printf("[INFO] Keeping cluster role %s, it is still bound by %s", legacyClusterRoleName, binding.Name)
+			return false, nil
+		}
+	}
+
+	err = c.client.RbacV1().ClusterRoles().Delete(legacyClusterRoleName, &metav1.DeleteOptions{})
+	if kubernetesErrors.IsNotFound(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func buildClusterRole(namespace *models.Namespace) *rbacv1.ClusterRole {
+	return &rbacv1.ClusterRole{
+		ObjectMeta: managedClusterObjectMeta(namespace, getClusterRoleName(namespace.Name), nil),
+		Rules: []rbacv1.PolicyRule{{
+			APIGroups:     []string{""},
+			Resources:     []string{"namespaces"},
+			ResourceNames: []string{namespace.Name},
+			Verbs:         namespaceVerbs,
+		}},
+	}
+}
+
+// OverBroadBinding is a cluster role binding giving a service account
+// in a bork namespace more than read access to its own namespace.
+type OverBroadBinding struct {
+	Name        string
+	Namespace   string
+	ClusterRole string
+	Reason      string
+}
+
+// FindOverBroadBindings returns the cluster role bindings of service
+// accounts in bork namespaces that grant more than get and watch on
+// the namespace of the service account.
+func (c *Client) FindOverBroadBindings() ([]OverBroadBinding, error) {
+	namespaces, err := c.getAllNamespaces()
+	if err != nil {
+		return nil, err
+	}
+
+	managed := map[string]bool{}
+	for _, ns := range namespaces.Items {
+		managed[ns.Name] = true
+	}
+
+	bindings, err := c.client.RbacV1().ClusterRoleBindings().List(metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	found := []OverBroadBinding{}
+	for _, binding := range bindings.Items {
+		for _, subject := range binding.Subjects {
+			if subject.Kind != rbacv1.ServiceAccountKind || !managed[subject.Namespace] {
+				continue
+			}
+
+			reason, err := c.clusterRoleBindingProblem(binding.RoleRef, subject.Namespace)
+			if err != nil {
+				return nil, err
+			}
+			if reason == "" {
+				continue
+			}
+
+			found = append(found, OverBroadBinding{
+				Name:        binding.Name,
+				Namespace:   subject.Namespace,
+				ClusterRole: binding.RoleRef.Name,
+				Reason:      reason,
+			})
+			break
+		}
+	}
+
+	return found, nil
+}
+
+// clusterRoleBindingProblem returns why binding the cluster role gives
+// a service account in the namespace too much access, or an empty
+// string if it does not.
+func (c *Client) clusterRoleBindingProblem(roleRef rbacv1.RoleRef, namespace string) (string, error) {
+	if roleRef.Kind != "ClusterRole" {
+		return "", nil
+	}
+	if roleRef.Name == legacyClusterRoleName {
+		return "shared cluster role of older versions", nil
+	}
+
+	clusterRole, err := c.client.RbacV1().ClusterRoles().Get(roleRef.Name, metav1.GetOptions{})
+	if kubernetesErrors.IsNotFound(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if clusterRole.AggregationRule != nil {
+		return "aggregated cluster role", nil
+	}
+
+	for _, rule := range clusterRole.Rules {
+		if reason := policyRuleProblem(rule, namespace); reason != "" {
+			return reason, nil
+		}
+	}
+	return "", nil
+}
+
+func policyRuleProblem(rule rbacv1.PolicyRule, namespace string) string {
+	if len(rule.NonResourceURLs) != 0 {
+		return "grants non-resource URLs"
+	}
+
+	for _, group := range rule.APIGroups {
+		if group != "" {
+			return fmt.Sprintf("grants API group %q", group)
+		}
+	}
+
+	for _, resource := range rule.Resources {
+		if resource != "namespaces" {
+			return fmt.Sprintf("grants resource %q", resource)
+		}
+	}
+
+	if len(rule.ResourceNames) == 0 {
+		return "grants all namespaces"
+	}
+	for _, name := range rule.ResourceNames {
+		if name != namespace {
+			return fmt.Sprintf("grants namespace %s", name)
+		}
+	}
+
+	for _, verb := range rule.Verbs {
+		if !contains(namespaceVerbs, verb) {
+			return fmt.Sprintf("grants verb %q", verb)
+		}
+	}
+
+	return ""
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+// getClusterRoleName returns the name of the cluster role of the
+// namespace.
+func getClusterRoleName(namespace string) string {
+	return namespace + "-namespace"
+}
@@ -0,0 +1,181 @@
+package kube
+
+import (
+	"testing"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func legacyClusterRoleBinding() *rbacv1.ClusterRoleBinding {
+	return &rbacv1.ClusterRoleBinding{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   getClusterRoleBindingName(testNamespace),
+			Labels: map[string]string{borkLabel: "true"},
+		},
+		Subjects: []rbacv1.Subject{{
+			Name:      getServiceAccountName(testNamespace),
+			Kind:      "ServiceAccount",
+			Namespace: testNamespace,
+		}},
+		RoleRef: rbacv1.RoleRef{
+			Kind:     "ClusterRole",
+			Name:     legacyClusterRoleName,
+			APIGroup: "rbac.authorization.k8s.io",
+		}}
+}
+
+func TestProvisionCreatesClusterRoleForNamespace(t *testing.T) {
+	c, _ := newTestClient()
+
+	if err := c.CreateNamespaceWithServiceAccount(testNamespaceModel()); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	clusterRole, err := c.client.RbacV1().ClusterRoles().Get(getClusterRoleName(testNamespace), metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(clusterRole.Rules) != 1 {
+		t.Fatalf("expected a single rule, got %#v", clusterRole.Rules)
+	}
+	if problem := policyRuleProblem(clusterRole.Rules[0], testNamespace); problem != "" {
+		t.Errorf("expected the rule to be limited to the namespace, got %s", problem)
+	}
+
+	binding, err := c.client.RbacV1().ClusterRoleBindings().Get(getClusterRoleBindingName(testNamespace), metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if binding.RoleRef.Name != clusterRole.Name {
+		t.Errorf("expected binding to the cluster role of the namespace, got %q", binding.RoleRef.Name)
+	}
+}
+
+func TestRepairDriftMigratesLegacyBindings(t *testing.T) {
+	c, _ := newTestClient()
+
+	ns := testNamespaceModel()
+	if err := c.CreateNamespaceWithServiceAccount(ns); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	bindings := c.client.RbacV1().ClusterRoleBindings()
+	if err := bindings.Delete(getClusterRoleBindingName(testNamespace), &metav1.DeleteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bindings.Create(legacyClusterRoleBinding()); err != nil {
+		t.Fatal(err)
+	}
+
+	found, err := c.FindOverBroadBindings()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(found) != 1 || found[0].Name != getClusterRoleBindingName(testNamespace) {
+		t.Fatalf("expected the legacy binding to be flagged, got %#v", found)
+	}
+
+	drift, err := c.FindDrift(ns)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := c.RepairDrift(ns, drift); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	binding, err := bindings.Get(getClusterRoleBindingName(testNamespace), metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if binding.RoleRef.Name != getClusterRoleName(testNamespace) {
+		t.Errorf("expected binding to be migrated, got %q", binding.RoleRef.Name)
+	}
+
+	found, err = c.FindOverBroadBindings()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(found) != 0 {
+		t.Errorf("expected no over-broad bindings after migration, got %#v", found)
+	}
+}
+
+func TestProvisionRefusesClusterRoleNotManagedByBork(t *testing.T) {
+	c, _ := newTestClient(&rbacv1.ClusterRole{
+		ObjectMeta: metav1.ObjectMeta{Name: getClusterRoleName(testNamespace)},
+		Rules: []rbacv1.PolicyRule{{
+			APIGroups: []string{"*"},
+			Resources: []string{"*"},
+			Verbs:     []string{"*"},
+		}},
+	})
+
+	if err := c.CreateNamespaceWithServiceAccount(testNamespaceModel()); err == nil {
+		t.Fatal("expected an error for a cluster role not managed by bork")
+	}
+
+	clusterRole, err := c.client.RbacV1().ClusterRoles().Get(getClusterRoleName(testNamespace), metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(clusterRole.Rules) != 1 || clusterRole.Rules[0].Verbs[0] != "*" {
+		t.Errorf("expected the cluster role to be left alone, got %#v", clusterRole.Rules)
+	}
+}
+
+func TestDeleteLegacyClusterRole(t *testing.T) {
+	c, _ := newTestClient(
+		&rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: legacyClusterRoleName}},
+		legacyClusterRoleBinding(),
+	)
+
+	deleted, err := c.DeleteLegacyClusterRole()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if deleted {
+		t.Fatal("expected the cluster role to be kept while it is bound")
+	}
+
+	bindings := c.client.RbacV1().ClusterRoleBindings()
+	if err := bindings.Delete(getClusterRoleBindingName(testNamespace), &metav1.DeleteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	deleted, err = c.DeleteLegacyClusterRole()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !deleted {
+		t.Error("expected the unbound cluster role to be deleted")
+	}
+
+	deleted, err = c.DeleteLegacyClusterRole()
+	if err != nil || deleted {
+		t.Errorf("expected nothing to delete, got %t and %v", deleted, err)
+	}
+}
+
+func TestPolicyRuleProblem(t *testing.T) {
+	tests := []struct {
+		name    string
+		rule    rbacv1.PolicyRule
+		problem bool
+	}{
+		{"own namespace", rbacv1.PolicyRule{APIGroups: []string{""}, Resources: []string{"namespaces"}, ResourceNames: []string{testNamespace}, Verbs: []string{"get", "watch"}}, false},
+		{"all namespaces", rbacv1.PolicyRule{APIGroups: []string{""}, Resources: []string{"namespaces"}, Verbs: []string{"get"}}, true},
+		{"other namespace", rbacv1.PolicyRule{APIGroups: []string{""}, Resources: []string{"namespaces"}, ResourceNames: []string{"other"}, Verbs: []string{"get"}}, true},
+		{"all verbs", rbacv1.PolicyRule{APIGroups: []string{""}, Resources: []string{"namespaces"}, ResourceNames: []string{testNamespace}, Verbs: []string{"*"}}, true},
+		{"delete", rbacv1.PolicyRule{APIGroups: []string{""}, Resources: []string{"namespaces"}, ResourceNames: []string{testNamespace}, Verbs: []string{"delete"}}, true},
+		{"all resources", rbacv1.PolicyRule{APIGroups: []string{""}, Resources: []string{"*"}, ResourceNames: []string{testNamespace}, Verbs: []string{"get"}}, true},
+		{"other group", rbacv1.PolicyRule{APIGroups: []string{"*"}, Resources: []string{"namespaces"}, ResourceNames: []string{testNamespace}, Verbs: []string{"get"}}, true},
+		{"non-resource", rbacv1.PolicyRule{NonResourceURLs: []string{"/metrics"}, Verbs: []string{"get"}}, true},
+	}
+
+	for _, tt := range tests {
+		if problem := policyRuleProblem(tt.rule, testNamespace); (problem != "") != tt.problem {
+			t.Errorf("%s: expected problem %t, got %q", tt.name, tt.problem, problem)
+		}
+	}
+}
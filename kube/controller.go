@@ -0,0 +1,254 @@
+package kube
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/kradalby/bork/models"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+)
+
+// The controller keeps the bork namespaces of a cluster in line with
+// their database rows. It watches the namespaces and the objects bork
+// creates for them through shared informers, and reconciles a namespace
+// when one of them changes and every resync interval. Namespaces in the
+// cluster but not in the database are left to `bork sync namespace`.
+
+// reconcileDelay coalesces the burst of events caused by provisioning
+// or deleting a namespace into a single reconcile
+const reconcileDelay = 5 * time.Second
+
+// ReconcileInterval returns how often the controller reconciles all
+// namespaces, set by BORK_RECONCILE_INTERVAL. Zero disables the
+// controller.
+func ReconcileInterval() time.Duration {
+	return durationFromEnv("BORK_RECONCILE_INTERVAL", 10*time.Minute)
+}
+
+// Controller reconciles the bork namespaces of a cluster.
+type Controller struct {
+	client  *Client
+	factory informers.SharedInformerFactory
+	queue   workqueue.RateLimitingInterface
+	resync  time.Duration
+	delay   time.Duration
+
+	namespaces cache.GenericLister
+
+	// database returns the rows of the namespaces of the cluster, find
+	// returns the row of a single one or nil, and record stores the
+	// result of reconciling it. Tests replace them.
+	database func() (models.Namespaces, error)
+	find     func(name string) (*models.Namespace, error)
+	record   func(namespace *models.Namespace, status string, err error) error
+}
+
+// NewController returns a controller for the cluster of the client,
+// reconciling every namespace each resync interval.
+func NewController(client *Client, resync time.Duration) *Controller {
+	factory := informers.NewSharedInformerFactoryWithOptions(client.client, resync,
+		informers.WithTweakListOptions(func(options *metav1.ListOptions) {
+			options.LabelSelector = borkLabel
+		}))
+
+	controller := &Controller{
+		client:   client,
+		factory:  factory,
+		queue:    workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "bork"),
+		resync:   resync,
+		delay:    reconcileDelay,
+		database: client.getNamespacesFromDatabase,
+		find:     client.getNamespaceFromDatabase,
+		record: func(namespace *models.Namespace, status string, err error) error {
+			return namespace.SetReconcileStatus(models.DB, status, err)
+		},
+	}
+
+	handler := cache.ResourceEventHandlerFuncs{
+		AddFunc: controller.enqueueObject,
+		UpdateFunc: func(old, obj interface{}) {
+			if objectChanged(old, obj) {
+				controller.enqueueObject(obj)
+			}
+		},
+		DeleteFunc: controller.enqueueObject,
+	}
+
+	namespaces := factory.Core().V1().Namespaces()
+	controller.namespaces = cache.NewGenericLister(namespaces.Informer().GetIndexer(), corev1.Resource("namespaces"))
+
+	for _, informer := range []cache.SharedIndexInformer{
+		namespaces.Informer(),
+		factory.Core().V1().ResourceQuotas().Informer(),
+		factory.Core().V1().LimitRanges().Informer(),
+		factory.Core().V1().ServiceAccounts().Informer(),
+		factory.Networking().V1().NetworkPolicies().Informer(),
+		factory.Rbac().V1().Roles().Informer(),
+		factory.Rbac().V1().RoleBindings().Informer(),
+		factory.Rbac().V1().ClusterRoles().Informer(),
+		factory.Rbac().V1().ClusterRoleBindings().Informer(),
+	} {
+		informer.AddEventHandler(handler)
+	}
+
+	return controller
+}
+
+// Run starts the informers and reconciles namespaces until stop is
+// closed.
+func (c *Controller) Run(stop <-chan struct{}) error {
+	defer c.queue.ShutDown()
+
+	c.factory.Start(stop)
+	for informerType, synced := range c.factory.WaitForCacheSync(stop) {
+		if !synced {
+			return fmt.Errorf("waiting for %s informer to sync", informerType)
+		}
+	}
+
+	// The informers only see namespaces that exist in the cluster, rows
+	// without one are queued from the database
+	go wait.Until(c.enqueueDatabase, c.resync, stop)
+	go wait.Until(c.runWorker, time.Second, stop)
+
+	<-stop
+	return nil
+}
+
+// enqueueObject queues the namespace an object belongs to.
+func (c *Controller) enqueueObject(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+
+	object, err := meta.Accessor(obj)
+	if err != nil {
+		log.Printf("[Error] %#v", err)
+		return
+	}
+
+	name := object.GetLabels()[namespaceLabel]
+	if _, ok := obj.(*corev1.Namespace); ok {
+		name = object.GetName()
+	}
+	if name == "" {
+		name = object.GetNamespace()
+	}
+	if name == "" {
+		return
+	}
+
+	c.queue.AddAfter(name, c.delay)
+}
+
+// objectChanged reports if an update changed more than the status of
+// an object. Resyncs of the informers deliver updates without a new
+// resource version, and the quota controller updates the status of a
+// resource quota on every pod change. Reconciling those would rewrite
+// busy namespaces over and over, the database resync covers them.
+func objectChanged(old, obj interface{}) bool {
+	oldObject, err := meta.Accessor(old)
+	if err != nil {
+		return true
+	}
+	object, err := meta.Accessor(obj)
+	if err != nil {
+		return true
+	}
+
+	if oldObject.GetResourceVersion() == object.GetResourceVersion() {
+		return false
+	}
+
+	if quota, ok := obj.(*corev1.ResourceQuota); ok {
+		oldQuota, ok := old.(*corev1.ResourceQuota)
+		return !ok ||
+			!equality.Semantic.DeepEqual(oldQuota.Spec, quota.Spec) ||
+			!equality.Semantic.DeepEqual(oldQuota.Labels, quota.Labels) ||
+			!equality.Semantic.DeepEqual(oldQuota.Annotations, quota.Annotations)
+	}
+
+	return true
+}
+
+func (c *Controller) enqueueDatabase() {
+	namespaces, err := c.database()
+	if err != nil {
+		log.Printf("[Error] %#v", err)
+		return
+	}
+
+	for _, ns := range namespaces {
+		c.queue.Add(ns.Name)
+	}
+}
+
+func (c *Controller) runWorker() {
+	for c.processNextItem() {
+	}
+}
+
+func (c *Controller) processNextItem() bool {
+	key, quit := c.queue.Get()
+	if quit {
+		return false
+	}
+	defer c.queue.Done(key)
+
+	if err := c.reconcile(key.(string)); err != nil {
+		log.Printf("[Error] Reconciling %s failed: %s", key, err)
+		c.queue.AddRateLimited(key)
+		return true
+	}
+
+	c.queue.Forget(key)
+	return true
+}
+
+// reconcile applies the database row of the namespace to the cluster
+// and records the result on the row.
+func (c *Controller) reconcile(name string) error {
+	namespace, err := c.find(name)
+	if err != nil {
+		return err
+	}
+	if namespace == nil {
+		log.Printf("[INFO] Namespace %s is not in the database, not reconciling", name)
+		return nil
+	}
+	if namespace.ReconcileStatus == models.ReconcileDeleting || c.isTerminating(name) {
+		return nil
+	}
+
+	reconcileErr := c.client.ApplyNamespace(namespace)
+
+	status := models.ReconcileSynced
+	if reconcileErr != nil {
+		status = models.ReconcileFailed
+	}
+	if err := c.record(namespace, status, reconcileErr); err != nil {
+		return err
+	}
+
+	return reconcileErr
+}
+
+// isTerminating reports if the namespace is being removed from the
+// cluster, objects can not be created in it anymore.
+func (c *Controller) isTerminating(name string) bool {
+	obj, err := c.namespaces.Get(name)
+	if err != nil {
+		return false
+	}
+
+	namespace, ok := obj.(*corev1.Namespace)
+	return ok && namespace.DeletionTimestamp != nil
+}
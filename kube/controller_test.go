@@ -0,0 +1,173 @@
+package kube
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kradalby/bork/models"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// testStatus records the reconcile results of a test controller.
+type testStatus struct {
+	sync.Mutex
+	status string
+	err    error
+}
+
+func (s *testStatus) get() (string, error) {
+	s.Lock()
+	defer s.Unlock()
+	return s.status, s.err
+}
+
+func newTestController(c *Client, ns *models.Namespace) (*Controller, *testStatus) {
+	status := &testStatus{}
+
+	controller := NewController(c, time.Hour)
+	controller.delay = 0
+	controller.database = func() (models.Namespaces, error) { return models.Namespaces{*ns}, nil }
+	controller.find = func(name string) (*models.Namespace, error) {
+		if name != ns.Name {
+			return nil, nil
+		}
+		return ns, nil
+	}
+	controller.record = func(namespace *models.Namespace, s string, err error) error {
+		status.Lock()
+		defer status.Unlock()
+		status.status, status.err = s, err
+		return nil
+	}
+	return controller, status
+}
+
+func TestControllerReconcileRecordsStatus(t *testing.T) {
+	c, clientset := newTestClient()
+	ns := testNamespaceModel()
+	controller, status := newTestController(c, ns)
+
+	failOn(clientset, "create", "roles")
+	if err := controller.reconcile(testNamespace); err == nil {
+		t.Fatal("expected reconcile to fail")
+	}
+	if s, err := status.get(); s != models.ReconcileFailed || err == nil {
+		t.Errorf("expected failed status with error, got %q %v", s, err)
+	}
+
+	c, _ = newTestClient()
+	controller, status = newTestController(c, ns)
+	if err := controller.reconcile(testNamespace); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if s, err := status.get(); s != models.ReconcileSynced || err != nil {
+		t.Errorf("expected synced status, got %q %v", s, err)
+	}
+	if remaining := remainingObjects(t, c, testNamespace); len(remaining) != 9 {
+		t.Errorf("expected the namespace to be provisioned, got %v", remaining)
+	}
+}
+
+func TestControllerSkipsNamespacesBeingDeleted(t *testing.T) {
+	c, _ := newTestClient()
+	ns := testNamespaceModel()
+	ns.ReconcileStatus = models.ReconcileDeleting
+	controller, status := newTestController(c, ns)
+
+	if err := controller.reconcile(testNamespace); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if remaining := remainingObjects(t, c, testNamespace); len(remaining) != 0 {
+		t.Errorf("expected nothing to be created, got %v", remaining)
+	}
+	if s, _ := status.get(); s != "" {
+		t.Errorf("expected no status to be recorded, got %q", s)
+	}
+
+	if err := controller.reconcile("bork-not-in-database"); err != nil {
+		t.Errorf("expected namespaces missing from the database to be skipped, got %s", err)
+	}
+}
+
+func TestControllerRepairsDeletedObjects(t *testing.T) {
+	c, _ := newTestClient()
+	ns := testNamespaceModel()
+	if err := c.CreateNamespaceWithServiceAccount(ns); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	controller, status := newTestController(c, ns)
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		if err := controller.Run(stop); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	err := wait.Poll(10*time.Millisecond, 5*time.Second, func() (bool, error) {
+		s, _ := status.get()
+		return s == models.ReconcileSynced, nil
+	})
+	if err != nil {
+		t.Fatal("expected the namespace to be reconciled on start")
+	}
+
+	err = c.client.RbacV1().ClusterRoleBindings().Delete(getClusterRoleBindingName(testNamespace), &metav1.DeleteOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = wait.Poll(10*time.Millisecond, 5*time.Second, func() (bool, error) {
+		_, err := c.client.RbacV1().ClusterRoleBindings().Get(getClusterRoleBindingName(testNamespace), metav1.GetOptions{})
+		return err == nil, nil
+	})
+	if err != nil {
+		t.Error("expected the deleted cluster role binding to be recreated")
+	}
+}
+
+func TestObjectChanged(t *testing.T) {
+	quota := &corev1.ResourceQuota{
+		ObjectMeta: metav1.ObjectMeta{Name: "quota", ResourceVersion: "1"},
+		Spec: corev1.ResourceQuotaSpec{
+			Hard: corev1.ResourceList{corev1.ResourcePods: resource.MustParse("20")},
+		},
+	}
+
+	if objectChanged(quota, quota.DeepCopy()) {
+		t.Error("expected a resync without a new resource version to be skipped")
+	}
+
+	status := quota.DeepCopy()
+	status.ResourceVersion = "2"
+	status.Status.Used = corev1.ResourceList{corev1.ResourcePods: resource.MustParse("3")}
+	if objectChanged(quota, status) {
+		t.Error("expected a status update of a resource quota to be skipped")
+	}
+
+	spec := quota.DeepCopy()
+	spec.ResourceVersion = "2"
+	spec.Spec.Hard = corev1.ResourceList{corev1.ResourcePods: resource.MustParse("40")}
+	if !objectChanged(quota, spec) {
+		t.Error("expected a spec change of a resource quota to be reconciled")
+	}
+
+	annotations := quota.DeepCopy()
+	annotations.ResourceVersion = "2"
+	annotations.Annotations = map[string]string{coOwnersAnnotation: ""}
+	if !objectChanged(quota, annotations) {
+		t.Error("expected a metadata change of a resource quota to be reconciled")
+	}
+
+	serviceAccount := &corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: "sa", ResourceVersion: "1"}}
+	updated := serviceAccount.DeepCopy()
+	updated.ResourceVersion = "2"
+	if !objectChanged(serviceAccount, updated) {
+		t.Error("expected an update of another object to be reconciled")
+	}
+}
@@ -0,0 +1,330 @@
+package kube
+
+import (
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/kradalby/bork/models"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// Deleting a namespace first puts it in pending deletion for a grace
+// period. Its resource quota allows no pods and its pods are deleted,
+// so nothing runs whatever the workloads or autoscalers ask for, and
+// the quota is reconciled like any other. Deployments and stateful
+// sets are scaled to zero and cron jobs suspended as well, the replica
+// count they had is kept in an annotation so restoring the namespace
+// can scale them back up. Bare pods are gone for good. A background
+// deleter reaps expired namespaces and removes the namespaces whose
+// grace period has passed.
+
+const (
+	// replicasAnnotation holds the replicas of a workload before it was
+	// scaled to zero
+	replicasAnnotation = "bork.replicas"
+	// suspendAnnotation holds if a cron job was suspended before bork
+	// suspended it
+	suspendAnnotation = "bork.suspend"
+)
+
+// DeletionGracePeriod returns how long a deleted namespace can be
+// restored, set by BORK_DELETION_GRACE_PERIOD. Zero deletes namespaces
+// right away.
+func DeletionGracePeriod() time.Duration {
+	return durationFromEnv("BORK_DELETION_GRACE_PERIOD", 72*time.Hour)
+}
+
+// DeletionInterval returns how often the deleter looks for namespaces
+// whose grace period has passed, set by BORK_DELETION_INTERVAL.
+func DeletionInterval() time.Duration {
+	return durationFromEnv("BORK_DELETION_INTERVAL", 5*time.Minute)
+}
+
+// ScheduleNamespaceDeletion puts the namespace in pending deletion,
+// stops its pods and scales its workloads to zero. Without a grace
+// period the namespace is deleted right away.
+func (c *Client) ScheduleNamespaceDeletion(namespace *models.Namespace) error {
+	if namespace.Locked {
+		return ErrNamespaceLocked
+	}
+	if err := c.checkNotLockedInCluster(namespace.Name); err != nil {
+		return err
+	}
+
+	grace := DeletionGracePeriod()
+	if grace <= 0 {
+		return c.DeleteNamespace(namespace.ID)
+	}
+
+	// Marked first, a namespace that failed to scale down is still
+	// deleted in the end
+	if err := namespace.ScheduleDeletion(models.DB, time.Now().Add(grace)); err != nil {
+		return err
+	}
+
+	if err := c.ApplyQuota(namespace); err != nil {
+		return err
+	}
+
+	if err := c.scaleDownWorkloads(namespace.Name); err != nil {
+		return err
+	}
+
+	return c.deletePods(namespace.Name)
+}
+
+// RestoreNamespace takes the namespace out of pending deletion for the
+// user, gives it its quota back and scales its workloads back up. An
+// expired namespace gets a new expiry, see restoreExpiry. The owner must
+// be loaded on the namespace.
+func (c *Client) RestoreNamespace(namespace *models.Namespace, user models.User) error {
+	now := time.Now()
+	if namespace.Expired(now) {
+		expiresAt, err := restoreExpiry(namespace, user, now)
+		if err != nil {
+			return err
+		}
+		if err := namespace.SetExpiry(models.DB, expiresAt); err != nil {
+			return err
+		}
+	}
+
+	// Cancelled first, the deleter must not remove a namespace that is
+	// being scaled back up
+	if err := namespace.CancelDeletion(models.DB); err != nil {
+		return err
+	}
+
+	if err := c.ApplyQuota(namespace); err != nil {
+		return err
+	}
+
+	return c.scaleUpWorkloads(namespace.Name)
+}
+
+// DeletePendingNamespaces deletes the namespaces of the cluster whose
+// grace period has passed, unless they were locked in the meantime, and
+// audits it. Namespaces locked in the cluster only get their row locked
+// instead of a failed deletion audited on every run. A namespace failing
+// to delete does not stop the others.
+func (c *Client) DeletePendingNamespaces(now time.Time) error {
+	namespaces := models.Namespaces{}
+
+	query := models.DB.Where("cluster_id IS NULL AND delete_at <= ? AND NOT locked", now)
+	if c.cluster != nil {
+		query = models.DB.Where("cluster_id = ? AND delete_at <= ? AND NOT locked", c.cluster.ID, now)
+	}
+	if err := query.All(&namespaces); err != nil {
+		return err
+	}
+
+	for i := range namespaces {
+		namespace := &namespaces[i]
+		locked, err := c.lockFromCluster(namespace)
+		if err != nil {
+			log.Printf("[Error] %#v", err)
+			continue
+		}
+		if locked {
+			continue
+		}
+
+		log.Printf("[INFO] Deleting namespace %s, its grace period has passed", namespace.Name)
+		err = c.DeleteNamespace(namespace.ID)
+		if err != nil {
+			log.Printf("[Error] %#v", err)
+		}
+		recordSystemAudit("DeletePendingNamespaces", namespace, "grace period ended at "+namespace.DeleteAt.Time.Format(time.RFC3339), err)
+	}
+	return nil
+}
+
+// RunDeleter reaps the expired namespaces and deletes the namespaces
+// whose grace period has passed every interval until stop is closed.
+func (c *Client) RunDeleter(stop <-chan struct{}, interval time.Duration) {
+	wait.Until(func() {
+		if err := c.ReapExpiredNamespaces(time.Now()); err != nil {
+			log.Printf("[Error] %#v", err)
+		}
+		if err := c.DeletePendingNamespaces(time.Now()); err != nil {
+			log.Printf("[Error] %#v", err)
+		}
+	}, interval, stop)
+}
+
+// scaleDownWorkloads scales the deployments and stateful sets of the
+// namespace to zero and suspends its cron jobs. Workloads already scaled
+// down by bork are skipped, so it can be run again.
+func (c *Client) scaleDownWorkloads(namespace string) error {
+	deployments := c.client.AppsV1().Deployments(namespace)
+	deploymentList, err := deployments.List(metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	for i := range deploymentList.Items {
+		deployment := &deploymentList.Items[i]
+		if !scaleDown(&deployment.ObjectMeta, &deployment.Spec.Replicas) {
+			continue
+		}
+		if _, err := deployments.Update(deployment); err != nil {
+			return err
+		}
+	}
+
+	statefulSets := c.client.AppsV1().StatefulSets(namespace)
+	statefulSetList, err := statefulSets.List(metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	for i := range statefulSetList.Items {
+		statefulSet := &statefulSetList.Items[i]
+		if !scaleDown(&statefulSet.ObjectMeta, &statefulSet.Spec.Replicas) {
+			continue
+		}
+		if _, err := statefulSets.Update(statefulSet); err != nil {
+			return err
+		}
+	}
+
+	cronJobs := c.client.BatchV1beta1().CronJobs(namespace)
+	cronJobList, err := cronJobs.List(metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	for i := range cronJobList.Items {
+		cronJob := &cronJobList.Items[i]
+		if _, ok := cronJob.Annotations[suspendAnnotation]; ok {
+			continue
+		}
+
+		suspended := cronJob.Spec.Suspend != nil && *cronJob.Spec.Suspend
+		setAnnotation(&cronJob.ObjectMeta, suspendAnnotation, strconv.FormatBool(suspended))
+		suspend := true
+		cronJob.Spec.Suspend = &suspend
+		if _, err := cronJobs.Update(cronJob); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// deletePods deletes every pod of the namespace, the quota keeps them
+// from coming back.
+func (c *Client) deletePods(namespace string) error {
+	pods := c.client.CoreV1().Pods(namespace)
+	podList, err := pods.List(metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	for _, pod := range podList.Items {
+		if err := ignoreNotFound(pods.Delete(pod.Name, &metav1.DeleteOptions{})); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// scaleUpWorkloads brings the workloads scaled down by
+// scaleDownWorkloads back to how they were.
+func (c *Client) scaleUpWorkloads(namespace string) error {
+	deployments := c.client.AppsV1().Deployments(namespace)
+	deploymentList, err := deployments.List(metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	for i := range deploymentList.Items {
+		deployment := &deploymentList.Items[i]
+		if !scaleUp(&deployment.ObjectMeta, &deployment.Spec.Replicas) {
+			continue
+		}
+		if _, err := deployments.Update(deployment); err != nil {
+			return err
+		}
+	}
+
+	statefulSets := c.client.AppsV1().StatefulSets(namespace)
+	statefulSetList, err := statefulSets.List(metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	for i := range statefulSetList.Items {
+		statefulSet := &statefulSetList.Items[i]
+		if !scaleUp(&statefulSet.ObjectMeta, &statefulSet.Spec.Replicas) {
+			continue
+		}
+		if _, err := statefulSets.Update(statefulSet); err != nil {
+			return err
+		}
+	}
+
+	cronJobs := c.client.BatchV1beta1().CronJobs(namespace)
+	cronJobList, err := cronJobs.List(metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	for i := range cronJobList.Items {
+		cronJob := &cronJobList.Items[i]
+		value, ok := cronJob.Annotations[suspendAnnotation]
+		if !ok {
+			continue
+		}
+
+		suspend, _ := strconv.ParseBool(value)
+		cronJob.Spec.Suspend = &suspend
+		delete(cronJob.Annotations, suspendAnnotation)
+		if _, err := cronJobs.Update(cronJob); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// scaleDown records the replicas of a workload and sets them to zero.
+// It reports if the workload changed.
+func scaleDown(meta *metav1.ObjectMeta, replicas **int32) bool {
+	if _, ok := meta.Annotations[replicasAnnotation]; ok {
+		return false
+	}
+
+	// Kubernetes defaults unset replicas to one
+	current := int32(1)
+	if *replicas != nil {
+		current = **replicas
+	}
+
+	setAnnotation(meta, replicasAnnotation, strconv.Itoa(int(current)))
+	zero := int32(0)
+	*replicas = &zero
+	return true
+}
+
+// scaleUp restores the replicas recorded by scaleDown. It reports if the
+// workload changed.
+func scaleUp(meta *metav1.ObjectMeta, replicas **int32) bool {
+	value, ok := meta.Annotations[replicasAnnotation]
+	if !ok {
+		return false
+	}
+
+	count, err := strconv.Atoi(value)
+	if err != nil {
+		log.Printf("[Error] Invalid %s annotation on %s: %#v", replicasAnnotation, meta.Name, err)
+		count = 1
+	}
+
+	restored := int32(count)
+	*replicas = &restored
+	delete(meta.Annotations, replicasAnnotation)
+	return true
+}
+
+func setAnnotation(meta *metav1.ObjectMeta, key string, value string) {
+	if meta.Annotations == nil {
+		meta.Annotations = map[string]string{}
+	}
+	meta.Annotations[key] = value
+}
@@ -0,0 +1,135 @@
+package kube
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gobuffalo/pop/nulls"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1beta1 "k8s.io/api/batch/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func int32Pointer(i int32) *int32 {
+	return &i
+}
+
+func boolPointer(b bool) *bool {
+	return &b
+}
+
+func TestScaleWorkloadsDownAndUp(t *testing.T) {
+	c, _ := newTestClient(
+		&appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: testNamespace},
+			Spec:       appsv1.DeploymentSpec{Replicas: int32Pointer(3)},
+		},
+		&appsv1.StatefulSet{
+			ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: testNamespace},
+		},
+		&batchv1beta1.CronJob{
+			ObjectMeta: metav1.ObjectMeta{Name: "paused", Namespace: testNamespace},
+			Spec:       batchv1beta1.CronJobSpec{Suspend: boolPointer(true)},
+		},
+		&batchv1beta1.CronJob{
+			ObjectMeta: metav1.ObjectMeta{Name: "backup", Namespace: testNamespace},
+		},
+	)
+	apps := c.client.AppsV1()
+	cronJobs := c.client.BatchV1beta1().CronJobs(testNamespace)
+
+	// Scaling down twice must not lose the replicas
+	for i := 0; i < 2; i++ {
+		if err := c.scaleDownWorkloads(testNamespace); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+
+	deployment, _ := apps.Deployments(testNamespace).Get("web", metav1.GetOptions{})
+	statefulSet, _ := apps.StatefulSets(testNamespace).Get("db", metav1.GetOptions{})
+	if *deployment.Spec.Replicas != 0 || *statefulSet.Spec.Replicas != 0 {
+		t.Errorf("expected the workloads to be scaled to zero, got %d and %d", *deployment.Spec.Replicas, *statefulSet.Spec.Replicas)
+	}
+	for _, name := range []string{"paused", "backup"} {
+		cronJob, _ := cronJobs.Get(name, metav1.GetOptions{})
+		if cronJob.Spec.Suspend == nil || !*cronJob.Spec.Suspend {
+			t.Errorf("expected cron job %s to be suspended", name)
+		}
+	}
+
+	if err := c.scaleUpWorkloads(testNamespace); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	deployment, _ = apps.Deployments(testNamespace).Get("web", metav1.GetOptions{})
+	statefulSet, _ = apps.StatefulSets(testNamespace).Get("db", metav1.GetOptions{})
+	if *deployment.Spec.Replicas != 3 || *statefulSet.Spec.Replicas != 1 {
+		t.Errorf("expected the replicas to be restored, got %d and %d", *deployment.Spec.Replicas, *statefulSet.Spec.Replicas)
+	}
+	if _, ok := deployment.Annotations[replicasAnnotation]; ok {
+		t.Error("expected the replicas annotation to be removed")
+	}
+
+	paused, _ := cronJobs.Get("paused", metav1.GetOptions{})
+	backup, _ := cronJobs.Get("backup", metav1.GetOptions{})
+	if !*paused.Spec.Suspend || *backup.Spec.Suspend {
+		t.Errorf("expected the cron jobs to be suspended as before, got %t and %t", *paused.Spec.Suspend, *backup.Spec.Suspend)
+	}
+}
+
+func TestPendingDeletionStopsPods(t *testing.T) {
+	c, _ := newTestClient(
+		&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "web-1", Namespace: testNamespace}},
+		&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "node-agent", Namespace: testNamespace}},
+	)
+
+	ns := testNamespaceModel()
+	if err := c.CreateNamespaceWithServiceAccount(ns); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	ns.DeleteAt = nulls.NewTime(time.Now().Add(time.Hour))
+	if err := c.ApplyQuota(ns); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := c.deletePods(testNamespace); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	quota, err := c.client.CoreV1().ResourceQuotas(testNamespace).Get(getResourceQuotaName(testNamespace), metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pods := quota.Spec.Hard[corev1.ResourcePods]; !pods.IsZero() {
+		t.Errorf("expected the quota to allow no pods, got %s", pods.String())
+	}
+
+	pods, err := c.client.CoreV1().Pods(testNamespace).List(metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pods.Items) != 0 {
+		t.Errorf("expected the pods to be deleted, got %d", len(pods.Items))
+	}
+
+	drift, err := c.FindDrift(ns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(drift) != 0 {
+		t.Errorf("expected the suspended quota not to be drift, got %v", drift)
+	}
+
+	ns.DeleteAt = nulls.Time{}
+	if err := c.ApplyQuota(ns); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	quota, err = c.client.CoreV1().ResourceQuotas(testNamespace).Get(getResourceQuotaName(testNamespace), metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pods := quota.Spec.Hard[corev1.ResourcePods]; pods.String() != DefaultQuota().Pods {
+		t.Errorf("expected the pods of the quota to be restored, got %s", pods.String())
+	}
+}
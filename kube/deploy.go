@@ -0,0 +1,143 @@
+package kube
+
+import (
+	"time"
+
+	"github.com/gobuffalo/envy"
+	"github.com/kradalby/bork/models"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Every namespace gets a deploy service account for CI pipelines. It is
+// bound to the role of the deploy profile, which by default can roll
+// out workloads and manage configmaps but cannot read secrets. The
+// profile can be changed like any other with "bork set profile".
+
+// RoleProfileDeploy is the built-in profile of the deploy service
+// account
+const RoleProfileDeploy = "deploy"
+
+const deployLabel = "bork.deploy"
+
+// DeployRoleProfile returns the profile the deploy service account is
+// bound to.
+func DeployRoleProfile() string {
+	return envy.Get("BORK_DEPLOY_ROLE_PROFILE", RoleProfileDeploy)
+}
+
+func deployRules() []rbacv1.PolicyRule {
+	read := []string{"get", "list", "watch"}
+	return []rbacv1.PolicyRule{
+		{
+			APIGroups: []string{"apps", "extensions"},
+			Resources: []string{"deployments", "statefulsets", "daemonsets", "replicasets"},
+			Verbs:     []string{"get", "list", "watch", "create", "update", "patch"},
+		},
+		{
+			APIGroups: []string{""},
+			Resources: []string{"configmaps"},
+			Verbs:     []string{"*"},
+		},
+		{
+			APIGroups: []string{""},
+			Resources: []string{"pods", "pods/log", "services", "events"},
+			Verbs:     read,
+		},
+		{
+			APIGroups: []string{"batch"},
+			Resources: []string{"jobs", "cronjobs"},
+			Verbs:     []string{"get", "list", "watch", "create", "update", "patch", "delete"},
+		},
+	}
+}
+
+// GetDeployToken returns a token of the deploy service account, see
+// GetToken.
+func (c *Client) GetDeployToken(namespace string, ttl time.Duration) (string, error) {
+	return c.serviceAccountToken(namespace, getDeployServiceAccountName(namespace), ttl)
+}
+
+// GetDeployCertificate returns the CA of the API server as handed to
+// the deploy service account.
+func (c *Client) GetDeployCertificate(namespace string) (string, error) {
+	return c.getServiceAccountCertificate(namespace, getDeployServiceAccountName(namespace))
+}
+
+// CreateDeployConfiguration returns a kubeconfig using a token of the
+// deploy service account.
+func (c *Client) CreateDeployConfiguration(namespace string, ttl time.Duration, options KubeconfigOptions) (string, error) {
+	token, err := c.GetDeployToken(namespace, ttl)
+	if err != nil {
+		return "", err
+	}
+
+	return c.createConfiguration(namespace, tokenCredentials(getDeployServiceAccountName(namespace), token), options)
+}
+
+// RotateDeployToken issues a new token for the deploy service account
+// and revokes the old ones.
+func (c *Client) RotateDeployToken(namespace string) (string, error) {
+	return c.rotateServiceAccountToken(namespace, getDeployServiceAccountName(namespace))
+}
+
+func (c *Client) deployStep(namespace *models.Namespace) provisionStep {
+	return provisionStep{
+		name:     "deploy service account",
+		create:   func() (bool, error) { return c.ensureDeployServiceAccount(namespace) },
+		rollback: func() error { return c.deleteDeployServiceAccountIfPresent(namespace.Name) },
+	}
+}
+
+// ensureDeployServiceAccount creates the deploy service account and its
+// role binding. It reports if the service account had to be created, a
+// failure after that is reported as created so the rollback removes all
+// of it.
+func (c *Client) ensureDeployServiceAccount(namespace *models.Namespace) (bool, error) {
+	created, err := c.ensureNamedServiceAccount(namespace, getDeployServiceAccountName(namespace.Name), deployLabels())
+	if err != nil {
+		return created, err
+	}
+
+	_, err = c.ensureRoleBinding(buildDeployRoleBinding(namespace))
+	return created, err
+}
+
+func (c *Client) deleteDeployServiceAccountIfPresent(namespace string) error {
+	err := c.client.RbacV1().RoleBindings(namespace).Delete(getDeployRoleBindingName(namespace), &metav1.DeleteOptions{})
+	if ignoreNotFound(err) != nil {
+		return err
+	}
+
+	err = c.client.CoreV1().ServiceAccounts(namespace).Delete(getDeployServiceAccountName(namespace), &metav1.DeleteOptions{})
+	return ignoreNotFound(err)
+}
+
+func buildDeployRoleBinding(namespace *models.Namespace) *rbacv1.RoleBinding {
+	return &rbacv1.RoleBinding{
+		ObjectMeta: managedObjectMeta(namespace, getDeployRoleBindingName(namespace.Name), deployLabels()),
+		Subjects: []rbacv1.Subject{{
+			Name:      getDeployServiceAccountName(namespace.Name),
+			Kind:      "ServiceAccount",
+			Namespace: namespace.Name,
+		}},
+		RoleRef: rbacv1.RoleRef{
+			Kind:     "Role",
+			Name:     getRoleName(namespace.Name, DeployRoleProfile()),
+			APIGroup: "rbac.authorization.k8s.io",
+		}}
+}
+
+func deployLabels() map[string]string {
+	return map[string]string{
+		deployLabel: "true",
+	}
+}
+
+func getDeployServiceAccountName(namespace string) string {
+	return namespace + "-deploy"
+}
+
+func getDeployRoleBindingName(namespace string) string {
+	return namespace + "-deploy"
+}
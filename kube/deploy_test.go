@@ -0,0 +1,89 @@
+package kube
+
+import (
+	"strings"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestProvisionCreatesDeployServiceAccount(t *testing.T) {
+	c, _ := newTestClient()
+
+	if err := c.CreateNamespaceWithServiceAccount(testNamespaceModel()); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	_, err := c.client.CoreV1().ServiceAccounts(testNamespace).Get(getDeployServiceAccountName(testNamespace), metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("expected deploy service account: %s", err)
+	}
+
+	binding, err := c.client.RbacV1().RoleBindings(testNamespace).Get(getDeployRoleBindingName(testNamespace), metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if binding.RoleRef.Name != getRoleName(testNamespace, RoleProfileDeploy) {
+		t.Errorf("expected deploy role, got %q", binding.RoleRef.Name)
+	}
+
+	role, err := c.client.RbacV1().Roles(testNamespace).Get(getRoleName(testNamespace, RoleProfileDeploy), metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, rule := range role.Rules {
+		for _, resource := range rule.Resources {
+			if resource == "secrets" || resource == "*" {
+				t.Errorf("expected deploy role without access to secrets, got %#v", rule)
+			}
+		}
+	}
+}
+
+func TestDeployCredentials(t *testing.T) {
+	c, _ := newTestClient()
+
+	if err := c.CreateNamespaceWithServiceAccount(testNamespaceModel()); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	token, err := c.GetDeployToken(testNamespace, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if expected := "token-" + getDeployServiceAccountName(testNamespace); token != expected {
+		t.Errorf("expected token %q, got %q", expected, token)
+	}
+
+	certificate, err := c.GetDeployCertificate(testNamespace)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if certificate != "certificate" {
+		t.Errorf("unexpected certificate %q", certificate)
+	}
+
+	config, err := c.CreateDeployConfiguration(testNamespace, 0, KubeconfigOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !strings.Contains(config, "token: "+token) {
+		t.Errorf("expected configuration with the deploy token, got:\n%s", config)
+	}
+
+	rotated, err := c.RotateDeployToken(testNamespace)
+	if err != nil {
+		t.Fatalf("unexpected error rotating token: %s", err)
+	}
+	if rotated == token {
+		t.Error("expected a new deploy token")
+	}
+
+	namespaceToken, err := c.GetToken(testNamespace, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if namespaceToken != "token-"+getServiceAccountName(testNamespace) {
+		t.Errorf("expected the namespace token to be left alone, got %q", namespaceToken)
+	}
+}
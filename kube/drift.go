@@ -0,0 +1,442 @@
+package kube
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/gobuffalo/uuid"
+	"github.com/kradalby/bork/models"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	kubernetesErrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// Drift is the difference between the objects bork would create for a
+// namespace and the ones in the cluster: objects that are missing,
+// objects with other labels, rules, subjects or quotas, and labelled
+// objects bork would not create. Applying the namespace again and
+// removing the extra objects repairs it.
+
+// ObjectDrift is a managed object that differs from what bork would
+// create.
+type ObjectDrift struct {
+	Namespace string `json:"namespace"`
+	Kind      string `json:"kind"`
+	Name      string `json:"name"`
+	// Missing objects do not exist in the cluster
+	Missing bool `json:"missing,omitempty"`
+	// Extra objects are labelled as belonging to the namespace, but
+	// bork would not create them
+	Extra  bool         `json:"extra,omitempty"`
+	Fields []FieldDrift `json:"fields,omitempty"`
+}
+
+// FieldDrift is a field of an object with another value than expected.
+type FieldDrift struct {
+	Field    string `json:"field"`
+	Expected string `json:"expected"`
+	Actual   string `json:"actual"`
+}
+
+// String returns the object and how it differs on a single line.
+func (d ObjectDrift) String() string {
+	object := d.Kind + "/" + d.Name
+	switch {
+	case d.Missing:
+		return object + " is missing"
+	case d.Extra:
+		return object + " is not expected"
+	}
+
+	description := object
+	for _, field := range d.Fields {
+		description += fmt.Sprintf(", %s: expected %s, got %s", field.Field, field.Expected, field.Actual)
+	}
+	return description
+}
+
+// FindDrift compares the objects of the namespace in the cluster with
+// the ones bork would create from the database row. The members must
+// be loaded on the namespace.
+func (c *Client) FindDrift(namespace *models.Namespace) ([]ObjectDrift, error) {
+	desired, err := c.desiredObjects(namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	drift := []ObjectDrift{}
+	expected := map[string]bool{}
+
+	for _, object := range desired {
+		objectDrift, err := c.objectDrift(namespace.Name, object)
+		if err != nil {
+			return nil, err
+		}
+		if objectDrift != nil {
+			drift = append(drift, *objectDrift)
+		}
+
+		accessor, err := meta.Accessor(object)
+		if err != nil {
+			return nil, err
+		}
+		expected[objectKind(object)+"/"+accessor.GetName()] = true
+	}
+
+	labelled, err := c.labelledObjects(namespace.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, object := range labelled {
+		accessor, err := meta.Accessor(object)
+		if err != nil {
+			return nil, err
+		}
+		if expected[objectKind(object)+"/"+accessor.GetName()] {
+			continue
+		}
+		drift = append(drift, ObjectDrift{
+			Namespace: namespace.Name,
+			Kind:      objectKind(object),
+			Name:      accessor.GetName(),
+			Extra:     true,
+		})
+	}
+
+	return drift, nil
+}
+
+// RepairDrift applies the namespace again and removes the extra
+// objects found by FindDrift.
+func (c *Client) RepairDrift(namespace *models.Namespace, drift []ObjectDrift) error {
+	if err := c.ApplyNamespace(namespace); err != nil {
+		return err
+	}
+
+	for _, object := range drift {
+		if !object.Extra {
+			continue
+		}
+		if err := c.deleteObject(namespace.Name, object.Kind, object.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// desiredObjects returns every object bork creates for the namespace,
+// built the same way as when provisioning it.
+func (c *Client) desiredObjects(namespace *models.Namespace) ([]runtime.Object, error) {
+	quota, err := buildResourceQuota(namespace, QuotaFor(namespace))
+	if err != nil {
+		return nil, err
+	}
+
+	limits, err := buildLimitRange(namespace, QuotaFor(namespace))
+	if err != nil {
+		return nil, err
+	}
+
+	policy, err := buildNetworkPolicy(namespace, NetworkPolicyFor(namespace))
+	if err != nil {
+		return nil, err
+	}
+
+	objects := []runtime.Object{
+		&corev1.Namespace{ObjectMeta: namespaceObjectMeta(namespace)},
+		quota,
+		limits,
+		policy,
+		&corev1.ServiceAccount{ObjectMeta: managedObjectMeta(namespace, getServiceAccountName(namespace.Name), nil)},
+		buildClusterRole(namespace),
+		buildServiceAccountClusterRoleBinding(namespace),
+		buildServiceAccountRoleBinding(namespace, RoleProfileFor(namespace)),
+		&corev1.ServiceAccount{ObjectMeta: managedObjectMeta(namespace, getDeployServiceAccountName(namespace.Name), deployLabels())},
+		buildDeployRoleBinding(namespace),
+	}
+
+	profiles, err := c.roleProfiles()
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range profiles.Names() {
+		objects = append(objects, buildRole(namespace, name, profiles[name]))
+	}
+
+	for _, user := range namespace.Users() {
+		if user.ID == uuid.Nil {
+			continue
+		}
+		objects = append(objects,
+			&corev1.ServiceAccount{ObjectMeta: managedObjectMeta(namespace, getMemberServiceAccountName(namespace.Name, user), memberLabels(user))},
+			buildMemberRoleBinding(namespace, user, MemberRoleProfileFor(namespace, user)),
+			buildMemberClusterRoleBinding(namespace, user),
+		)
+	}
+
+	if OIDCEnabled() {
+		subjects := oidcSubjects(namespace)
+		all := []rbacv1.Subject{}
+		for _, profile := range subjects.profiles() {
+			objects = append(objects, buildOIDCRoleBinding(namespace, profile, subjects[profile]))
+			all = append(all, subjects[profile]...)
+		}
+		objects = append(objects, buildOIDCClusterRoleBinding(namespace, all))
+	}
+
+	return objects, nil
+}
+
+// objectDrift compares an object with the one in the cluster, returning
+// nil if they match.
+func (c *Client) objectDrift(namespace string, desired runtime.Object) (*ObjectDrift, error) {
+	desiredMeta, err := meta.Accessor(desired)
+	if err != nil {
+		return nil, err
+	}
+
+	drift := &ObjectDrift{
+		Namespace: namespace,
+		Kind:      objectKind(desired),
+		Name:      desiredMeta.GetName(),
+	}
+
+	existing, err := c.getObject(namespace, drift.Kind, drift.Name)
+	if kubernetesErrors.IsNotFound(err) {
+		drift.Missing = true
+		return drift, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	existingMeta, err := meta.Accessor(existing)
+	if err != nil {
+		return nil, err
+	}
+
+	drift.Fields = append(drift.Fields, mapDrift("labels", desiredMeta.GetLabels(), existingMeta.GetLabels())...)
+	drift.Fields = append(drift.Fields, mapDrift("annotations", desiredMeta.GetAnnotations(), existingMeta.GetAnnotations())...)
+
+	desiredSpec := specFields(desired)
+	existingSpec := specFields(existing)
+	for _, field := range sortedKeys(desiredSpec) {
+		if equality.Semantic.DeepEqual(desiredSpec[field], existingSpec[field]) {
+			continue
+		}
+		drift.Fields = append(drift.Fields, FieldDrift{
+			Field:    field,
+			Expected: toJSON(desiredSpec[field]),
+			Actual:   toJSON(existingSpec[field]),
+		})
+	}
+
+	if len(drift.Fields) == 0 {
+		return nil, nil
+	}
+	return drift, nil
+}
+
+// mapDrift compares the expected keys of a label or annotation map,
+// keys added by others are ignored.
+func mapDrift(field string, desired map[string]string, existing map[string]string) []FieldDrift {
+	drift := []FieldDrift{}
+	for _, key := range sortedKeys(desired) {
+		actual, ok := existing[key]
+		if ok && actual == desired[key] {
+			continue
+		}
+		if !ok {
+			actual = "<none>"
+		}
+		drift = append(drift, FieldDrift{
+			Field:    field + "[" + key + "]",
+			Expected: desired[key],
+			Actual:   actual,
+		})
+	}
+	return drift
+}
+
+// specFields returns the fields of an object bork sets, other than the
+// metadata.
+func specFields(object runtime.Object) map[string]interface{} {
+	switch o := object.(type) {
+	case *corev1.ResourceQuota:
+		return map[string]interface{}{"spec.hard": o.Spec.Hard}
+	case *corev1.LimitRange:
+		return map[string]interface{}{"spec.limits": o.Spec.Limits}
+	case *networkingv1.NetworkPolicy:
+		return map[string]interface{}{"spec": o.Spec}
+	case *rbacv1.Role:
+		return map[string]interface{}{"rules": o.Rules}
+	case *rbacv1.ClusterRole:
+		return map[string]interface{}{"rules": o.Rules}
+	case *rbacv1.RoleBinding:
+		return map[string]interface{}{"subjects": o.Subjects, "roleRef": o.RoleRef}
+	case *rbacv1.ClusterRoleBinding:
+		return map[string]interface{}{"subjects": o.Subjects, "roleRef": o.RoleRef}
+	}
+	return map[string]interface{}{}
+}
+
+func objectKind(object runtime.Object) string {
+	switch object.(type) {
+	case *corev1.Namespace:
+		return "Namespace"
+	case *corev1.ResourceQuota:
+		return "ResourceQuota"
+	case *corev1.LimitRange:
+		return "LimitRange"
+	case *corev1.ServiceAccount:
+		return "ServiceAccount"
+	case *networkingv1.NetworkPolicy:
+		return "NetworkPolicy"
+	case *rbacv1.Role:
+		return "Role"
+	case *rbacv1.RoleBinding:
+		return "RoleBinding"
+	case *rbacv1.ClusterRole:
+		return "ClusterRole"
+	case *rbacv1.ClusterRoleBinding:
+		return "ClusterRoleBinding"
+	}
+	return fmt.Sprintf("%T", object)
+}
+
+func (c *Client) getObject(namespace string, kind string, name string) (runtime.Object, error) {
+	options := metav1.GetOptions{}
+
+	var object runtime.Object
+	var err error
+	switch kind {
+	case "Namespace":
+		object, err = c.client.CoreV1().Namespaces().Get(name, options)
+	case "ResourceQuota":
+		object, err = c.client.CoreV1().ResourceQuotas(namespace).Get(name, options)
+	case "LimitRange":
+		object, err = c.client.CoreV1().LimitRanges(namespace).Get(name, options)
+	case "ServiceAccount":
+		object, err = c.client.CoreV1().ServiceAccounts(namespace).Get(name, options)
+	case "NetworkPolicy":
+		object, err = c.client.NetworkingV1().NetworkPolicies(namespace).Get(name, options)
+	case "Role":
+		object, err = c.client.RbacV1().Roles(namespace).Get(name, options)
+	case "RoleBinding":
+		object, err = c.client.RbacV1().RoleBindings(namespace).Get(name, options)
+	case "ClusterRole":
+		object, err = c.client.RbacV1().ClusterRoles().Get(name, options)
+	case "ClusterRoleBinding":
+		object, err = c.client.RbacV1().ClusterRoleBindings().Get(name, options)
+	default:
+		return nil, fmt.Errorf("unknown kind %s", kind)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	return object, nil
+}
+
+func (c *Client) deleteObject(namespace string, kind string, name string) error {
+	options := &metav1.DeleteOptions{}
+
+	var err error
+	switch kind {
+	case "ServiceAccount":
+		err = c.client.CoreV1().ServiceAccounts(namespace).Delete(name, options)
+	case "Role":
+		err = c.client.RbacV1().Roles(namespace).Delete(name, options)
+	case "RoleBinding":
+		err = c.client.RbacV1().RoleBindings(namespace).Delete(name, options)
+	case "ClusterRole":
+		err = c.client.RbacV1().ClusterRoles().Delete(name, options)
+	case "ClusterRoleBinding":
+		err = c.client.RbacV1().ClusterRoleBindings().Delete(name, options)
+	default:
+		return fmt.Errorf("not deleting %s %s", kind, name)
+	}
+	return ignoreNotFound(err)
+}
+
+// labelledObjects returns the service accounts, roles and bindings
+// labelled as belonging to the namespace, the kinds deleteManagedObjects
+// removes.
+func (c *Client) labelledObjects(namespace string) ([]runtime.Object, error) {
+	options := metav1.ListOptions{LabelSelector: namespaceSelector(namespace)}
+	rbac := c.client.RbacV1()
+	objects := []runtime.Object{}
+
+	serviceAccounts, err := c.client.CoreV1().ServiceAccounts(namespace).List(options)
+	if err != nil {
+		return nil, err
+	}
+	for i := range serviceAccounts.Items {
+		objects = append(objects, &serviceAccounts.Items[i])
+	}
+
+	roles, err := rbac.Roles(namespace).List(options)
+	if err != nil {
+		return nil, err
+	}
+	for i := range roles.Items {
+		objects = append(objects, &roles.Items[i])
+	}
+
+	roleBindings, err := rbac.RoleBindings(namespace).List(options)
+	if err != nil {
+		return nil, err
+	}
+	for i := range roleBindings.Items {
+		objects = append(objects, &roleBindings.Items[i])
+	}
+
+	clusterRoles, err := rbac.ClusterRoles().List(options)
+	if err != nil {
+		return nil, err
+	}
+	for i := range clusterRoles.Items {
+		objects = append(objects, &clusterRoles.Items[i])
+	}
+
+	clusterRoleBindings, err := rbac.ClusterRoleBindings().List(options)
+	if err != nil {
+		return nil, err
+	}
+	for i := range clusterRoleBindings.Items {
+		objects = append(objects, &clusterRoleBindings.Items[i])
+	}
+
+	return objects, nil
+}
+
+func sortedKeys(m interface{}) []string {
+	keys := []string{}
+	switch m := m.(type) {
+	case map[string]string:
+		for key := range m {
+			keys = append(keys, key)
+		}
+	case map[string]interface{}:
+		for key := range m {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func toJSON(value interface{}) string {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprintf("%v", value)
+	}
+	return string(encoded)
+}
@@ -0,0 +1,121 @@
+package kube
+
+import (
+	"testing"
+
+	"github.com/gobuffalo/uuid"
+	"github.com/kradalby/bork/models"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func provisionedNamespace(t *testing.T, c *Client) *models.Namespace {
+	t.Helper()
+
+	owner := testUser("owner")
+	ns := testNamespaceModel()
+	ns.ID = uuid.Must(uuid.NewV4())
+	ns.Owner = owner
+	ns.OwnerID = owner.ID
+
+	if err := c.CreateNamespaceWithServiceAccount(ns); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	return ns
+}
+
+func findObjectDrift(drift []ObjectDrift, kind string, name string) *ObjectDrift {
+	for i := range drift {
+		if drift[i].Kind == kind && drift[i].Name == name {
+			return &drift[i]
+		}
+	}
+	return nil
+}
+
+func TestNoDriftAfterProvisioning(t *testing.T) {
+	defer withOIDCBindings(OIDCBindingsEmail, "")()
+
+	c, _ := newTestClient()
+	ns := provisionedNamespace(t, c)
+
+	drift, err := c.FindDrift(ns)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(drift) != 0 {
+		t.Errorf("expected no drift, got %v", drift)
+	}
+}
+
+func TestFindDrift(t *testing.T) {
+	c, _ := newTestClient()
+	ns := provisionedNamespace(t, c)
+	rbac := c.client.RbacV1()
+
+	err := rbac.RoleBindings(testNamespace).Delete(getRoleBindingName(testNamespace), &metav1.DeleteOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serviceAccount, err := c.client.CoreV1().ServiceAccounts(testNamespace).Get(getServiceAccountName(testNamespace), metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	serviceAccount.Labels[ownerLabel] = "someone-else"
+	if _, err := c.client.CoreV1().ServiceAccounts(testNamespace).Update(serviceAccount); err != nil {
+		t.Fatal(err)
+	}
+
+	roleName := getRoleName(testNamespace, RoleProfileFor(ns))
+	role, err := rbac.Roles(testNamespace).Get(roleName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	role.Rules = append(role.Rules, rbacv1.PolicyRule{APIGroups: []string{""}, Resources: []string{"secrets"}, Verbs: []string{"*"}})
+	if _, err := rbac.Roles(testNamespace).Update(role); err != nil {
+		t.Fatal(err)
+	}
+
+	extra := &rbacv1.ClusterRoleBinding{ObjectMeta: managedClusterObjectMeta(ns, "extra-binding", nil)}
+	if _, err := rbac.ClusterRoleBindings().Create(extra); err != nil {
+		t.Fatal(err)
+	}
+
+	drift, err := c.FindDrift(ns)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(drift) != 4 {
+		t.Errorf("expected 4 drifted objects, got %v", drift)
+	}
+
+	if d := findObjectDrift(drift, "RoleBinding", getRoleBindingName(testNamespace)); d == nil || !d.Missing {
+		t.Errorf("expected the role binding to be missing, got %v", d)
+	}
+
+	d := findObjectDrift(drift, "ServiceAccount", getServiceAccountName(testNamespace))
+	if d == nil || len(d.Fields) != 1 || d.Fields[0].Field != "labels["+ownerLabel+"]" || d.Fields[0].Actual != "someone-else" {
+		t.Errorf("expected the owner label to differ, got %v", d)
+	}
+
+	if d := findObjectDrift(drift, "Role", roleName); d == nil || len(d.Fields) != 1 || d.Fields[0].Field != "rules" {
+		t.Errorf("expected the role rules to differ, got %v", d)
+	}
+
+	if d := findObjectDrift(drift, "ClusterRoleBinding", extra.Name); d == nil || !d.Extra {
+		t.Errorf("expected the extra binding to be reported, got %v", d)
+	}
+
+	if err := c.RepairDrift(ns, drift); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	drift, err = c.FindDrift(ns)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(drift) != 0 {
+		t.Errorf("expected repair to remove all drift, got %v", drift)
+	}
+}
@@ -0,0 +1,166 @@
+package kube
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/gobuffalo/pop/nulls"
+	"github.com/kradalby/bork/models"
+)
+
+// Namespaces can expire. New namespaces get the default TTL of their
+// owner, owners can extend the expiry up to the maximum and the reaper
+// deletes expired namespaces through the grace period like any other
+// deletion. Locked namespaces are never reaped.
+
+// DefaultNamespaceTTL returns how long new namespaces of the user live,
+// the TTL set on the user or else the default of their role, set by
+// BORK_NAMESPACE_TTL_DEFAULT and BORK_NAMESPACE_TTL_ADMIN_DEFAULT. Zero
+// means namespaces do not expire.
+func DefaultNamespaceTTL(user models.User) time.Duration {
+	if user.NamespaceTTL != "" {
+		ttl, err := time.ParseDuration(user.NamespaceTTL)
+		if err == nil {
+			return ttl
+		}
+		log.Printf("[Error] Invalid namespace TTL of %s, using the default: %#v", user.Username, err)
+	}
+
+	if user.IsAdmin {
+		return durationFromEnv("BORK_NAMESPACE_TTL_ADMIN_DEFAULT", 0)
+	}
+	return durationFromEnv("BORK_NAMESPACE_TTL_DEFAULT", 0)
+}
+
+// MaxNamespaceTTL returns how far ahead owners can set the expiry of a
+// namespace, set by BORK_NAMESPACE_TTL_MAX. Zero means there is no
+// maximum, and owners can remove the expiry.
+func MaxNamespaceTTL() time.Duration {
+	return durationFromEnv("BORK_NAMESPACE_TTL_MAX", 30*24*time.Hour)
+}
+
+// DefaultExpiry returns the expiry of a new namespace of the user,
+// invalid if it does not expire.
+func DefaultExpiry(user models.User, now time.Time) nulls.Time {
+	ttl := DefaultNamespaceTTL(user)
+	if ttl <= 0 {
+		return nulls.Time{}
+	}
+	return nulls.NewTime(now.Add(ttl))
+}
+
+// ErrNamespaceTTLExceeded is returned when an owner restores a namespace
+// that has lived for the maximum TTL.
+var ErrNamespaceTTLExceeded = errors.New("namespace has reached its maximum TTL")
+
+// MaxExpiry returns the latest expiry owners can give the namespace, the
+// maximum TTL counted from when the namespace was created. It is invalid
+// if there is no maximum.
+func MaxExpiry(namespace *models.Namespace, now time.Time) nulls.Time {
+	max := MaxNamespaceTTL()
+	if max <= 0 {
+		return nulls.Time{}
+	}
+
+	created := namespace.CreatedAt
+	if created.IsZero() {
+		created = now
+	}
+	return nulls.NewTime(created.Add(max))
+}
+
+// ValidateExpiry makes sure the user may set the expiry of the namespace.
+// It has to be in the future, and for anyone but admins within the
+// maximum TTL of when the namespace was created.
+func ValidateExpiry(user models.User, namespace *models.Namespace, expiresAt nulls.Time, now time.Time) error {
+	if user.IsAdmin {
+		if expiresAt.Valid && !expiresAt.Time.After(now) {
+			return errors.New("the expiry has to be in the future")
+		}
+		return nil
+	}
+
+	max := MaxExpiry(namespace, now)
+	if !expiresAt.Valid {
+		if max.Valid {
+			return fmt.Errorf("namespaces have to expire within %s of being created", MaxNamespaceTTL())
+		}
+		return nil
+	}
+
+	if !expiresAt.Time.After(now) {
+		return errors.New("the expiry has to be in the future")
+	}
+	if max.Valid && expiresAt.Time.After(max.Time) {
+		return fmt.Errorf("namespaces have to expire within %s of being created", MaxNamespaceTTL())
+	}
+	return nil
+}
+
+// restoreExpiry returns the expiry of the namespace once the user
+// restores it. An expired namespace gets the default TTL of its owner
+// again, so it is not reaped right away, for anyone but admins no later
+// than the maximum expiry. The owner must be loaded on the namespace.
+func restoreExpiry(namespace *models.Namespace, user models.User, now time.Time) (nulls.Time, error) {
+	if !namespace.Expired(now) {
+		return namespace.ExpiresAt, nil
+	}
+
+	expiresAt := DefaultExpiry(namespace.Owner, now)
+	if user.IsAdmin {
+		return expiresAt, nil
+	}
+
+	max := MaxExpiry(namespace, now)
+	if !max.Valid {
+		return expiresAt, nil
+	}
+	if !max.Time.After(now) {
+		return nulls.Time{}, ErrNamespaceTTLExceeded
+	}
+	if !expiresAt.Valid || expiresAt.Time.After(max.Time) {
+		return max, nil
+	}
+	return expiresAt, nil
+}
+
+// ReapExpiredNamespaces schedules the deletion of the namespaces of the
+// cluster that expired, unless they are locked or already pending
+// deletion, and audits it. Namespaces locked in the cluster only get
+// their row locked, see lockFromCluster. A namespace failing to be scheduled does not
+// stop the others.
+func (c *Client) ReapExpiredNamespaces(now time.Time) error {
+	namespaces := models.Namespaces{}
+
+	// The members are loaded, scheduling the deletion applies the quota
+	// with their annotation
+	query := models.DB.Eager().Where("cluster_id IS NULL AND expires_at <= ? AND delete_at IS NULL AND NOT locked", now)
+	if c.cluster != nil {
+		query = models.DB.Eager().Where("cluster_id = ? AND expires_at <= ? AND delete_at IS NULL AND NOT locked", c.cluster.ID, now)
+	}
+	if err := query.All(&namespaces); err != nil {
+		return err
+	}
+
+	for i := range namespaces {
+		namespace := &namespaces[i]
+		locked, err := c.lockFromCluster(namespace)
+		if err != nil {
+			log.Printf("[Error] %#v", err)
+			continue
+		}
+		if locked {
+			continue
+		}
+
+		log.Printf("[INFO] Deleting namespace %s, it expired at %s", namespace.Name, namespace.ExpiresAt.Time)
+		err = c.ScheduleNamespaceDeletion(namespace)
+		if err != nil {
+			log.Printf("[Error] %#v", err)
+		}
+		recordSystemAudit("ReapExpiredNamespaces", namespace, "expired at "+namespace.ExpiresAt.Time.Format(time.RFC3339), err)
+	}
+	return nil
+}
@@ -0,0 +1,278 @@
+package kube
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gobuffalo/envy"
+	"github.com/gobuffalo/pop/nulls"
+	"github.com/kradalby/bork/models"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func withNamespaceTTLs(user string, admin string, max string) func() {
+	envy.Set("BORK_NAMESPACE_TTL_DEFAULT", user)
+	envy.Set("BORK_NAMESPACE_TTL_ADMIN_DEFAULT", admin)
+	envy.Set("BORK_NAMESPACE_TTL_MAX", max)
+	return func() {
+		envy.Set("BORK_NAMESPACE_TTL_DEFAULT", "0")
+		envy.Set("BORK_NAMESPACE_TTL_ADMIN_DEFAULT", "0")
+		envy.Set("BORK_NAMESPACE_TTL_MAX", "720h")
+	}
+}
+
+func TestDefaultNamespaceTTL(t *testing.T) {
+	defer withNamespaceTTLs("72h", "0", "720h")()
+
+	tests := []struct {
+		name     string
+		user     models.User
+		expected time.Duration
+	}{
+		{"user", models.User{}, 72 * time.Hour},
+		{"admin", models.User{IsAdmin: true}, 0},
+		{"user override", models.User{NamespaceTTL: "8h"}, 8 * time.Hour},
+		{"admin override", models.User{IsAdmin: true, NamespaceTTL: "24h"}, 24 * time.Hour},
+		{"never expires", models.User{NamespaceTTL: "0"}, 0},
+		{"invalid override", models.User{NamespaceTTL: "soon"}, 72 * time.Hour},
+	}
+
+	for _, tt := range tests {
+		if ttl := DefaultNamespaceTTL(tt.user); ttl != tt.expected {
+			t.Errorf("%s: expected %s, got %s", tt.name, tt.expected, ttl)
+		}
+	}
+
+	now := time.Now()
+	if expiry := DefaultExpiry(models.User{IsAdmin: true}, now); expiry.Valid {
+		t.Errorf("expected namespaces of admins not to expire, got %s", expiry.Time)
+	}
+	if expiry := DefaultExpiry(models.User{}, now); !expiry.Time.Equal(now.Add(72 * time.Hour)) {
+		t.Errorf("expected namespaces of users to expire in 72h, got %s", expiry.Time)
+	}
+}
+
+func TestValidateExpiry(t *testing.T) {
+	defer withNamespaceTTLs("0", "0", "168h")()
+
+	now := time.Now()
+	owner := models.User{}
+	admin := models.User{IsAdmin: true}
+
+	tests := []struct {
+		name      string
+		user      models.User
+		expiresAt nulls.Time
+		valid     bool
+	}{
+		{"within the maximum", owner, nulls.NewTime(now.Add(72 * time.Hour)), true},
+		{"beyond the maximum", owner, nulls.NewTime(now.Add(200 * time.Hour)), false},
+		{"in the past", owner, nulls.NewTime(now.Add(-time.Hour)), false},
+		{"never as owner", owner, nulls.Time{}, false},
+		{"beyond the maximum as admin", admin, nulls.NewTime(now.Add(200 * time.Hour)), true},
+		{"never as admin", admin, nulls.Time{}, true},
+		{"in the past as admin", admin, nulls.NewTime(now.Add(-time.Hour)), false},
+	}
+
+	for _, tt := range tests {
+		err := ValidateExpiry(tt.user, &models.Namespace{}, tt.expiresAt, now)
+		if (err == nil) != tt.valid {
+			t.Errorf("%s: expected valid %t, got %v", tt.name, tt.valid, err)
+		}
+	}
+
+	// The maximum counts from when the namespace was created
+	old := &models.Namespace{CreatedAt: now.Add(-100 * time.Hour)}
+	if err := ValidateExpiry(owner, old, nulls.NewTime(now.Add(72*time.Hour)), now); err == nil {
+		t.Error("expected an old namespace not to be extended beyond the maximum")
+	}
+	if err := ValidateExpiry(owner, old, nulls.NewTime(now.Add(48*time.Hour)), now); err != nil {
+		t.Errorf("expected an old namespace to be extended within the maximum, got %s", err)
+	}
+
+	envy.Set("BORK_NAMESPACE_TTL_MAX", "0")
+	if err := ValidateExpiry(owner, &models.Namespace{}, nulls.Time{}, now); err != nil {
+		t.Errorf("expected owners to remove the expiry without a maximum, got %s", err)
+	}
+}
+
+func TestRestoreExpiry(t *testing.T) {
+	defer withNamespaceTTLs("720h", "0", "168h")()
+
+	now := time.Now()
+	owner := models.User{}
+	admin := models.User{IsAdmin: true}
+	expired := nulls.NewTime(now.Add(-time.Hour))
+
+	tests := []struct {
+		name      string
+		user      models.User
+		namespace models.Namespace
+		expected  nulls.Time
+		err       error
+	}{
+		{"not expired", owner, models.Namespace{ExpiresAt: nulls.NewTime(now.Add(time.Hour))}, nulls.NewTime(now.Add(time.Hour)), nil},
+		{"clamped to the maximum", owner, models.Namespace{CreatedAt: now.Add(-100 * time.Hour), ExpiresAt: expired}, nulls.NewTime(now.Add(68 * time.Hour)), nil},
+		{"beyond the maximum", owner, models.Namespace{CreatedAt: now.Add(-200 * time.Hour), ExpiresAt: expired}, nulls.Time{}, ErrNamespaceTTLExceeded},
+		{"beyond the maximum as admin", admin, models.Namespace{CreatedAt: now.Add(-200 * time.Hour), ExpiresAt: expired}, nulls.NewTime(now.Add(720 * time.Hour)), nil},
+	}
+
+	for _, tt := range tests {
+		expiresAt, err := restoreExpiry(&tt.namespace, tt.user, now)
+		if err != tt.err {
+			t.Errorf("%s: expected error %v, got %v", tt.name, tt.err, err)
+			continue
+		}
+		if expiresAt.Valid != tt.expected.Valid || !expiresAt.Time.Equal(tt.expected.Time) {
+			t.Errorf("%s: expected expiry %v, got %v", tt.name, tt.expected, expiresAt)
+		}
+	}
+}
+
+// requireDatabase skips a test when the database can not be reached.
+func requireDatabase(t *testing.T) {
+	t.Helper()
+	if err := models.DB.RawQuery("SELECT 1").Exec(); err != nil {
+		t.Skipf("database not available: %s", err)
+	}
+}
+
+// createTestNamespace stores a namespace of the owner in the database,
+// removed again by the returned function.
+func createTestNamespace(t *testing.T, owner models.User, name string, expiresAt nulls.Time, locked bool) (*models.Namespace, func()) {
+	t.Helper()
+	ns := &models.Namespace{
+		Name:      name,
+		Owner:     owner,
+		OwnerID:   owner.ID,
+		ExpiresAt: expiresAt,
+		Locked:    locked,
+	}
+	if err := models.DB.Create(ns); err != nil {
+		t.Fatal(err)
+	}
+	return ns, func() { models.DB.Destroy(ns) }
+}
+
+func TestReapExpiredNamespaces(t *testing.T) {
+	requireDatabase(t)
+	defer withNamespaceTTLs("0", "0", "0")()
+
+	owner := testUser("reaper-owner")
+	if err := models.DB.Create(&owner); err != nil {
+		t.Fatal(err)
+	}
+	defer models.DB.Destroy(&owner)
+
+	now := time.Now()
+	expired, cleanup := createTestNamespace(t, owner, "bork-test-expired", nulls.NewTime(now.Add(-time.Hour)), false)
+	defer cleanup()
+	locked, cleanup := createTestNamespace(t, owner, "bork-test-expired-locked", nulls.NewTime(now.Add(-time.Hour)), true)
+	defer cleanup()
+	alive, cleanup := createTestNamespace(t, owner, "bork-test-alive", nulls.NewTime(now.Add(time.Hour)), false)
+	defer cleanup()
+
+	c, _ := newTestClient()
+	if err := c.ReapExpiredNamespaces(now); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	for _, tt := range []struct {
+		namespace *models.Namespace
+		pending   bool
+	}{
+		{expired, true},
+		{locked, false},
+		{alive, false},
+	} {
+		reloaded := &models.Namespace{}
+		if err := models.DB.Find(reloaded, tt.namespace.ID); err != nil {
+			t.Fatal(err)
+		}
+		if reloaded.PendingDeletion() != tt.pending {
+			t.Errorf("%s: expected pending deletion %t, got %t", tt.namespace.Name, tt.pending, reloaded.PendingDeletion())
+		}
+	}
+}
+
+func TestReapExpiredNamespacesKeepsCoOwners(t *testing.T) {
+	requireDatabase(t)
+	defer withNamespaceTTLs("0", "0", "0")()
+
+	owner := testUser("reaper-owner")
+	coOwner := testUser("reaper-coowner")
+	for _, user := range []*models.User{&owner, &coOwner} {
+		if err := models.DB.Create(user); err != nil {
+			t.Fatal(err)
+		}
+		defer models.DB.Destroy(user)
+	}
+
+	ns, cleanup := createTestNamespace(t, owner, "bork-test-expired-coowned", nulls.NewTime(time.Now().Add(-time.Hour)), false)
+	defer cleanup()
+	if err := models.DB.RawQuery("INSERT INTO namespaces_users (namespace_id, user_id) VALUES (?, ?)", ns.ID, coOwner.ID).Exec(); err != nil {
+		t.Fatal(err)
+	}
+	defer models.DB.RawQuery("DELETE FROM namespaces_users WHERE namespace_id = ?", ns.ID).Exec()
+
+	c, _ := newTestClient()
+	if err := c.ReapExpiredNamespaces(time.Now()); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	quota, err := c.client.CoreV1().ResourceQuotas(ns.Name).Get(getResourceQuotaName(ns.Name), metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if coOwners := quota.Annotations[coOwnersAnnotation]; coOwners != coOwner.ID.String() {
+		t.Errorf("expected the co-owner %s to be kept, got %q", coOwner.ID, coOwners)
+	}
+}
+
+func TestRestoreNamespace(t *testing.T) {
+	requireDatabase(t)
+	defer withNamespaceTTLs("720h", "0", "168h")()
+
+	owner := testUser("restore-owner")
+	if err := models.DB.Create(&owner); err != nil {
+		t.Fatal(err)
+	}
+	defer models.DB.Destroy(&owner)
+
+	now := time.Now()
+	ns, cleanup := createTestNamespace(t, owner, "bork-test-restore", nulls.NewTime(now.Add(-time.Hour)), false)
+	defer cleanup()
+
+	c, _ := newTestClient()
+	if err := c.CreateNamespaceWithServiceAccount(ns); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := c.ScheduleNamespaceDeletion(ns); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if err := c.RestoreNamespace(ns, owner); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	reloaded := &models.Namespace{}
+	if err := models.DB.Find(reloaded, ns.ID); err != nil {
+		t.Fatal(err)
+	}
+	if reloaded.PendingDeletion() {
+		t.Error("expected the namespace not to be pending deletion")
+	}
+	max := MaxExpiry(reloaded, now)
+	if !reloaded.ExpiresAt.Valid || reloaded.ExpiresAt.Time.After(max.Time) {
+		t.Errorf("expected the expiry to be clamped to %s, got %v", max.Time, reloaded.ExpiresAt)
+	}
+
+	quota, err := c.client.CoreV1().ResourceQuotas(ns.Name).Get(getResourceQuotaName(ns.Name), metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pods := quota.Spec.Hard[corev1.ResourcePods]; pods.IsZero() {
+		t.Error("expected the quota to allow pods again")
+	}
+}
@@ -0,0 +1,221 @@
+package kube
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"reflect"
+	"time"
+
+	"github.com/gobuffalo/envy"
+	"github.com/kradalby/bork/models"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+	clientcmdlatest "k8s.io/client-go/tools/clientcmd/api/latest"
+	clientcmdapiv1 "k8s.io/client-go/tools/clientcmd/api/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// kubeconfigNamePrefix is put in front of the default cluster names,
+// keeping them apart from clusters in kubeconfigs made by other tools.
+const kubeconfigNamePrefix = "bork-"
+
+// KubeconfigOptions changes how a kubeconfig is generated, empty fields
+// use the defaults.
+type KubeconfigOptions struct {
+	// ClusterName defaults to bork- followed by the name of the
+	// cluster, or BORK_KUBECONFIG_CLUSTER_NAME and then bork- followed
+	// by the host of the API server for the default cluster
+	ClusterName string
+	// UserName defaults to the name of the service account or OIDC user
+	UserName string
+	// ContextName defaults to the namespace
+	ContextName string
+	// InsecureSkipTLSVerify leaves out the CA and skips verifying the
+	// certificate of the API server
+	InsecureSkipTLSVerify bool
+	// CertificateAuthority is a PEM encoded CA replacing the CA of the
+	// cluster, for endpoints fronted by another certificate
+	CertificateAuthority string
+}
+
+// kubeconfigCredentials is the user part of a kubeconfig, either a
+// token of a service account or an auth provider.
+type kubeconfigCredentials struct {
+	serviceAccountName string
+	userName           string
+	authInfo           *clientcmdapi.AuthInfo
+}
+
+func tokenCredentials(serviceAccountName string, token string) kubeconfigCredentials {
+	authInfo := clientcmdapi.NewAuthInfo()
+	authInfo.Token = token
+
+	return kubeconfigCredentials{
+		serviceAccountName: serviceAccountName,
+		userName:           serviceAccountName,
+		authInfo:           authInfo,
+	}
+}
+
+// createConfiguration returns a kubeconfig for the namespace with a
+// single cluster, user and context.
+func (c *Client) createConfiguration(namespace string, credentials kubeconfigCredentials, options KubeconfigOptions) (string, error) {
+	config, err := c.buildKubeconfig(namespace, credentials, options)
+	if err != nil {
+		return "", err
+	}
+
+	return writeKubeconfig(config)
+}
+
+// MemberKubeconfig returns the kubeconfig of CreateMemberConfiguration
+// before it is serialized, to be merged with other Kubeconfigs.
+func (c *Client) MemberKubeconfig(namespace string, user models.User, ttl time.Duration, options KubeconfigOptions) (*clientcmdapi.Config, error) {
+	token, err := c.GetMemberToken(namespace, user, ttl)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.buildKubeconfig(namespace, tokenCredentials(getMemberServiceAccountName(namespace, user), token), options)
+}
+
+// Kubeconfigs are kubeconfigs of several namespaces, possibly on
+// different clusters, to be handed out as one.
+type Kubeconfigs []*clientcmdapi.Config
+
+// Merge returns a kubeconfig with the clusters, users and contexts of
+// all the kubeconfigs. Namespaces on the same cluster share the cluster
+// entry. No current context is set, so merging the result into an
+// existing kubeconfig does not switch away from the context in use.
+func (k Kubeconfigs) Merge() (string, error) {
+	merged := clientcmdapi.NewConfig()
+
+	for _, config := range k {
+		for name, cluster := range config.Clusters {
+			if existing, ok := merged.Clusters[name]; ok && !reflect.DeepEqual(existing, cluster) {
+				return "", fmt.Errorf("cluster %s is defined twice with different endpoints or certificates", name)
+			}
+			merged.Clusters[name] = cluster
+		}
+		for name, authInfo := range config.AuthInfos {
+			if _, ok := merged.AuthInfos[name]; ok {
+				return "", fmt.Errorf("user %s is defined twice", name)
+			}
+			merged.AuthInfos[name] = authInfo
+		}
+		for name, context := range config.Contexts {
+			if _, ok := merged.Contexts[name]; ok {
+				return "", fmt.Errorf("context %s is defined twice", name)
+			}
+			merged.Contexts[name] = context
+		}
+	}
+
+	return writeKubeconfig(merged)
+}
+
+// writeKubeconfig serializes the config to YAML in the v1 format
+// kubectl reads.
+func writeKubeconfig(config *clientcmdapi.Config) (string, error) {
+	external := &clientcmdapiv1.Config{}
+	if err := clientcmdlatest.Scheme.Convert(config, external, nil); err != nil {
+		return "", err
+	}
+	external.APIVersion = clientcmdlatest.Version
+	external.Kind = "Config"
+
+	content, err := yaml.Marshal(external)
+	if err != nil {
+		return "", err
+	}
+
+	return string(content), nil
+}
+
+func (c *Client) buildKubeconfig(namespace string, credentials kubeconfigCredentials, options KubeconfigOptions) (*clientcmdapi.Config, error) {
+	options = c.kubeconfigDefaults(namespace, credentials, options)
+
+	cluster := clientcmdapi.NewCluster()
+	cluster.Server = c.Endpoint()
+
+	if options.InsecureSkipTLSVerify {
+		cluster.InsecureSkipTLSVerify = true
+	} else {
+		certificate, err := c.kubeconfigCertificate(namespace, credentials.serviceAccountName, options)
+		if err != nil {
+			return nil, err
+		}
+		cluster.CertificateAuthorityData = []byte(certificate)
+	}
+
+	context := clientcmdapi.NewContext()
+	context.Cluster = options.ClusterName
+	context.AuthInfo = options.UserName
+	context.Namespace = namespace
+
+	config := clientcmdapi.NewConfig()
+	config.Clusters[options.ClusterName] = cluster
+	config.AuthInfos[options.UserName] = credentials.authInfo
+	config.Contexts[options.ContextName] = context
+	config.CurrentContext = options.ContextName
+
+	return config, nil
+}
+
+func (c *Client) kubeconfigDefaults(namespace string, credentials kubeconfigCredentials, options KubeconfigOptions) KubeconfigOptions {
+	if options.ClusterName == "" {
+		options.ClusterName = c.kubeconfigClusterName()
+	}
+	if options.UserName == "" {
+		options.UserName = credentials.userName
+	}
+	if options.ContextName == "" {
+		options.ContextName = namespace
+	}
+	if !options.InsecureSkipTLSVerify {
+		options.InsecureSkipTLSVerify = c.cluster == nil && envy.Get("BORK_KUBECONFIG_INSECURE_SKIP_TLS_VERIFY", "false") == "true"
+	}
+	return options
+}
+
+// kubeconfigClusterName returns the default name of the cluster in a
+// kubeconfig. It is prefixed with bork- and named after the cluster or
+// the host of the API server, so merging the kubeconfigs of several
+// clusters does not overwrite one cluster with another.
+func (c *Client) kubeconfigClusterName() string {
+	if c.cluster != nil {
+		return kubeconfigNamePrefix + c.cluster.Name
+	}
+
+	if name := envy.Get("BORK_KUBECONFIG_CLUSTER_NAME", ""); name != "" {
+		return name
+	}
+
+	endpoint := c.Endpoint()
+	if u, err := url.Parse(endpoint); err == nil && u.Hostname() != "" {
+		return kubeconfigNamePrefix + u.Hostname()
+	}
+	return kubeconfigNamePrefix + "cluster"
+}
+
+// kubeconfigCertificate returns the CA put in the kubeconfig. The CA of
+// the options comes first, then BORK_KUBECONFIG_CA_FILE for the default
+// cluster and last the CA of the API server.
+func (c *Client) kubeconfigCertificate(namespace string, serviceAccountName string, options KubeconfigOptions) (string, error) {
+	if options.CertificateAuthority != "" {
+		return options.CertificateAuthority, nil
+	}
+
+	if file := envy.Get("BORK_KUBECONFIG_CA_FILE", ""); c.cluster == nil && file != "" {
+		certificate, err := ioutil.ReadFile(file)
+		if err != nil {
+			return "", err
+		}
+		return string(certificate), nil
+	}
+
+	if serviceAccountName == "" {
+		return c.GetCertificate(namespace)
+	}
+	return c.getServiceAccountCertificate(namespace, serviceAccountName)
+}
@@ -0,0 +1,197 @@
+package kube
+
+import (
+	"testing"
+
+	"github.com/gobuffalo/envy"
+	"github.com/gobuffalo/uuid"
+	"github.com/kradalby/bork/models"
+	clientcmdapiv1 "k8s.io/client-go/tools/clientcmd/api/v1"
+	"sigs.k8s.io/yaml"
+)
+
+func parseKubeconfig(t *testing.T, content string) clientcmdapiv1.Config {
+	config := clientcmdapiv1.Config{}
+	if err := yaml.Unmarshal([]byte(content), &config); err != nil {
+		t.Fatalf("invalid kubeconfig: %s\n%s", err, content)
+	}
+	if len(config.Clusters) != 1 || len(config.AuthInfos) != 1 || len(config.Contexts) != 1 {
+		t.Fatalf("expected a single cluster, user and context, got:\n%s", content)
+	}
+	return config
+}
+
+func TestKubeconfigDefaults(t *testing.T) {
+	c, _ := newTestClient()
+	if err := c.CreateNamespaceWithServiceAccount(testNamespaceModel()); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	content, err := c.CreateConfiguration(testNamespace, 0, KubeconfigOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	config := parseKubeconfig(t, content)
+
+	if config.Kind != "Config" || config.APIVersion != "v1" {
+		t.Errorf("unexpected kind %q and version %q", config.Kind, config.APIVersion)
+	}
+
+	cluster := config.Clusters[0]
+	if cluster.Name != "bork-kubernetes.example.com" || cluster.Cluster.Server != testEndpoint {
+		t.Errorf("unexpected cluster %#v", cluster)
+	}
+	if string(cluster.Cluster.CertificateAuthorityData) != "certificate" {
+		t.Errorf("expected the CA in certificate-authority-data, got %q", cluster.Cluster.CertificateAuthorityData)
+	}
+
+	user := config.AuthInfos[0]
+	if user.Name != getServiceAccountName(testNamespace) {
+		t.Errorf("expected user named after the service account, got %q", user.Name)
+	}
+	if len(user.AuthInfo.ClientKeyData) != 0 || len(user.AuthInfo.ClientCertificateData) != 0 {
+		t.Errorf("expected no client certificate, got %#v", user.AuthInfo)
+	}
+	if user.AuthInfo.Token != "token-"+getServiceAccountName(testNamespace) {
+		t.Errorf("unexpected token %q", user.AuthInfo.Token)
+	}
+
+	context := config.Contexts[0]
+	if context.Name != testNamespace || config.CurrentContext != testNamespace {
+		t.Errorf("expected current context %q, got %q", testNamespace, config.CurrentContext)
+	}
+	if context.Context.Cluster != cluster.Name || context.Context.AuthInfo != user.Name || context.Context.Namespace != testNamespace {
+		t.Errorf("unexpected context %#v", context.Context)
+	}
+}
+
+func TestKubeconfigOptions(t *testing.T) {
+	c, _ := newTestClient()
+	if err := c.CreateNamespaceWithServiceAccount(testNamespaceModel()); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	content, err := c.CreateConfiguration(testNamespace, 0, KubeconfigOptions{
+		ClusterName:          "production",
+		UserName:             "ci",
+		ContextName:          "production-ci",
+		CertificateAuthority: "fronted-ca",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	config := parseKubeconfig(t, content)
+
+	if config.Clusters[0].Name != "production" || config.AuthInfos[0].Name != "ci" || config.Contexts[0].Name != "production-ci" {
+		t.Errorf("expected custom names, got:\n%s", content)
+	}
+	if config.CurrentContext != "production-ci" {
+		t.Errorf("expected current context production-ci, got %q", config.CurrentContext)
+	}
+	if context := config.Contexts[0].Context; context.Cluster != "production" || context.AuthInfo != "ci" {
+		t.Errorf("expected context to refer to the custom names, got %#v", context)
+	}
+	if ca := string(config.Clusters[0].Cluster.CertificateAuthorityData); ca != "fronted-ca" {
+		t.Errorf("expected custom CA, got %q", ca)
+	}
+}
+
+func TestKubeconfigInsecureSkipTLSVerify(t *testing.T) {
+	c, _ := newTestClient()
+	if err := c.CreateNamespaceWithServiceAccount(testNamespaceModel()); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	tests := []struct {
+		name    string
+		env     string
+		options KubeconfigOptions
+	}{
+		{"option", "false", KubeconfigOptions{InsecureSkipTLSVerify: true}},
+		{"environment", "true", KubeconfigOptions{}},
+	}
+
+	for _, tt := range tests {
+		envy.Set("BORK_KUBECONFIG_INSECURE_SKIP_TLS_VERIFY", tt.env)
+
+		content, err := c.CreateConfiguration(testNamespace, 0, tt.options)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", tt.name, err)
+		}
+		cluster := parseKubeconfig(t, content).Clusters[0].Cluster
+
+		if !cluster.InsecureSkipTLSVerify {
+			t.Errorf("%s: expected insecure-skip-tls-verify", tt.name)
+		}
+		if len(cluster.CertificateAuthorityData) != 0 {
+			t.Errorf("%s: expected no CA when skipping verification, got %q", tt.name, cluster.CertificateAuthorityData)
+		}
+	}
+	envy.Set("BORK_KUBECONFIG_INSECURE_SKIP_TLS_VERIFY", "false")
+}
+
+func TestMergeMemberKubeconfigs(t *testing.T) {
+	c, _ := newTestClient()
+
+	owner := testUser("owner")
+	names := []string{testNamespace, testNamespace + "-web"}
+
+	configs := Kubeconfigs{}
+	for _, name := range names {
+		ns := &models.Namespace{
+			ID:      uuid.Must(uuid.NewV4()),
+			Name:    name,
+			Owner:   owner,
+			OwnerID: owner.ID,
+		}
+		if err := c.CreateNamespaceWithServiceAccount(ns); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		config, err := c.MemberKubeconfig(name, owner, 0, KubeconfigOptions{})
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		configs = append(configs, config)
+	}
+
+	content, err := configs.Merge()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	config := clientcmdapiv1.Config{}
+	if err := yaml.Unmarshal([]byte(content), &config); err != nil {
+		t.Fatalf("invalid kubeconfig: %s\n%s", err, content)
+	}
+
+	if len(config.Clusters) != 1 {
+		t.Errorf("expected the namespaces to share the cluster, got %d clusters", len(config.Clusters))
+	}
+	if len(config.AuthInfos) != len(names) || len(config.Contexts) != len(names) {
+		t.Fatalf("expected a user and context per namespace, got:\n%s", content)
+	}
+	if config.CurrentContext != "" {
+		t.Errorf("expected no current context, got %q", config.CurrentContext)
+	}
+	for i, context := range config.Contexts {
+		if context.Name != context.Context.Namespace {
+			t.Errorf("expected context named after the namespace, got %q", context.Name)
+		}
+		if context.Context.AuthInfo != getMemberServiceAccountName(context.Context.Namespace, owner) {
+			t.Errorf("context %d: expected the service account of the member, got %q", i, context.Context.AuthInfo)
+		}
+	}
+
+	if _, err := append(configs, configs[0]).Merge(); err == nil {
+		t.Error("expected an error merging a namespace twice")
+	}
+
+	other, err := c.MemberKubeconfig(names[1], owner, 0, KubeconfigOptions{CertificateAuthority: "other-ca"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := (Kubeconfigs{configs[0], other}).Merge(); err == nil {
+		t.Error("expected an error merging clusters with the same name and different CAs")
+	}
+}
@@ -0,0 +1,133 @@
+package kube
+
+import (
+	"strings"
+
+	"github.com/gobuffalo/uuid"
+	"github.com/kradalby/bork/models"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Every object bork creates carries the same labels and annotations,
+// naming the namespace it belongs to, its owners and the version of
+// bork that last wrote it. Cleanup selects objects by these labels,
+// objects created before they existed are still found by name.
+const (
+	// borkLabel marks an object as managed by bork, older versions
+	// only set this label
+	borkLabel = "bork"
+	// managedByLabel is the recommended Kubernetes label for the tool
+	// managing an object
+	managedByLabel = "app.kubernetes.io/managed-by"
+	managedBy      = "bork"
+	// namespaceLabel is the name of the bork namespace an object
+	// belongs to, also on cluster scoped objects
+	namespaceLabel   = "bork.namespace"
+	namespaceIDLabel = "bork.namespace-id"
+	ownerLabel       = "bork.owner"
+
+	// coOwnersAnnotation lists the IDs of the co-owners separated by
+	// commas, there can be too many for a label
+	coOwnersAnnotation = "bork.co-owners"
+	versionAnnotation  = "bork.version"
+)
+
+// Version is the version of bork written to the objects it manages,
+// set at build time with
+// -ldflags "-X github.com/kradalby/bork/kube.Version=<version>"
+var Version = "dev"
+
+// managedLabels returns the labels of an object belonging to the
+// namespace, with the extra labels of the object added.
+func managedLabels(namespace *models.Namespace, extra map[string]string) map[string]string {
+	labels := map[string]string{
+		borkLabel:      "true",
+		managedByLabel: managedBy,
+		namespaceLabel: namespace.Name,
+	}
+	if namespace.ID != uuid.Nil {
+		labels[namespaceIDLabel] = namespace.ID.String()
+	}
+	if namespace.OwnerID != uuid.Nil {
+		labels[ownerLabel] = namespace.OwnerID.String()
+	}
+
+	for key, value := range extra {
+		labels[key] = value
+	}
+	return labels
+}
+
+// managedAnnotations returns the annotations of an object belonging to
+// the namespace.
+func managedAnnotations(namespace *models.Namespace) map[string]string {
+	coOwners := make([]string, 0, len(namespace.CoOwners))
+	for _, user := range namespace.CoOwners {
+		coOwners = append(coOwners, user.ID.String())
+	}
+
+	return map[string]string{
+		coOwnersAnnotation: strings.Join(coOwners, ","),
+		versionAnnotation:  Version,
+	}
+}
+
+// managedObjectMeta returns the metadata of an object in the namespace.
+func managedObjectMeta(namespace *models.Namespace, name string, extra map[string]string) metav1.ObjectMeta {
+	return metav1.ObjectMeta{
+		Name:        name,
+		Namespace:   namespace.Name,
+		Labels:      managedLabels(namespace, extra),
+		Annotations: managedAnnotations(namespace),
+	}
+}
+
+// managedClusterObjectMeta returns the metadata of a cluster scoped
+// object belonging to the namespace.
+func managedClusterObjectMeta(namespace *models.Namespace, name string, extra map[string]string) metav1.ObjectMeta {
+	meta := managedObjectMeta(namespace, name, extra)
+	meta.Namespace = ""
+	return meta
+}
+
+// sameLabel reports if an existing object agrees with the desired labels
+// on the key. Objects created before the label existed agree with
+// anything.
+func sameLabel(existing map[string]string, key string, desired map[string]string) bool {
+	current, ok := existing[key]
+	return !ok || current == desired[key]
+}
+
+// updateManagedMetadata copies the bork labels and annotations to an
+// existing object, keeping the ones added by others. It reports if
+// anything changed.
+func updateManagedMetadata(existing *metav1.ObjectMeta, desired metav1.ObjectMeta) bool {
+	changed := false
+
+	if existing.Labels == nil {
+		existing.Labels = map[string]string{}
+	}
+	for key, value := range desired.Labels {
+		if current, ok := existing.Labels[key]; !ok || current != value {
+			existing.Labels[key] = value
+			changed = true
+		}
+	}
+
+	if existing.Annotations == nil {
+		existing.Annotations = map[string]string{}
+	}
+	for key, value := range desired.Annotations {
+		if current, ok := existing.Annotations[key]; !ok || current != value {
+			existing.Annotations[key] = value
+			changed = true
+		}
+	}
+
+	return changed
+}
+
+// namespaceSelector selects the bork objects of the namespace.
+func namespaceSelector(namespace string) string {
+	return borkLabel + "," + namespaceLabel + "=" + namespace
+}
@@ -0,0 +1,201 @@
+package kube
+
+import (
+	"testing"
+
+	"github.com/gobuffalo/uuid"
+	"github.com/kradalby/bork/models"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestProvisionLabelsEveryObject(t *testing.T) {
+	c, _ := newTestClient()
+
+	owner := testUser("owner")
+	coOwner := testUser("coowner")
+
+	ns := testNamespaceModel()
+	ns.ID = uuid.Must(uuid.NewV4())
+	ns.Owner = owner
+	ns.OwnerID = owner.ID
+	ns.CoOwners = models.Users{coOwner}
+
+	if err := c.CreateNamespaceWithServiceAccount(ns); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	objects := map[string]metav1.ObjectMeta{}
+
+	namespace, err := c.client.CoreV1().Namespaces().Get(testNamespace, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	objects["namespace"] = namespace.ObjectMeta
+
+	quota, err := c.client.CoreV1().ResourceQuotas(testNamespace).Get(getResourceQuotaName(testNamespace), metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	objects["resource quota"] = quota.ObjectMeta
+
+	limits, err := c.client.CoreV1().LimitRanges(testNamespace).Get(getLimitRangeName(testNamespace), metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	objects["limit range"] = limits.ObjectMeta
+
+	policy, err := c.client.NetworkingV1().NetworkPolicies(testNamespace).Get(getNetworkPolicyName(testNamespace), metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	objects["network policy"] = policy.ObjectMeta
+
+	serviceAccounts, err := c.client.CoreV1().ServiceAccounts(testNamespace).List(metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, serviceAccount := range serviceAccounts.Items {
+		objects["service account "+serviceAccount.Name] = serviceAccount.ObjectMeta
+	}
+
+	roles, err := c.client.RbacV1().Roles(testNamespace).List(metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, role := range roles.Items {
+		objects["role "+role.Name] = role.ObjectMeta
+	}
+
+	roleBindings, err := c.client.RbacV1().RoleBindings(testNamespace).List(metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, binding := range roleBindings.Items {
+		objects["role binding "+binding.Name] = binding.ObjectMeta
+	}
+
+	clusterRole, err := c.client.RbacV1().ClusterRoles().Get(getClusterRoleName(testNamespace), metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	objects["cluster role"] = clusterRole.ObjectMeta
+
+	clusterRoleBindings, err := c.client.RbacV1().ClusterRoleBindings().List(metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, binding := range clusterRoleBindings.Items {
+		objects["cluster role binding "+binding.Name] = binding.ObjectMeta
+	}
+
+	// namespace, quota, limits, policy, 3 service accounts, a cluster
+	// role, 3 cluster role bindings and at least a role and binding
+	if len(objects) < 13 {
+		t.Fatalf("expected all objects to be created, got %d", len(objects))
+	}
+
+	for name, meta := range objects {
+		expectedLabels := map[string]string{
+			borkLabel:        "true",
+			managedByLabel:   managedBy,
+			namespaceLabel:   testNamespace,
+			namespaceIDLabel: ns.ID.String(),
+			ownerLabel:       owner.ID.String(),
+		}
+		for key, value := range expectedLabels {
+			if meta.Labels[key] != value {
+				t.Errorf("%s: expected label %s=%q, got %q", name, key, value, meta.Labels[key])
+			}
+		}
+
+		if meta.Annotations[coOwnersAnnotation] != coOwner.ID.String() {
+			t.Errorf("%s: expected co-owners %q, got %q", name, coOwner.ID, meta.Annotations[coOwnersAnnotation])
+		}
+		if meta.Annotations[versionAnnotation] != Version {
+			t.Errorf("%s: expected version %q, got %q", name, Version, meta.Annotations[versionAnnotation])
+		}
+	}
+}
+
+func TestApplyNamespaceUpdatesCoOwners(t *testing.T) {
+	c, _ := newTestClient()
+
+	owner := testUser("owner")
+	coOwner := testUser("coowner")
+
+	ns := testNamespaceModel()
+	ns.Owner = owner
+	ns.OwnerID = owner.ID
+
+	if err := c.CreateNamespaceWithServiceAccount(ns); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	ns.CoOwners = models.Users{coOwner}
+	if err := c.ApplyNamespace(ns); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	binding, err := c.client.RbacV1().RoleBindings(testNamespace).Get(getRoleBindingName(testNamespace), metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if binding.Annotations[coOwnersAnnotation] != coOwner.ID.String() {
+		t.Errorf("expected the co-owner on existing objects, got %q", binding.Annotations[coOwnersAnnotation])
+	}
+
+	if _, err := c.client.CoreV1().ServiceAccounts(testNamespace).Get(getMemberServiceAccountName(testNamespace, coOwner), metav1.GetOptions{}); err != nil {
+		t.Errorf("expected a service account for the new co-owner: %s", err)
+	}
+}
+
+func TestUpdateManagedMetadataKeepsOtherLabels(t *testing.T) {
+	ns := testNamespaceModel()
+	existing := metav1.ObjectMeta{
+		Labels:      map[string]string{"team": "web", borkLabel: "true"},
+		Annotations: map[string]string{"note": "keep"},
+	}
+
+	if !updateManagedMetadata(&existing, managedObjectMeta(ns, "object", nil)) {
+		t.Error("expected missing labels to be reported as a change")
+	}
+	if existing.Labels["team"] != "web" || existing.Annotations["note"] != "keep" {
+		t.Errorf("expected labels and annotations of others to be kept, got %v %v", existing.Labels, existing.Annotations)
+	}
+	if existing.Labels[managedByLabel] != managedBy {
+		t.Errorf("expected managed-by label, got %v", existing.Labels)
+	}
+
+	if updateManagedMetadata(&existing, managedObjectMeta(ns, "object", nil)) {
+		t.Error("expected no change the second time")
+	}
+}
+
+func TestDeleteSelectsObjectsByLabel(t *testing.T) {
+	ns := testNamespaceModel()
+
+	renamed := &rbacv1.ClusterRoleBinding{
+		ObjectMeta: managedClusterObjectMeta(ns, "renamed-binding", nil),
+	}
+	foreign := &rbacv1.ClusterRoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: testNamespace + "-user-clusterrole-binding-copy"},
+	}
+	c, _ := newTestClient(renamed, foreign)
+
+	if err := c.CreateNamespaceWithServiceAccount(ns); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if err := c.DeleteNamespaceWithServiceAccount(testNamespace); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	bindings, err := c.client.RbacV1().ClusterRoleBindings().List(metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bindings.Items) != 1 || bindings.Items[0].Name != foreign.Name {
+		t.Errorf("expected only the unlabelled binding to be left, got %#v", bindings.Items)
+	}
+}
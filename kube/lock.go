@@ -0,0 +1,97 @@
+package kube
+
+import (
+	"errors"
+	"log"
+
+	"github.com/kradalby/bork/models"
+	corev1 "k8s.io/api/core/v1"
+	kubernetesErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// A locked namespace can not be deleted until it is unlocked again. The
+// lock is kept in the database and as an annotation on the namespace in
+// the cluster. Reconciling only ever adds the annotation, so a lock in
+// the cluster survives a database restored from before it was set, and
+// every deletion checks both.
+
+// lockedAnnotation is set to "true" on locked namespaces
+const lockedAnnotation = "bork.locked"
+
+// ErrNamespaceLocked is returned when deleting a locked namespace.
+var ErrNamespaceLocked = errors.New("namespace is locked")
+
+// LockNamespace locks or unlocks the namespace, in the database and in
+// the cluster.
+func (c *Client) LockNamespace(namespace *models.Namespace, locked bool) error {
+	if err := namespace.SetLocked(models.DB, locked); err != nil {
+		return err
+	}
+
+	namespaces := c.client.CoreV1().Namespaces()
+	existing, err := namespaces.Get(namespace.Name, metav1.GetOptions{})
+	if kubernetesErrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if isLocked(existing) == locked {
+		return nil
+	}
+	if locked {
+		setAnnotation(&existing.ObjectMeta, lockedAnnotation, "true")
+	} else {
+		delete(existing.Annotations, lockedAnnotation)
+	}
+
+	_, err = namespaces.Update(existing)
+	return err
+}
+
+// checkNotLockedInCluster returns ErrNamespaceLocked if the namespace in
+// the cluster carries the lock annotation.
+func (c *Client) checkNotLockedInCluster(name string) error {
+	existing, err := c.client.CoreV1().Namespaces().Get(name, metav1.GetOptions{})
+	if kubernetesErrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if isLocked(existing) {
+		log.Printf("[INFO] Not deleting namespace %s, it is locked", name)
+		return ErrNamespaceLocked
+	}
+	return nil
+}
+
+// lockFromCluster locks the database row of a namespace locked in the
+// cluster only, so the deleter stops picking it up. It reports if the
+// namespace is locked in the cluster.
+func (c *Client) lockFromCluster(namespace *models.Namespace) (bool, error) {
+	err := c.checkNotLockedInCluster(namespace.Name)
+	if err != ErrNamespaceLocked {
+		return false, err
+	}
+
+	log.Printf("[INFO] Locking namespace %s in the database, it is locked in the cluster", namespace.Name)
+	return true, namespace.SetLocked(models.DB, true)
+}
+
+func isLocked(namespace *corev1.Namespace) bool {
+	return namespace.Annotations[lockedAnnotation] == "true"
+}
+
+// namespaceObjectMeta returns the metadata of the namespace itself, the
+// lock annotation is only added, never removed by reconciling.
+func namespaceObjectMeta(namespace *models.Namespace) metav1.ObjectMeta {
+	meta := managedClusterObjectMeta(namespace, namespace.Name, nil)
+	if namespace.Locked {
+		meta.Annotations[lockedAnnotation] = "true"
+	}
+	return meta
+}
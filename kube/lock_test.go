@@ -0,0 +1,138 @@
+package kube
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gobuffalo/pop/nulls"
+	"github.com/kradalby/bork/models"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestLockedNamespaceIsNotDeleted(t *testing.T) {
+	c, _ := newTestClient()
+	ns := testNamespaceModel()
+	ns.Locked = true
+	if err := c.CreateNamespaceWithServiceAccount(ns); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// A database without the lock must not unlock the cluster
+	ns.Locked = false
+	if err := c.ApplyNamespace(ns); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if err := c.DeleteNamespaceWithServiceAccount(testNamespace); err != ErrNamespaceLocked {
+		t.Errorf("expected the namespace to be locked, got %v", err)
+	}
+	if remaining := remainingObjects(t, c, testNamespace); len(remaining) != 9 {
+		t.Errorf("expected nothing to be deleted, got %v", remaining)
+	}
+}
+
+func TestScheduleDeletionOfNamespaceLockedInCluster(t *testing.T) {
+	c, _ := newTestClient()
+	ns := testNamespaceModel()
+	ns.Locked = true
+	if err := c.CreateNamespaceWithServiceAccount(ns); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// Only the cluster knows about the lock
+	ns.Locked = false
+	if err := c.ScheduleNamespaceDeletion(ns); err != ErrNamespaceLocked {
+		t.Errorf("expected the namespace to be locked, got %v", err)
+	}
+	if ns.PendingDeletion() {
+		t.Error("expected the namespace not to be scheduled for deletion")
+	}
+}
+
+func TestDeletePendingNamespaceLockedInCluster(t *testing.T) {
+	requireDatabase(t)
+
+	owner := testUser("deleter-owner")
+	if err := models.DB.Create(&owner); err != nil {
+		t.Fatal(err)
+	}
+	defer models.DB.Destroy(&owner)
+
+	ns, cleanup := createTestNamespace(t, owner, "bork-test-pending-locked", nulls.Time{}, false)
+	defer cleanup()
+	if err := ns.ScheduleDeletion(models.DB, time.Now().Add(-time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the cluster knows about the lock
+	c, _ := newTestClient()
+	locked := *ns
+	locked.Locked = true
+	if err := c.CreateNamespaceWithServiceAccount(&locked); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	for run := 0; run < 2; run++ {
+		if err := c.DeletePendingNamespaces(time.Now()); err != nil {
+			t.Fatalf("run %d: unexpected error: %s", run, err)
+		}
+	}
+
+	reloaded := &models.Namespace{}
+	if err := models.DB.Find(reloaded, ns.ID); err != nil {
+		t.Fatal(err)
+	}
+	if !reloaded.Locked {
+		t.Error("expected the lock of the cluster to be stored on the row")
+	}
+
+	count, err := models.DB.Where("namespace_id = ?", ns.ID).Count(&models.AuditEvents{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 0 {
+		t.Errorf("expected no failed deletion to be audited, got %d events", count)
+	}
+}
+
+func TestDeleteOrphansSkipsLockedNamespaces(t *testing.T) {
+	locked := clusterNamespace("bork-locked")
+	locked.Annotations = map[string]string{lockedAnnotation: "true"}
+	orphan := clusterNamespace("bork-orphan")
+	c, _ := newTestClient(&locked, &orphan)
+
+	plan, err := c.planSync(models.Namespaces{}, models.Users{})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	for _, adoption := range plan.Adopt {
+		if adoption.Locked != (adoption.Name == locked.Name) {
+			t.Errorf("expected only %s to be locked, got %#v", locked.Name, adoption)
+		}
+	}
+
+	if err := c.ApplySyncPlan(plan, SyncOptions{DeleteOrphans: true}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	namespaces := c.client.CoreV1().Namespaces()
+	if _, err := namespaces.Get(locked.Name, metav1.GetOptions{}); err != nil {
+		t.Errorf("expected the locked orphan to be kept, got %s", err)
+	}
+	if _, err := namespaces.Get(orphan.Name, metav1.GetOptions{}); err == nil {
+		t.Error("expected the unlocked orphan to be deleted")
+	}
+}
+
+func TestIsLocked(t *testing.T) {
+	namespace := &corev1.Namespace{}
+	if isLocked(namespace) {
+		t.Error("expected a namespace without annotations not to be locked")
+	}
+
+	namespace.Annotations = map[string]string{lockedAnnotation: "true"}
+	if !isLocked(namespace) {
+		t.Error("expected the annotated namespace to be locked")
+	}
+}
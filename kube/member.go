@@ -0,0 +1,228 @@
+package kube
+
+import (
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gobuffalo/uuid"
+	"github.com/kradalby/bork/models"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	kubernetesErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Every owner and co-owner of a namespace, a member, gets a service
+// account of their own. It is bound to the role of the profile of the
+// member, the API server sees who made a request and a single member can
+// be cut off.
+
+const memberLabel = "bork.user"
+
+var invalidNameCharacters = regexp.MustCompile("[^a-z0-9-]+")
+
+// AddMember creates the service account and bindings of a member of the
+// namespace. If a step fails the objects created so far are removed
+// again.
+func (c *Client) AddMember(namespace *models.Namespace, user models.User) error {
+	return provision(namespace.Name, []provisionStep{c.memberStep(namespace, user)})
+}
+
+// RemoveMember removes the service account and bindings of a member,
+// which revokes their token.
+func (c *Client) RemoveMember(namespace string, user models.User) error {
+	return c.deleteMemberIfPresent(namespace, user)
+}
+
+// GetMemberToken returns a token of the service account of a member,
+// see GetToken.
+func (c *Client) GetMemberToken(namespace string, user models.User, ttl time.Duration) (string, error) {
+	return c.serviceAccountToken(namespace, getMemberServiceAccountName(namespace, user), ttl)
+}
+
+// CreateMemberConfiguration returns a kubeconfig using a token of the
+// service account of a member.
+func (c *Client) CreateMemberConfiguration(namespace string, user models.User, ttl time.Duration, options KubeconfigOptions) (string, error) {
+	config, err := c.MemberKubeconfig(namespace, user, ttl, options)
+	if err != nil {
+		return "", err
+	}
+
+	return writeKubeconfig(config)
+}
+
+// memberSteps returns a provisioning step for every member of the
+// namespace. Members not loaded from the database are skipped.
+func (c *Client) memberSteps(namespace *models.Namespace) []provisionStep {
+	steps := []provisionStep{}
+	for _, user := range namespace.Users() {
+		if user.ID == uuid.Nil {
+			continue
+		}
+		steps = append(steps, c.memberStep(namespace, user))
+	}
+	return steps
+}
+
+func (c *Client) memberStep(namespace *models.Namespace, user models.User) provisionStep {
+	profile := MemberRoleProfileFor(namespace, user)
+	return provisionStep{
+		name:     "member " + user.Username,
+		create:   func() (bool, error) { return c.ensureMember(namespace, user, profile) },
+		rollback: func() error { return c.deleteMemberIfPresent(namespace.Name, user) },
+	}
+}
+
+// ensureMember creates the service account, role binding and cluster
+// role binding of a member, binding it to the role of the profile. It
+// reports if the service account had to be created, a failure after
+// that is reported as created so the rollback removes all of it.
+func (c *Client) ensureMember(namespace *models.Namespace, user models.User, profile string) (bool, error) {
+	serviceAccountName := getMemberServiceAccountName(namespace.Name, user)
+
+	created, err := c.ensureNamedServiceAccount(namespace, serviceAccountName, memberLabels(user))
+	if err != nil {
+		return created, err
+	}
+
+	_, err = c.ensureRoleBinding(buildMemberRoleBinding(namespace, user, profile))
+	if err != nil {
+		return created, err
+	}
+
+	_, err = c.ensureClusterRoleBinding(buildMemberClusterRoleBinding(namespace, user))
+	return created, err
+}
+
+func (c *Client) deleteMemberIfPresent(namespace string, user models.User) error {
+	err := c.client.RbacV1().ClusterRoleBindings().Delete(getMemberClusterRoleBindingName(namespace, user), &metav1.DeleteOptions{})
+	if ignoreNotFound(err) != nil {
+		return err
+	}
+
+	err = c.client.RbacV1().RoleBindings(namespace).Delete(getMemberRoleBindingName(namespace, user), &metav1.DeleteOptions{})
+	if ignoreNotFound(err) != nil {
+		return err
+	}
+
+	err = c.client.CoreV1().ServiceAccounts(namespace).Delete(getMemberServiceAccountName(namespace, user), &metav1.DeleteOptions{})
+	return ignoreNotFound(err)
+}
+
+func buildMemberRoleBinding(namespace *models.Namespace, user models.User, profile string) *rbacv1.RoleBinding {
+	return &rbacv1.RoleBinding{
+		ObjectMeta: managedObjectMeta(namespace, getMemberRoleBindingName(namespace.Name, user), memberLabels(user)),
+		Subjects: []rbacv1.Subject{{
+			Name:      getMemberServiceAccountName(namespace.Name, user),
+			Kind:      "ServiceAccount",
+			Namespace: namespace.Name,
+		}},
+		RoleRef: rbacv1.RoleRef{
+			Kind:     "Role",
+			Name:     getRoleName(namespace.Name, profile),
+			APIGroup: "rbac.authorization.k8s.io",
+		}}
+}
+
+func buildMemberClusterRoleBinding(namespace *models.Namespace, user models.User) *rbacv1.ClusterRoleBinding {
+	return &rbacv1.ClusterRoleBinding{
+		ObjectMeta: managedClusterObjectMeta(namespace, getMemberClusterRoleBindingName(namespace.Name, user), memberLabels(user)),
+		Subjects: []rbacv1.Subject{{
+			Name:      getMemberServiceAccountName(namespace.Name, user),
+			Kind:      "ServiceAccount",
+			Namespace: namespace.Name,
+		}},
+		RoleRef: rbacv1.RoleRef{
+			Kind:     "ClusterRole",
+			Name:     getClusterRoleName(namespace.Name),
+			APIGroup: "rbac.authorization.k8s.io",
+		}}
+}
+
+// ensureRoleBinding creates the role binding or updates the subjects of
+// an existing one, replacing it if the role reference differs.
+func (c *Client) ensureRoleBinding(desired *rbacv1.RoleBinding) (bool, error) {
+	bindings := c.client.RbacV1().RoleBindings(desired.Namespace)
+
+	existing, err := bindings.Get(desired.Name, metav1.GetOptions{})
+	if kubernetesErrors.IsNotFound(err) {
+		_, err = bindings.Create(desired)
+		return err == nil, err
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if existing.RoleRef != desired.RoleRef {
+		if err := bindings.Delete(desired.Name, &metav1.DeleteOptions{}); err != nil {
+			return false, err
+		}
+		_, err = bindings.Create(desired)
+		return false, err
+	}
+
+	metadataChanged := updateManagedMetadata(&existing.ObjectMeta, desired.ObjectMeta)
+	if !metadataChanged && equality.Semantic.DeepEqual(existing.Subjects, desired.Subjects) {
+		return false, nil
+	}
+
+	existing.Subjects = desired.Subjects
+	_, err = bindings.Update(existing)
+	return false, err
+}
+
+// ensureClusterRoleBinding creates the cluster role binding or updates
+// the subjects of an existing one, replacing it if the role reference
+// differs.
+func (c *Client) ensureClusterRoleBinding(desired *rbacv1.ClusterRoleBinding) (bool, error) {
+	bindings := c.client.RbacV1().ClusterRoleBindings()
+
+	existing, err := bindings.Get(desired.Name, metav1.GetOptions{})
+	if kubernetesErrors.IsNotFound(err) {
+		_, err = bindings.Create(desired)
+		return err == nil, err
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if existing.RoleRef != desired.RoleRef {
+		if err := bindings.Delete(desired.Name, &metav1.DeleteOptions{}); err != nil {
+			return false, err
+		}
+		_, err = bindings.Create(desired)
+		return false, err
+	}
+
+	metadataChanged := updateManagedMetadata(&existing.ObjectMeta, desired.ObjectMeta)
+	if !metadataChanged && equality.Semantic.DeepEqual(existing.Subjects, desired.Subjects) {
+		return false, nil
+	}
+
+	existing.Subjects = desired.Subjects
+	_, err = bindings.Update(existing)
+	return false, err
+}
+
+func memberLabels(user models.User) map[string]string {
+	return map[string]string{
+		memberLabel: user.ID.String(),
+	}
+}
+
+// getMemberServiceAccountName returns a readable name for the service
+// account of a member. Part of the user ID is added as usernames can
+// end up the same after removing invalid characters.
+func getMemberServiceAccountName(namespace string, user models.User) string {
+	username := strings.Trim(invalidNameCharacters.ReplaceAllString(strings.ToLower(user.Username), "-"), "-")
+	return namespace + "-" + username + "-" + user.ID.String()[:8]
+}
+
+func getMemberRoleBindingName(namespace string, user models.User) string {
+	return getMemberServiceAccountName(namespace, user)
+}
+
+func getMemberClusterRoleBindingName(namespace string, user models.User) string {
+	return getMemberServiceAccountName(namespace, user) + "-clusterrole-binding"
+}
@@ -0,0 +1,156 @@
+package kube
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gobuffalo/uuid"
+	"github.com/kradalby/bork/models"
+	kubernetesErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func testUser(username string) models.User {
+	return models.User{
+		ID:       uuid.Must(uuid.NewV4()),
+		Username: username,
+	}
+}
+
+// memberObjects returns the kinds of member objects that exist.
+func memberObjects(t *testing.T, c *Client, namespace string, user models.User) []string {
+	t.Helper()
+
+	remaining := []string{}
+
+	_, err := c.client.CoreV1().ServiceAccounts(namespace).Get(getMemberServiceAccountName(namespace, user), metav1.GetOptions{})
+	if err == nil {
+		remaining = append(remaining, "service account")
+	} else if !kubernetesErrors.IsNotFound(err) {
+		t.Fatal(err)
+	}
+
+	_, err = c.client.RbacV1().RoleBindings(namespace).Get(getMemberRoleBindingName(namespace, user), metav1.GetOptions{})
+	if err == nil {
+		remaining = append(remaining, "role binding")
+	} else if !kubernetesErrors.IsNotFound(err) {
+		t.Fatal(err)
+	}
+
+	_, err = c.client.RbacV1().ClusterRoleBindings().Get(getMemberClusterRoleBindingName(namespace, user), metav1.GetOptions{})
+	if err == nil {
+		remaining = append(remaining, "cluster role binding")
+	} else if !kubernetesErrors.IsNotFound(err) {
+		t.Fatal(err)
+	}
+
+	return remaining
+}
+
+func TestMemberServiceAccountName(t *testing.T) {
+	user := testUser("Kristoffer Dalby")
+
+	name := getMemberServiceAccountName(testNamespace, user)
+	expected := testNamespace + "-kristoffer-dalby-" + user.ID.String()[:8]
+	if name != expected {
+		t.Errorf("expected %q, got %q", expected, name)
+	}
+}
+
+func TestProvisionCreatesMembers(t *testing.T) {
+	c, _ := newTestClient()
+
+	owner := testUser("owner")
+	coOwner := testUser("coowner")
+
+	ns := testNamespaceModel()
+	ns.Owner = owner
+	ns.OwnerID = owner.ID
+	ns.CoOwners = models.Users{coOwner}
+
+	if err := c.CreateNamespaceWithServiceAccount(ns); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	for _, user := range ns.Users() {
+		if objects := memberObjects(t, c, testNamespace, user); len(objects) != 3 {
+			t.Errorf("expected all member objects for %s, got %v", user.Username, objects)
+		}
+	}
+
+	ownerToken, err := c.GetMemberToken(testNamespace, owner, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	coOwnerToken, err := c.GetMemberToken(testNamespace, coOwner, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ownerToken == coOwnerToken {
+		t.Error("expected members to have different tokens")
+	}
+
+	config, err := c.CreateMemberConfiguration(testNamespace, coOwner, 0, KubeconfigOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(config, "token: "+coOwnerToken) || strings.Contains(config, ownerToken) {
+		t.Errorf("expected configuration with only the co-owner token, got:\n%s", config)
+	}
+
+	if err := c.DeleteNamespaceWithServiceAccount(testNamespace); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	bindings, err := c.client.RbacV1().ClusterRoleBindings().List(metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bindings.Items) != 0 {
+		t.Errorf("expected all cluster role bindings to be deleted, got %d", len(bindings.Items))
+	}
+}
+
+func TestAddAndRemoveMember(t *testing.T) {
+	c, _ := newTestClient()
+
+	if err := c.CreateNamespaceWithServiceAccount(testNamespaceModel()); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	user := testUser("coowner")
+
+	if err := c.AddMember(testNamespaceModel(), user); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if objects := memberObjects(t, c, testNamespace, user); len(objects) != 3 {
+		t.Errorf("expected all member objects, got %v", objects)
+	}
+
+	if err := c.RemoveMember(testNamespace, user); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if objects := memberObjects(t, c, testNamespace, user); len(objects) != 0 {
+		t.Errorf("expected member objects to be removed, left %v", objects)
+	}
+
+	// Removing again is a no-op
+	if err := c.RemoveMember(testNamespace, user); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+}
+
+func TestAddMemberRollback(t *testing.T) {
+	c, clientset := newTestClient()
+	failOn(clientset, "create", "clusterrolebindings")
+
+	user := testUser("coowner")
+
+	if err := c.AddMember(testNamespaceModel(), user); err == nil {
+		t.Fatal("expected adding member to fail")
+	}
+
+	if objects := memberObjects(t, c, testNamespace, user); len(objects) != 0 {
+		t.Errorf("expected rollback to remove member objects, left %v", objects)
+	}
+}
@@ -0,0 +1,248 @@
+package kube
+
+import (
+	"strings"
+
+	"github.com/gobuffalo/envy"
+	"github.com/kradalby/bork/models"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	kubernetesErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// Network policy presets owners can pick from.
+//
+// The restricted presets limit both directions. Pods can only reach the
+// pods of their own namespace and DNS in the namespaces matched by
+// BORK_DNS_NAMESPACE_SELECTOR, so traffic to other tenants is blocked on
+// the sending side too.
+const (
+	// NetworkPolicyIsolated only allows traffic within the namespace and
+	// DNS lookups
+	NetworkPolicyIsolated = "isolated"
+	// NetworkPolicyAllowIngressController also allows traffic from the
+	// namespace of the ingress controller
+	NetworkPolicyAllowIngressController = "allow-ingress-controller"
+	// NetworkPolicyOpen allows traffic from and to everywhere
+	NetworkPolicyOpen = "open"
+)
+
+// dnsPort is the port DNS egress is allowed to, over UDP and TCP
+const dnsPort = 53
+
+var knownNetworkPolicies = []string{
+	NetworkPolicyIsolated,
+	NetworkPolicyAllowIngressController,
+	NetworkPolicyOpen,
+}
+
+// DefaultNetworkPolicy returns the preset used when the owner has not
+// picked one.
+func DefaultNetworkPolicy() string {
+	return envy.Get("BORK_NETWORK_POLICY_DEFAULT", NetworkPolicyIsolated)
+}
+
+// ValidateNetworkPolicyConfig makes sure the default preset is a known
+// preset available to owners and the namespace selectors parse, so a
+// misconfiguration is found at startup rather than on the first
+// namespace created.
+func ValidateNetworkPolicyConfig() error {
+	preset := DefaultNetworkPolicy()
+	if !isKnownNetworkPolicy(preset) {
+		return errors.Errorf("unknown default network policy preset %q, known presets are %s", preset, strings.Join(knownNetworkPolicies, ", "))
+	}
+	if !IsValidNetworkPolicy(preset) {
+		return errors.Errorf("default network policy preset %q is not in BORK_NETWORK_POLICY_PRESETS", preset)
+	}
+
+	if _, err := ingressControllerSelector(); err != nil {
+		return err
+	}
+	_, err := dnsNamespaceSelector()
+	return err
+}
+
+// NetworkPolicyPresets returns the presets the administrator has made
+// available to owners.
+func NetworkPolicyPresets() []string {
+	presets := []string{}
+
+	configured := envy.Get("BORK_NETWORK_POLICY_PRESETS", strings.Join(knownNetworkPolicies, ","))
+	for _, preset := range strings.Split(configured, ",") {
+		preset = strings.TrimSpace(preset)
+		if isKnownNetworkPolicy(preset) {
+			presets = append(presets, preset)
+		}
+	}
+
+	return presets
+}
+
+// IsValidNetworkPolicy reports if the preset is available to owners.
+func IsValidNetworkPolicy(preset string) bool {
+	for _, p := range NetworkPolicyPresets() {
+		if p == preset {
+			return true
+		}
+	}
+	return false
+}
+
+// NetworkPolicyFor returns the preset in effect for the namespace.
+func NetworkPolicyFor(namespace *models.Namespace) string {
+	if namespace.NetworkPolicy == "" {
+		return DefaultNetworkPolicy()
+	}
+	return namespace.NetworkPolicy
+}
+
+// ApplyNetworkPolicy creates or updates the NetworkPolicy of the
+// namespace to match the selected preset.
+func (c *Client) ApplyNetworkPolicy(namespace *models.Namespace) error {
+	_, err := c.ensureNetworkPolicy(namespace, NetworkPolicyFor(namespace))
+	return err
+}
+
+func (c *Client) ensureNetworkPolicy(namespace *models.Namespace, preset string) (bool, error) {
+	desired, err := buildNetworkPolicy(namespace, preset)
+	if err != nil {
+		return false, err
+	}
+
+	existing, err := c.client.NetworkingV1().NetworkPolicies(namespace.Name).Get(desired.Name, metav1.GetOptions{})
+	if kubernetesErrors.IsNotFound(err) {
+		_, err = c.client.NetworkingV1().NetworkPolicies(namespace.Name).Create(desired)
+		return err == nil, err
+	}
+	if err != nil {
+		return false, err
+	}
+
+	metadataChanged := updateManagedMetadata(&existing.ObjectMeta, desired.ObjectMeta)
+	if !metadataChanged && equality.Semantic.DeepEqual(existing.Spec, desired.Spec) {
+		return false, nil
+	}
+
+	existing.Spec = desired.Spec
+	_, err = c.client.NetworkingV1().NetworkPolicies(namespace.Name).Update(existing)
+	return false, err
+}
+
+func (c *Client) deleteNetworkPolicyIfPresent(namespace string) error {
+	err := c.client.NetworkingV1().NetworkPolicies(namespace).Delete(getNetworkPolicyName(namespace), &metav1.DeleteOptions{})
+	return ignoreNotFound(err)
+}
+
+func buildNetworkPolicy(namespace *models.Namespace, preset string) (*networkingv1.NetworkPolicy, error) {
+	sameNamespace := []networkingv1.NetworkPolicyPeer{{
+		PodSelector: &metav1.LabelSelector{},
+	}}
+
+	var ingress []networkingv1.NetworkPolicyIngressRule
+	var egress []networkingv1.NetworkPolicyEgressRule
+
+	switch preset {
+	case NetworkPolicyIsolated:
+		ingress = []networkingv1.NetworkPolicyIngressRule{{From: sameNamespace}}
+
+	case NetworkPolicyAllowIngressController:
+		selector, err := ingressControllerSelector()
+		if err != nil {
+			return nil, err
+		}
+
+		ingress = []networkingv1.NetworkPolicyIngressRule{
+			{From: sameNamespace},
+			{
+				From: []networkingv1.NetworkPolicyPeer{{
+					NamespaceSelector: selector,
+				}},
+			},
+		}
+
+	case NetworkPolicyOpen:
+		// A single empty rule matches all sources and destinations
+		ingress = []networkingv1.NetworkPolicyIngressRule{{}}
+		egress = []networkingv1.NetworkPolicyEgressRule{{}}
+
+	default:
+		return nil, errors.Errorf("unknown network policy preset %q", preset)
+	}
+
+	if egress == nil {
+		dns, err := dnsEgressRule()
+		if err != nil {
+			return nil, err
+		}
+		egress = []networkingv1.NetworkPolicyEgressRule{{To: sameNamespace}, dns}
+	}
+
+	return &networkingv1.NetworkPolicy{
+		ObjectMeta: managedObjectMeta(namespace, getNetworkPolicyName(namespace.Name), nil),
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{},
+			Ingress:     ingress,
+			Egress:      egress,
+			PolicyTypes: []networkingv1.PolicyType{
+				networkingv1.PolicyTypeIngress,
+				networkingv1.PolicyTypeEgress,
+			},
+		},
+	}, nil
+}
+
+// dnsEgressRule allows DNS lookups to the pods of the namespaces
+// matched by BORK_DNS_NAMESPACE_SELECTOR.
+func dnsEgressRule() (networkingv1.NetworkPolicyEgressRule, error) {
+	selector, err := dnsNamespaceSelector()
+	if err != nil {
+		return networkingv1.NetworkPolicyEgressRule{}, err
+	}
+
+	port := intstr.FromInt(dnsPort)
+	udp := corev1.ProtocolUDP
+	tcp := corev1.ProtocolTCP
+
+	return networkingv1.NetworkPolicyEgressRule{
+		To: []networkingv1.NetworkPolicyPeer{{
+			NamespaceSelector: selector,
+		}},
+		Ports: []networkingv1.NetworkPolicyPort{
+			{Protocol: &udp, Port: &port},
+			{Protocol: &tcp, Port: &port},
+		},
+	}, nil
+}
+
+func ingressControllerSelector() (*metav1.LabelSelector, error) {
+	selector, err := metav1.ParseToLabelSelector(
+		envy.Get("BORK_INGRESS_CONTROLLER_NAMESPACE_SELECTOR", "app.kubernetes.io/name=ingress-nginx"),
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid ingress controller namespace selector")
+	}
+	return selector, nil
+}
+
+func dnsNamespaceSelector() (*metav1.LabelSelector, error) {
+	selector, err := metav1.ParseToLabelSelector(
+		envy.Get("BORK_DNS_NAMESPACE_SELECTOR", "kubernetes.io/metadata.name=kube-system"),
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid DNS namespace selector")
+	}
+	return selector, nil
+}
+
+func isKnownNetworkPolicy(preset string) bool {
+	for _, p := range knownNetworkPolicies {
+		if p == preset {
+			return true
+		}
+	}
+	return false
+}
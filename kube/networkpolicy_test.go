@@ -0,0 +1,95 @@
+package kube
+
+import (
+	"testing"
+
+	"github.com/gobuffalo/envy"
+	networkingv1 "k8s.io/api/networking/v1"
+)
+
+func TestBuildNetworkPolicy(t *testing.T) {
+	tests := []struct {
+		preset       string
+		ingressRules int
+		allowsAllIn  bool
+		allowsAllOut bool
+		allowsDNS    bool
+	}{
+		{NetworkPolicyIsolated, 1, false, false, true},
+		{NetworkPolicyAllowIngressController, 2, false, false, true},
+		{NetworkPolicyOpen, 1, true, true, false},
+	}
+
+	for _, tt := range tests {
+		policy, err := buildNetworkPolicy(testNamespaceModel(), tt.preset)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", tt.preset, err)
+		}
+		spec := policy.Spec
+
+		if len(spec.PolicyTypes) != 2 {
+			t.Errorf("%s: expected ingress and egress to be restricted, got %v", tt.preset, spec.PolicyTypes)
+		}
+		if len(spec.Ingress) != tt.ingressRules {
+			t.Errorf("%s: expected %d ingress rules, got %d", tt.preset, tt.ingressRules, len(spec.Ingress))
+		}
+		if allowsAll := len(spec.Ingress[0].From) == 0; allowsAll != tt.allowsAllIn {
+			t.Errorf("%s: expected allowing all ingress to be %t", tt.preset, tt.allowsAllIn)
+		}
+		if allowsAll := len(spec.Egress) == 1 && len(spec.Egress[0].To) == 0; allowsAll != tt.allowsAllOut {
+			t.Errorf("%s: expected allowing all egress to be %t", tt.preset, tt.allowsAllOut)
+		}
+		if hasDNS := hasDNSEgress(spec.Egress); hasDNS != tt.allowsDNS {
+			t.Errorf("%s: expected a DNS egress rule to be %t", tt.preset, tt.allowsDNS)
+		}
+	}
+}
+
+func TestBuildNetworkPolicyUnknownPreset(t *testing.T) {
+	if _, err := buildNetworkPolicy(testNamespaceModel(), "wide-open"); err == nil {
+		t.Error("expected an unknown preset to fail")
+	}
+}
+
+func TestValidateNetworkPolicyConfig(t *testing.T) {
+	defer envy.Set("BORK_NETWORK_POLICY_DEFAULT", NetworkPolicyIsolated)
+	defer envy.Set("BORK_NETWORK_POLICY_PRESETS", "isolated,allow-ingress-controller,open")
+	defer envy.Set("BORK_DNS_NAMESPACE_SELECTOR", "kubernetes.io/metadata.name=kube-system")
+
+	tests := []struct {
+		name     string
+		preset   string
+		presets  string
+		selector string
+		valid    bool
+	}{
+		{"default", NetworkPolicyIsolated, "isolated,allow-ingress-controller,open", "kubernetes.io/metadata.name=kube-system", true},
+		{"unknown default", "closed", "isolated,allow-ingress-controller,open", "kubernetes.io/metadata.name=kube-system", false},
+		{"default not offered", NetworkPolicyOpen, "isolated", "kubernetes.io/metadata.name=kube-system", false},
+		{"invalid DNS selector", NetworkPolicyIsolated, "isolated", "name in (", false},
+	}
+
+	for _, tt := range tests {
+		envy.Set("BORK_NETWORK_POLICY_DEFAULT", tt.preset)
+		envy.Set("BORK_NETWORK_POLICY_PRESETS", tt.presets)
+		envy.Set("BORK_DNS_NAMESPACE_SELECTOR", tt.selector)
+
+		if err := ValidateNetworkPolicyConfig(); (err == nil) != tt.valid {
+			t.Errorf("%s: expected valid to be %t, got %v", tt.name, tt.valid, err)
+		}
+	}
+}
+
+// hasDNSEgress reports if one of the rules only allows port 53 to the
+// DNS namespaces.
+func hasDNSEgress(rules []networkingv1.NetworkPolicyEgressRule) bool {
+	for _, rule := range rules {
+		if len(rule.To) != 1 || rule.To[0].NamespaceSelector == nil || len(rule.Ports) != 2 {
+			continue
+		}
+		if rule.Ports[0].Port.IntValue() == dnsPort && rule.Ports[1].Port.IntValue() == dnsPort {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,264 @@
+package kube
+
+import (
+	"errors"
+	"sort"
+	"strings"
+
+	"github.com/gobuffalo/envy"
+	"github.com/kradalby/bork/models"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+// When the API server is set up with the same OpenID Connect issuer as
+// bork, the members of a namespace can use kubectl with their own login
+// instead of a service account token. The role of the profile of every
+// member is then also bound to their OIDC identity, either their subject or
+// their email depending on the --oidc-username-claim of the API server.
+const (
+	// OIDCBindingsDisabled does not create any OIDC bindings
+	OIDCBindingsDisabled = ""
+	// OIDCBindingsSubject binds the subject (sub claim) of the user
+	OIDCBindingsSubject = "subject"
+	// OIDCBindingsEmail binds the email of the user
+	OIDCBindingsEmail = "email"
+)
+
+const oidcLabel = "bork.oidc"
+
+// OIDCBindings returns which claim of the user is bound, an empty
+// string if OIDC bindings are disabled.
+func OIDCBindings() string {
+	switch mode := envy.Get("BORK_OIDC_BINDINGS", OIDCBindingsDisabled); mode {
+	case OIDCBindingsSubject, OIDCBindingsEmail:
+		return mode
+	default:
+		return OIDCBindingsDisabled
+	}
+}
+
+// OIDCEnabled reports if members are bound by their OIDC identity.
+func OIDCEnabled() bool {
+	return OIDCBindings() != OIDCBindingsDisabled
+}
+
+// OIDCUsername returns the name the API server knows the user by, the
+// bound claim with the --oidc-username-prefix of the API server.
+func OIDCUsername(user models.User) string {
+	var claim string
+	switch OIDCBindings() {
+	case OIDCBindingsSubject:
+		claim = user.ProviderID
+	case OIDCBindingsEmail:
+		claim = user.Email
+	}
+
+	if claim == "" {
+		return ""
+	}
+	return envy.Get("BORK_OIDC_USERNAME_PREFIX", "") + claim
+}
+
+// oidcIssuerURL returns the issuer handed to kubectl, by default the
+// discovery URL bork uses without the well-known path.
+func oidcIssuerURL() string {
+	discovery := envy.Get("OPENID_CONNECT_DISCOVERY_URL", "")
+	return envy.Get("BORK_OIDC_ISSUER_URL", strings.TrimSuffix(discovery, "/.well-known/openid-configuration"))
+}
+
+// ApplyOIDCBindings brings the OIDC bindings of the namespace in line
+// with its members. The members must be loaded on the namespace. It
+// does nothing if OIDC bindings are disabled.
+func (c *Client) ApplyOIDCBindings(namespace *models.Namespace) error {
+	if !OIDCEnabled() {
+		return nil
+	}
+
+	_, err := c.ensureOIDCBindings(namespace)
+	return err
+}
+
+// ensureOIDCBindings creates a role binding for every profile given to
+// a member, and removes the bindings of profiles no member has. The
+// cluster role binding holds all members.
+func (c *Client) ensureOIDCBindings(namespace *models.Namespace) (bool, error) {
+	subjects := oidcSubjects(namespace)
+
+	created := false
+	all := []rbacv1.Subject{}
+	for _, profile := range subjects.profiles() {
+		bindingCreated, err := c.ensureRoleBinding(buildOIDCRoleBinding(namespace, profile, subjects[profile]))
+		created = created || bindingCreated
+		if err != nil {
+			return created, err
+		}
+		all = append(all, subjects[profile]...)
+	}
+
+	bindings, err := c.client.RbacV1().RoleBindings(namespace.Name).List(metav1.ListOptions{LabelSelector: oidcLabel})
+	if err != nil {
+		return created, err
+	}
+
+	for _, binding := range bindings.Items {
+		if _, ok := subjects[binding.Labels[roleProfileLabel]]; ok {
+			continue
+		}
+		err := c.client.RbacV1().RoleBindings(namespace.Name).Delete(binding.Name, &metav1.DeleteOptions{})
+		if ignoreNotFound(err) != nil {
+			return created, err
+		}
+	}
+
+	_, err = c.ensureClusterRoleBinding(buildOIDCClusterRoleBinding(namespace, all))
+	return created, err
+}
+
+func (c *Client) deleteOIDCBindingsIfPresent(namespace string) error {
+	err := c.client.RbacV1().ClusterRoleBindings().Delete(getOIDCClusterRoleBindingName(namespace), &metav1.DeleteOptions{})
+	if ignoreNotFound(err) != nil {
+		return err
+	}
+
+	bindings, err := c.client.RbacV1().RoleBindings(namespace).List(metav1.ListOptions{LabelSelector: oidcLabel})
+	if err != nil {
+		return err
+	}
+
+	for _, binding := range bindings.Items {
+		err := c.client.RbacV1().RoleBindings(namespace).Delete(binding.Name, &metav1.DeleteOptions{})
+		if ignoreNotFound(err) != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// oidcSteps returns the step creating the OIDC bindings, or no steps if
+// they are disabled.
+func (c *Client) oidcSteps(namespace *models.Namespace) []provisionStep {
+	if !OIDCEnabled() {
+		return []provisionStep{}
+	}
+
+	return []provisionStep{{
+		name:     "oidc bindings",
+		create:   func() (bool, error) { return c.ensureOIDCBindings(namespace) },
+		rollback: func() error { return c.deleteOIDCBindingsIfPresent(namespace.Name) },
+	}}
+}
+
+// oidcProfileSubjects maps a role profile to the User subjects of the
+// members given that profile.
+type oidcProfileSubjects map[string][]rbacv1.Subject
+
+func (s oidcProfileSubjects) profiles() []string {
+	profiles := make([]string, 0, len(s))
+	for profile := range s {
+		profiles = append(profiles, profile)
+	}
+	sort.Strings(profiles)
+	return profiles
+}
+
+// oidcSubjects returns a User subject for every member of the namespace
+// with a known identity, grouped by the profile of the member.
+func oidcSubjects(namespace *models.Namespace) oidcProfileSubjects {
+	subjects := oidcProfileSubjects{}
+	for _, user := range namespace.Users() {
+		username := OIDCUsername(user)
+		if username == "" {
+			continue
+		}
+
+		profile := MemberRoleProfileFor(namespace, user)
+		subjects[profile] = append(subjects[profile], rbacv1.Subject{
+			Kind:     "User",
+			Name:     username,
+			APIGroup: "rbac.authorization.k8s.io",
+		})
+	}
+	return subjects
+}
+
+func buildOIDCRoleBinding(namespace *models.Namespace, profile string, subjects []rbacv1.Subject) *rbacv1.RoleBinding {
+	return &rbacv1.RoleBinding{
+		ObjectMeta: managedObjectMeta(namespace, getOIDCRoleBindingName(namespace.Name, profile), map[string]string{
+			oidcLabel:        "true",
+			roleProfileLabel: profile,
+		}),
+		Subjects: subjects,
+		RoleRef: rbacv1.RoleRef{
+			Kind:     "Role",
+			Name:     getRoleName(namespace.Name, profile),
+			APIGroup: "rbac.authorization.k8s.io",
+		}}
+}
+
+func buildOIDCClusterRoleBinding(namespace *models.Namespace, subjects []rbacv1.Subject) *rbacv1.ClusterRoleBinding {
+	return &rbacv1.ClusterRoleBinding{
+		ObjectMeta: managedClusterObjectMeta(namespace, getOIDCClusterRoleBindingName(namespace.Name), map[string]string{
+			oidcLabel: "true",
+		}),
+		Subjects: subjects,
+		RoleRef: rbacv1.RoleRef{
+			Kind:     "ClusterRole",
+			Name:     getClusterRoleName(namespace.Name),
+			APIGroup: "rbac.authorization.k8s.io",
+		}}
+}
+
+// ErrOIDCClientNotConfigured is returned when an OIDC kubeconfig is
+// asked for without a kubectl client set up.
+var ErrOIDCClientNotConfigured = errors.New("no OpenID Connect client for kubectl, set BORK_OIDC_KUBECTL_CLIENT_ID")
+
+// OIDCKubectlClientID returns the client kubectl logs in with. It has
+// to be a public client without a secret, the confidential client of
+// bork itself is never handed out.
+func OIDCKubectlClientID() string {
+	return envy.Get("BORK_OIDC_KUBECTL_CLIENT_ID", "")
+}
+
+// CreateOIDCConfiguration returns a kubeconfig where kubectl runs its
+// own OpenID Connect login through the oidc-login plugin
+// (https://github.com/int128/kubelogin) with the kubectl client. No
+// secret or token of bork ends up in the kubeconfig.
+func (c *Client) CreateOIDCConfiguration(namespace string, user models.User, options KubeconfigOptions) (string, error) {
+	clientID := OIDCKubectlClientID()
+	if clientID == "" {
+		return "", ErrOIDCClientNotConfigured
+	}
+
+	args := []string{
+		"oidc-login",
+		"get-token",
+		"--oidc-issuer-url=" + oidcIssuerURL(),
+		"--oidc-client-id=" + clientID,
+	}
+	// The email is only in the ID token when asked for
+	if OIDCBindings() == OIDCBindingsEmail {
+		args = append(args, "--oidc-extra-scope=email")
+	}
+
+	authInfo := clientcmdapi.NewAuthInfo()
+	authInfo.Exec = &clientcmdapi.ExecConfig{
+		APIVersion: "client.authentication.k8s.io/v1beta1",
+		Command:    "kubectl",
+		Args:       args,
+	}
+
+	return c.createConfiguration(namespace, kubeconfigCredentials{
+		userName: OIDCUsername(user),
+		authInfo: authInfo,
+	}, options)
+}
+
+func getOIDCRoleBindingName(namespace string, profile string) string {
+	return namespace + "-oidc-" + profile
+}
+
+func getOIDCClusterRoleBindingName(namespace string) string {
+	return namespace + "-oidc-clusterrole-binding"
+}
@@ -0,0 +1,160 @@
+package kube
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gobuffalo/envy"
+	"github.com/kradalby/bork/models"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func withOIDCBindings(mode string, prefix string) func() {
+	envy.Set("BORK_OIDC_BINDINGS", mode)
+	envy.Set("BORK_OIDC_USERNAME_PREFIX", prefix)
+	return func() {
+		envy.Set("BORK_OIDC_BINDINGS", "")
+		envy.Set("BORK_OIDC_USERNAME_PREFIX", "")
+	}
+}
+
+func TestOIDCUsername(t *testing.T) {
+	user := models.User{ProviderID: "1234", Email: "user@example.com"}
+
+	tests := []struct {
+		mode     string
+		prefix   string
+		expected string
+	}{
+		{OIDCBindingsDisabled, "", ""},
+		{OIDCBindingsSubject, "", "1234"},
+		{OIDCBindingsSubject, "oidc:", "oidc:1234"},
+		{OIDCBindingsEmail, "", "user@example.com"},
+		{"unknown", "", ""},
+	}
+
+	for _, tt := range tests {
+		reset := withOIDCBindings(tt.mode, tt.prefix)
+		if username := OIDCUsername(user); username != tt.expected {
+			t.Errorf("mode %q: expected %q, got %q", tt.mode, tt.expected, username)
+		}
+		reset()
+	}
+}
+
+func TestOIDCBindingsFollowMembers(t *testing.T) {
+	defer withOIDCBindings(OIDCBindingsEmail, "")()
+
+	c, _ := newTestClient()
+
+	owner := testUser("owner")
+	owner.Email = "owner@example.com"
+	coOwner := testUser("coowner")
+	coOwner.Email = "coowner@example.com"
+
+	ns := testNamespaceModel()
+	ns.Owner = owner
+	ns.OwnerID = owner.ID
+	ns.CoOwners = models.Users{coOwner}
+
+	if err := c.CreateNamespaceWithServiceAccount(ns); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	binding, err := c.client.RbacV1().RoleBindings(testNamespace).Get(getOIDCRoleBindingName(testNamespace, RoleProfileDeveloper), metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(binding.Subjects) != 2 || binding.Subjects[1].Name != "coowner@example.com" {
+		t.Errorf("expected owner and co-owner to be bound, got %#v", binding.Subjects)
+	}
+
+	ns.CoOwners = models.Users{}
+	if err := c.ApplyOIDCBindings(ns); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	clusterBinding, err := c.client.RbacV1().ClusterRoleBindings().Get(getOIDCClusterRoleBindingName(testNamespace), metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(clusterBinding.Subjects) != 1 || clusterBinding.Subjects[0].Name != "owner@example.com" {
+		t.Errorf("expected only the owner to be bound, got %#v", clusterBinding.Subjects)
+	}
+
+	if err := c.DeleteNamespaceWithServiceAccount(testNamespace); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	bindings, err := c.client.RbacV1().ClusterRoleBindings().List(metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bindings.Items) != 0 {
+		t.Errorf("expected all cluster role bindings to be deleted, got %d", len(bindings.Items))
+	}
+}
+
+func TestOIDCDisabledCreatesNoBindings(t *testing.T) {
+	c, _ := newTestClient()
+
+	if err := c.CreateNamespaceWithServiceAccount(testNamespaceModel()); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	_, err := c.client.RbacV1().RoleBindings(testNamespace).Get(getOIDCRoleBindingName(testNamespace, RoleProfileDeveloper), metav1.GetOptions{})
+	if err == nil {
+		t.Error("expected no OIDC role binding when disabled")
+	}
+}
+
+func TestOIDCConfiguration(t *testing.T) {
+	defer withOIDCBindings(OIDCBindingsSubject, "")()
+	envy.Set("OPENID_CONNECT_DISCOVERY_URL", "https://dex.example.com/dex/.well-known/openid-configuration")
+	envy.Set("OPENID_CONNECT_KEY", "bork")
+	envy.Set("OPENID_CONNECT_SECRET", "bork-secret")
+	envy.Set("BORK_OIDC_KUBECTL_CLIENT_ID", "kubectl")
+	defer envy.Set("BORK_OIDC_KUBECTL_CLIENT_ID", "")
+
+	c, _ := newTestClient()
+	if err := c.CreateNamespaceWithServiceAccount(testNamespaceModel()); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	user := testUser("owner")
+	user.ProviderID = "1234"
+
+	config, err := c.CreateOIDCConfiguration(testNamespace, user, KubeconfigOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	for _, expected := range []string{
+		"command: kubectl",
+		"- oidc-login",
+		"- --oidc-issuer-url=https://dex.example.com/dex\n",
+		"- --oidc-client-id=kubectl\n",
+		`user: "1234"`,
+	} {
+		if !strings.Contains(config, expected) {
+			t.Errorf("expected configuration to contain %q, got:\n%s", expected, config)
+		}
+	}
+
+	// Nothing of the confidential client of bork is handed out
+	for _, unexpected := range []string{"bork-secret", "client-secret", "refresh-token", "oidc-client-id=bork\n"} {
+		if strings.Contains(config, unexpected) {
+			t.Errorf("expected configuration not to contain %q, got:\n%s", unexpected, config)
+		}
+	}
+}
+
+func TestOIDCConfigurationNeedsKubectlClient(t *testing.T) {
+	defer withOIDCBindings(OIDCBindingsSubject, "")()
+
+	c, _ := newTestClient()
+	_, err := c.CreateOIDCConfiguration(testNamespace, testUser("owner"), KubeconfigOptions{})
+	if err != ErrOIDCClientNotConfigured {
+		t.Errorf("expected the missing kubectl client to be reported, got %v", err)
+	}
+}
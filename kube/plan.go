@@ -0,0 +1,164 @@
+package kube
+
+import (
+	"log"
+
+	"github.com/kradalby/bork/models"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// SyncPlan lists what syncing the namespaces of a cluster changes,
+// so it can be reviewed before anything is created or deleted.
+type SyncPlan struct {
+	Cluster string `json:"cluster"`
+	// Create are the namespaces in the database missing from the cluster
+	Create []string `json:"create"`
+	// Delete are the bork namespaces in the cluster missing from the
+	// database, the orphans
+	Delete []string `json:"delete"`
+	// Adopt are the orphans with the database rows rebuilt from them
+	Adopt []Adoption `json:"adopt"`
+	// Repair are the namespaces with objects that drifted from the
+	// database, Drift lists the objects
+	Repair []string      `json:"repair"`
+	Drift  []ObjectDrift `json:"drift"`
+
+	create []models.Namespace
+	delete []corev1.Namespace
+	repair []models.Namespace
+}
+
+// IsEmpty reports if syncing changes nothing.
+func (p *SyncPlan) IsEmpty() bool {
+	return len(p.Create) == 0 && len(p.Delete) == 0 && len(p.Repair) == 0
+}
+
+// SyncOptions chooses what happens to the orphaned namespaces when a
+// plan is applied. Orphans are kept unless asked otherwise.
+type SyncOptions struct {
+	// Adopt stores the rebuilt rows of the orphans that can be adopted
+	Adopt bool
+	// DeleteOrphans deletes the orphans, with Adopt only the ones that
+	// could not be adopted
+	DeleteOrphans bool
+}
+
+// PlanSync compares the namespaces of the cluster with the database
+// without changing anything.
+func (c *Client) PlanSync() (*SyncPlan, error) {
+	namespacesFromDatabase, err := c.getNamespacesFromDatabase()
+	if err != nil {
+		return nil, err
+	}
+
+	users := models.Users{}
+	if err := models.DB.All(&users); err != nil {
+		return nil, err
+	}
+
+	return c.planSync(namespacesFromDatabase, users)
+}
+
+func (c *Client) planSync(namespacesFromDatabase models.Namespaces, users models.Users) (*SyncPlan, error) {
+	namespacesFromCluster, err := c.getAllNamespaces()
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &SyncPlan{
+		Cluster: c.ClusterName(),
+		Create:  []string{},
+		Delete:  []string{},
+		Adopt:   []Adoption{},
+		Repair:  []string{},
+		Drift:   []ObjectDrift{},
+	}
+	plan.create, plan.delete = diffNamespaces(namespacesFromCluster.Items, namespacesFromDatabase)
+	for _, ns := range plan.create {
+		plan.Create = append(plan.Create, ns.Name)
+	}
+	for _, ns := range plan.delete {
+		plan.Delete = append(plan.Delete, ns.Name)
+	}
+
+	plan.Adopt, err = c.planAdoptions(plan.delete, users)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range namespacesFromDatabase {
+		ns := namespacesFromDatabase[i]
+		if !isNamespaceInClusterList(namespacesFromCluster.Items, ns.Name) {
+			continue
+		}
+
+		drift, err := c.FindDrift(&ns)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(drift) != 0 {
+			plan.repair = append(plan.repair, ns)
+			plan.Repair = append(plan.Repair, ns.Name)
+			plan.Drift = append(plan.Drift, drift...)
+		}
+	}
+
+	return plan, nil
+}
+
+// ApplySyncPlan creates the missing namespaces and repairs the drifted
+// ones of the plan. The orphaned namespaces are adopted or deleted as
+// the options tell. The shared cluster role of older versions is
+// deleted once nothing is bound to it.
+func (c *Client) ApplySyncPlan(plan *SyncPlan, options SyncOptions) error {
+	adopted := []string{}
+	if options.Adopt {
+		if err := AdoptNamespaces(models.DB, plan.Adopt); err != nil {
+			return err
+		}
+		for _, adoption := range plan.Adopt {
+			if adoption.Namespace != nil {
+				adopted = append(adopted, adoption.Name)
+			}
+		}
+	}
+
+	deleteList := []corev1.Namespace{}
+	for _, ns := range plan.delete {
+		if options.DeleteOrphans && !contains(adopted, ns.Name) {
+			deleteList = append(deleteList, ns)
+		}
+	}
+
+	if err := c.Sync(plan.create, deleteList); err != nil {
+		return err
+	}
+
+	for i := range plan.repair {
+		ns := &plan.repair[i]
+		if err := c.RepairDrift(ns, plan.namespaceDrift(ns.Name)); err != nil {
+			return err
+		}
+	}
+
+	deleted, err := c.DeleteLegacyClusterRole()
+	if err != nil {
+		return err
+	}
+	if deleted {
+		log.Printf("[INFO] Deleted cluster role %s of older versions", legacyClusterRoleName)
+	}
+	return nil
+}
+
+// namespaceDrift returns the drift of a single namespace of the plan.
+func (p *SyncPlan) namespaceDrift(namespace string) []ObjectDrift {
+	drift := []ObjectDrift{}
+	for _, object := range p.Drift {
+		if object.Namespace == namespace {
+			drift = append(drift, object)
+		}
+	}
+	return drift
+}
@@ -0,0 +1,93 @@
+package kube
+
+import (
+	"testing"
+
+	"github.com/kradalby/bork/models"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestPlanSyncChangesNothing(t *testing.T) {
+	orphan := clusterNamespace("bork-orphan")
+	c, _ := newTestClient(&orphan, buildClusterRole(&models.Namespace{Name: orphan.Name}))
+
+	plan, err := c.planSync(models.Namespaces{*testNamespaceModel()}, models.Users{})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if plan.Cluster != "default" {
+		t.Errorf("expected the default cluster, got %q", plan.Cluster)
+	}
+	if len(plan.Create) != 1 || plan.Create[0] != testNamespace {
+		t.Errorf("expected %s to be created, got %v", testNamespace, plan.Create)
+	}
+	if len(plan.Delete) != 1 || plan.Delete[0] != orphan.Name {
+		t.Errorf("expected %s to be deleted, got %v", orphan.Name, plan.Delete)
+	}
+
+	if remaining := remainingObjects(t, c, testNamespace); len(remaining) != 0 {
+		t.Errorf("expected planning to create nothing, got %v", remaining)
+	}
+	if _, err := c.client.CoreV1().Namespaces().Get(orphan.Name, metav1.GetOptions{}); err != nil {
+		t.Errorf("expected planning to delete nothing, got %s", err)
+	}
+}
+
+func TestPlanSyncRepairsDeletedQuotas(t *testing.T) {
+	c, _ := newTestClient()
+	ns := testNamespaceModel()
+	if err := c.CreateNamespaceWithServiceAccount(ns); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	err := c.client.CoreV1().LimitRanges(testNamespace).Delete(getLimitRangeName(testNamespace), &metav1.DeleteOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	plan, err := c.planSync(models.Namespaces{*ns}, models.Users{})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(plan.Drift) != 1 || !plan.Drift[0].Missing || !(len(plan.Create) == 0 && len(plan.Delete) == 0) {
+		t.Fatalf("expected only the limit range to be repaired, got %#v", plan)
+	}
+
+	if err := c.ApplySyncPlan(plan, SyncOptions{}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if _, err := c.client.CoreV1().LimitRanges(testNamespace).Get(getLimitRangeName(testNamespace), metav1.GetOptions{}); err != nil {
+		t.Errorf("expected the limit range to be repaired, got %s", err)
+	}
+}
+
+func TestApplySyncPlanDeletesOnlyWhenConfirmed(t *testing.T) {
+	orphan := clusterNamespace("bork-orphan")
+	c, _ := newTestClient(&orphan, buildClusterRole(&models.Namespace{Name: orphan.Name}))
+
+	plan, err := c.planSync(models.Namespaces{*testNamespaceModel()}, models.Users{})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if err := c.ApplySyncPlan(plan, SyncOptions{}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if _, err := c.client.CoreV1().Namespaces().Get(orphan.Name, metav1.GetOptions{}); err != nil {
+		t.Errorf("expected the orphan to be kept, got %s", err)
+	}
+	if remaining := remainingObjects(t, c, testNamespace); len(remaining) != 9 {
+		t.Errorf("expected the missing namespace to be created, got %v", remaining)
+	}
+
+	if err := c.ApplySyncPlan(plan, SyncOptions{DeleteOrphans: true}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if _, err := c.client.CoreV1().Namespaces().Get(orphan.Name, metav1.GetOptions{}); err == nil {
+		t.Error("expected the orphan to be deleted")
+	}
+	if _, err := c.client.RbacV1().ClusterRoles().Get(getClusterRoleName(orphan.Name), metav1.GetOptions{}); err == nil {
+		t.Error("expected the cluster role of the orphan to be deleted")
+	}
+}
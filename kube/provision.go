@@ -0,0 +1,150 @@
+package kube
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/kradalby/bork/models"
+)
+
+// ProvisionError is returned when one of the steps creating a namespace
+// fails. The steps completed before the failure have been rolled back,
+// errors from the rollback itself are kept in RollbackErrors.
+type ProvisionError struct {
+	Namespace      string
+	Step           string
+	Err            error
+	RollbackErrors []error
+}
+
+func (e *ProvisionError) Error() string {
+	msg := fmt.Sprintf("provisioning namespace %s failed at step %q: %s", e.Namespace, e.Step, e.Err)
+
+	if len(e.RollbackErrors) > 0 {
+		errs := make([]string, len(e.RollbackErrors))
+		for i, err := range e.RollbackErrors {
+			errs[i] = err.Error()
+		}
+		msg += fmt.Sprintf(" (rollback failed: %s)", strings.Join(errs, "; "))
+	}
+
+	return msg
+}
+
+// Cause returns the error of the failed step.
+func (e *ProvisionError) Cause() error {
+	return e.Err
+}
+
+// provisionStep is a single step of creating a namespace together with
+// the action undoing it. create brings the object to the desired state
+// and reports if it had to be created. Only created objects are rolled
+// back, objects that existed before are left in place.
+type provisionStep struct {
+	name     string
+	create   func() (bool, error)
+	rollback func() error
+}
+
+// provisionSteps returns the steps needed to create a namespace, its
+// service account and the service accounts of its members in the
+// cluster, in order.
+func (c *Client) provisionSteps(namespace *models.Namespace) []provisionStep {
+	name := namespace.Name
+
+	steps := []provisionStep{
+		{
+			name:     "namespace",
+			create:   func() (bool, error) { return c.ensureNamespace(namespace) },
+			rollback: func() error { return c.deleteNamespaceIfPresent(name) },
+		},
+		{
+			name:     "resource quota",
+			create:   func() (bool, error) { return c.ensureResourceQuota(namespace, QuotaFor(namespace)) },
+			rollback: func() error { return c.deleteResourceQuotaIfPresent(name) },
+		},
+		{
+			name:     "limit range",
+			create:   func() (bool, error) { return c.ensureLimitRange(namespace, QuotaFor(namespace)) },
+			rollback: func() error { return c.deleteLimitRangeIfPresent(name) },
+		},
+		{
+			name:     "network policy",
+			create:   func() (bool, error) { return c.ensureNetworkPolicy(namespace, NetworkPolicyFor(namespace)) },
+			rollback: func() error { return c.deleteNetworkPolicyIfPresent(name) },
+		},
+		{
+			name:     "service account",
+			create:   func() (bool, error) { return c.ensureServiceAccount(namespace) },
+			rollback: func() error { return c.deleteServiceAccountIfPresent(name) },
+		},
+		{
+			name:     "role",
+			create:   func() (bool, error) { return c.ensureRoles(namespace) },
+			rollback: func() error { return c.deleteRolesIfPresent(name) },
+		},
+		{
+			name:     "cluster role",
+			create:   func() (bool, error) { return c.ensureClusterRole(namespace) },
+			rollback: func() error { return c.deleteClusterRoleIfPresent(name) },
+		},
+		{
+			name:     "cluster role binding",
+			create:   func() (bool, error) { return c.ensureServiceAccountClusterRoleBinding(namespace) },
+			rollback: func() error { return c.deleteServiceAccountClusterRoleBindingIfPresent(name) },
+		},
+		{
+			name:     "role binding",
+			create:   func() (bool, error) { return c.ensureServiceAccountRoleBinding(namespace, RoleProfileFor(namespace)) },
+			rollback: func() error { return c.deleteServiceAccountRoleBindingIfPresent(name) },
+		},
+		c.deployStep(namespace),
+	}
+
+	steps = append(steps, c.memberSteps(namespace)...)
+	return append(steps, c.oidcSteps(namespace)...)
+}
+
+// provision runs the steps in order. If a step fails, the objects
+// created by the completed steps, and by the failing step itself, are
+// removed in reverse order and a ProvisionError naming the failed step
+// is returned.
+func provision(namespace string, steps []provisionStep) error {
+	created := make([]bool, len(steps))
+
+	for i, step := range steps {
+		var err error
+		created[i], err = step.create()
+		if err == nil {
+			continue
+		}
+
+		log.Printf("[Error] Provisioning %s failed at step %s: %#v", namespace, step.name, err)
+
+		provisionErr := &ProvisionError{
+			Namespace: namespace,
+			Step:      step.name,
+			Err:       err,
+		}
+
+		for j := i; j >= 0; j-- {
+			if !created[j] || steps[j].rollback == nil {
+				continue
+			}
+
+			log.Printf("[INFO] Rolling back step %s of %s", steps[j].name, namespace)
+			if rollbackErr := steps[j].rollback(); rollbackErr != nil {
+				log.Printf("[Error] Rollback of step %s failed: %#v", steps[j].name, rollbackErr)
+				provisionErr.RollbackErrors = append(
+					provisionErr.RollbackErrors,
+					fmt.Errorf("%s: %s", steps[j].name, rollbackErr),
+				)
+			}
+		}
+
+		return provisionErr
+	}
+
+	return nil
+}
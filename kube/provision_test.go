@@ -0,0 +1,357 @@
+package kube
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/gobuffalo/uuid"
+	"github.com/kradalby/bork/models"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	kubernetesErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	restclient "k8s.io/client-go/rest"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+const (
+	testNamespace = "bork-test-ns"
+	testEndpoint  = "https://kubernetes.example.com"
+)
+
+// newTestClient returns a client backed by a fake clientset. As the
+// fake has no token controller, creating a service account also adds
+// its token secret to the tracker, and token secrets are filled in
+// when created.
+func newTestClient(objects ...runtime.Object) (*Client, *fake.Clientset) {
+	tracker := k8stesting.NewObjectTracker(scheme.Scheme, scheme.Codecs.UniversalDecoder())
+	for _, obj := range objects {
+		if err := tracker.Add(obj); err != nil {
+			panic(err)
+		}
+	}
+
+	clientset := &fake.Clientset{}
+	clientset.AddReactor("*", "*", k8stesting.ObjectReaction(tracker))
+	clientset.AddWatchReactor("*", func(action k8stesting.Action) (bool, watch.Interface, error) {
+		w, err := tracker.Watch(action.GetResource(), action.GetNamespace())
+		return err == nil, w, err
+	})
+
+	clientset.PrependReactor("create", "serviceaccounts", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if action.GetSubresource() != "" {
+			return false, nil, nil
+		}
+		serviceAccount := action.(k8stesting.CreateAction).GetObject().(*corev1.ServiceAccount)
+		secret := testTokenSecret(action.GetNamespace(), serviceAccount.Name)
+		serviceAccount.Secrets = append(serviceAccount.Secrets, corev1.ObjectReference{Name: secret.Name})
+		return false, nil, tracker.Add(secret)
+	})
+
+	clientset.PrependReactor("create", "secrets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		secret := action.(k8stesting.CreateAction).GetObject().(*corev1.Secret)
+		if secret.Type == corev1.SecretTypeServiceAccountToken && len(secret.Data) == 0 {
+			secret.Data = map[string][]byte{
+				"token":  []byte("token-" + secret.Name),
+				"ca.crt": []byte("certificate"),
+			}
+		}
+		return false, nil, nil
+	})
+
+	c := NewClient(clientset, &restclient.Config{Host: testEndpoint})
+	c.roleProfiles = func() (RoleProfiles, error) { return BuiltinRoleProfiles(), nil }
+	return c, clientset
+}
+
+func testTokenSecret(namespace string, serviceAccountName string) *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      serviceAccountName + "-token-abcde",
+			Namespace: namespace,
+			Annotations: map[string]string{
+				serviceAccountNameAnnotation: serviceAccountName,
+			},
+		},
+		Type: corev1.SecretTypeServiceAccountToken,
+		Data: map[string][]byte{
+			"token":  []byte("token-" + serviceAccountName),
+			"ca.crt": []byte("certificate"),
+		},
+	}
+}
+
+func failOn(clientset *fake.Clientset, verb string, resource string) {
+	clientset.PrependReactor(verb, resource, func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.New("injected failure")
+	})
+}
+
+func testNamespaceModel() *models.Namespace {
+	return &models.Namespace{
+		Name:    testNamespace,
+		OwnerID: uuid.Must(uuid.NewV4()),
+	}
+}
+
+// remainingObjects returns the kinds of managed objects that still
+// exist in the fake cluster.
+func remainingObjects(t *testing.T, c *Client, namespace string) []string {
+	t.Helper()
+
+	getters := map[string]func() error{
+		"namespace": func() error {
+			_, err := c.client.CoreV1().Namespaces().Get(namespace, metav1.GetOptions{})
+			return err
+		},
+		"resource quota": func() error {
+			_, err := c.client.CoreV1().ResourceQuotas(namespace).Get(getResourceQuotaName(namespace), metav1.GetOptions{})
+			return err
+		},
+		"limit range": func() error {
+			_, err := c.client.CoreV1().LimitRanges(namespace).Get(getLimitRangeName(namespace), metav1.GetOptions{})
+			return err
+		},
+		"network policy": func() error {
+			_, err := c.client.NetworkingV1().NetworkPolicies(namespace).Get(getNetworkPolicyName(namespace), metav1.GetOptions{})
+			return err
+		},
+		"service account": func() error {
+			_, err := c.client.CoreV1().ServiceAccounts(namespace).Get(getServiceAccountName(namespace), metav1.GetOptions{})
+			return err
+		},
+		"role": func() error {
+			roles, err := c.client.RbacV1().Roles(namespace).List(metav1.ListOptions{})
+			if err == nil && len(roles.Items) == 0 {
+				return kubernetesErrors.NewNotFound(rbacv1.Resource("roles"), namespace)
+			}
+			return err
+		},
+		"role binding": func() error {
+			_, err := c.client.RbacV1().RoleBindings(namespace).Get(getRoleBindingName(namespace), metav1.GetOptions{})
+			return err
+		},
+		"cluster role": func() error {
+			_, err := c.client.RbacV1().ClusterRoles().Get(getClusterRoleName(namespace), metav1.GetOptions{})
+			return err
+		},
+		"cluster role binding": func() error {
+			_, err := c.client.RbacV1().ClusterRoleBindings().Get(getClusterRoleBindingName(namespace), metav1.GetOptions{})
+			return err
+		},
+	}
+
+	remaining := []string{}
+	for kind, get := range getters {
+		err := get()
+		if err == nil {
+			remaining = append(remaining, kind)
+			continue
+		}
+		if !kubernetesErrors.IsNotFound(err) {
+			t.Fatalf("unexpected error getting %s: %s", kind, err)
+		}
+	}
+	return remaining
+}
+
+func TestProvisionCreatesAllObjects(t *testing.T) {
+	c, _ := newTestClient()
+
+	err := c.CreateNamespaceWithServiceAccount(testNamespaceModel())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	remaining := remainingObjects(t, c, testNamespace)
+	if len(remaining) != 9 {
+		t.Errorf("expected all 9 objects to be created, got %v", remaining)
+	}
+}
+
+func TestProvisionRollbackOnFailure(t *testing.T) {
+	tests := []struct {
+		step     string
+		resource string
+	}{
+		{"namespace", "namespaces"},
+		{"resource quota", "resourcequotas"},
+		{"limit range", "limitranges"},
+		{"network policy", "networkpolicies"},
+		{"service account", "serviceaccounts"},
+		{"role", "roles"},
+		{"cluster role", "clusterroles"},
+		{"cluster role binding", "clusterrolebindings"},
+		{"role binding", "rolebindings"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.resource, func(t *testing.T) {
+			c, clientset := newTestClient()
+			failOn(clientset, "create", tt.resource)
+
+			err := c.CreateNamespaceWithServiceAccount(testNamespaceModel())
+			if err == nil {
+				t.Fatal("expected provisioning to fail")
+			}
+
+			provisionErr, ok := err.(*ProvisionError)
+			if !ok {
+				t.Fatalf("expected *ProvisionError, got %T: %s", err, err)
+			}
+
+			if provisionErr.Step != tt.step {
+				t.Errorf("expected failed step %q, got %q", tt.step, provisionErr.Step)
+			}
+
+			if len(provisionErr.RollbackErrors) != 0 {
+				t.Errorf("expected clean rollback, got %v", provisionErr.RollbackErrors)
+			}
+
+			if remaining := remainingObjects(t, c, testNamespace); len(remaining) != 0 {
+				t.Errorf("expected rollback to remove everything, left %v", remaining)
+			}
+		})
+	}
+}
+
+func TestProvisionDoesNotRemoveExistingNamespace(t *testing.T) {
+	c, _ := newTestClient(&corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{Name: testNamespace},
+	})
+
+	err := c.CreateNamespaceWithServiceAccount(testNamespaceModel())
+
+	provisionErr, ok := err.(*ProvisionError)
+	if !ok {
+		t.Fatalf("expected *ProvisionError, got %T: %v", err, err)
+	}
+
+	if provisionErr.Step != "namespace" {
+		t.Errorf("expected failed step namespace, got %q", provisionErr.Step)
+	}
+
+	_, err = c.client.CoreV1().Namespaces().Get(testNamespace, metav1.GetOptions{})
+	if err != nil {
+		t.Errorf("expected existing namespace to be kept, got %s", err)
+	}
+}
+
+func TestProvisionReportsRollbackErrors(t *testing.T) {
+	c, clientset := newTestClient()
+	failOn(clientset, "create", "rolebindings")
+	failOn(clientset, "delete", "namespaces")
+
+	err := c.CreateNamespaceWithServiceAccount(testNamespaceModel())
+
+	provisionErr, ok := err.(*ProvisionError)
+	if !ok {
+		t.Fatalf("expected *ProvisionError, got %T: %v", err, err)
+	}
+
+	if len(provisionErr.RollbackErrors) != 1 {
+		t.Errorf("expected one rollback error, got %v", provisionErr.RollbackErrors)
+	}
+}
+
+// recordingSteps returns steps appending their create and rollback to
+// calls. The step named failing fails to create, the others create
+// their object.
+func recordingSteps(calls *[]string, failing string, names ...string) []provisionStep {
+	steps := make([]provisionStep, len(names))
+	for i, name := range names {
+		name := name
+		steps[i] = provisionStep{
+			name: name,
+			create: func() (bool, error) {
+				*calls = append(*calls, "create "+name)
+				if name == failing {
+					return false, errors.New("injected failure")
+				}
+				return true, nil
+			},
+			rollback: func() error {
+				*calls = append(*calls, "rollback "+name)
+				return nil
+			},
+		}
+	}
+	return steps
+}
+
+func TestProvisionRunsAllSteps(t *testing.T) {
+	calls := []string{}
+	if err := provision(testNamespace, recordingSteps(&calls, "", "a", "b")); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	expected := "[create a create b]"
+	if got := fmt.Sprint(calls); got != expected {
+		t.Errorf("expected %s, got %s", expected, got)
+	}
+}
+
+func TestProvisionRollsBackStepsInReverse(t *testing.T) {
+	calls := []string{}
+	err := provision(testNamespace, recordingSteps(&calls, "c", "a", "b", "c", "d"))
+
+	provisionErr, ok := err.(*ProvisionError)
+	if !ok || provisionErr.Step != "c" {
+		t.Fatalf("expected step c to fail, got %v", err)
+	}
+
+	expected := "[create a create b create c rollback b rollback a]"
+	if got := fmt.Sprint(calls); got != expected {
+		t.Errorf("expected %s, got %s", expected, got)
+	}
+}
+
+func TestProvisionCollectsRollbackErrors(t *testing.T) {
+	calls := []string{}
+	steps := recordingSteps(&calls, "c", "a", "b", "c")
+	steps[0].rollback = func() error { return errors.New("rollback failure") }
+
+	err := provision(testNamespace, steps)
+
+	provisionErr, ok := err.(*ProvisionError)
+	if !ok {
+		t.Fatalf("expected a ProvisionError, got %v", err)
+	}
+	if len(provisionErr.RollbackErrors) != 1 {
+		t.Errorf("expected one rollback error, got %v", provisionErr.RollbackErrors)
+	}
+
+	// A failing rollback does not stop the others
+	expected := "[create a create b create c rollback b]"
+	if got := fmt.Sprint(calls); got != expected {
+		t.Errorf("expected %s, got %s", expected, got)
+	}
+}
+
+func TestProvisionKeepsExistingObjects(t *testing.T) {
+	calls := []string{}
+	steps := recordingSteps(&calls, "c", "a", "b", "c")
+	steps[0].create = func() (bool, error) {
+		calls = append(calls, "create a")
+		return false, nil
+	}
+	// The failing step created its object before failing
+	steps[2].create = func() (bool, error) {
+		calls = append(calls, "create c")
+		return true, errors.New("injected failure")
+	}
+
+	if err := provision(testNamespace, steps); err == nil {
+		t.Fatal("expected provisioning to fail")
+	}
+
+	expected := "[create a create b create c rollback c rollback b]"
+	if got := fmt.Sprint(calls); got != expected {
+		t.Errorf("expected %s, got %s", expected, got)
+	}
+}
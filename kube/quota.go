@@ -0,0 +1,232 @@
+package kube
+
+import (
+	"github.com/gobuffalo/envy"
+	"github.com/kradalby/bork/models"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	kubernetesErrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// DefaultQuota returns the cluster wide quota applied to namespaces
+// without an override. Setting a variable to an empty string removes
+// the limit.
+func DefaultQuota() models.Quota {
+	return models.Quota{
+		RequestsCPU:          envy.Get("BORK_QUOTA_REQUESTS_CPU", "2"),
+		RequestsMemory:       envy.Get("BORK_QUOTA_REQUESTS_MEMORY", "4Gi"),
+		LimitsCPU:            envy.Get("BORK_QUOTA_LIMITS_CPU", "4"),
+		LimitsMemory:         envy.Get("BORK_QUOTA_LIMITS_MEMORY", "8Gi"),
+		Pods:                 envy.Get("BORK_QUOTA_PODS", "20"),
+		DefaultCPU:           envy.Get("BORK_LIMIT_DEFAULT_CPU", "500m"),
+		DefaultMemory:        envy.Get("BORK_LIMIT_DEFAULT_MEMORY", "512Mi"),
+		DefaultRequestCPU:    envy.Get("BORK_LIMIT_DEFAULT_REQUEST_CPU", "100m"),
+		DefaultRequestMemory: envy.Get("BORK_LIMIT_DEFAULT_REQUEST_MEMORY", "128Mi"),
+	}
+}
+
+// QuotaFor returns the effective quota of a namespace, the defaults
+// with the namespace overrides applied. Namespaces pending deletion can
+// not run any pods.
+func QuotaFor(namespace *models.Namespace) models.Quota {
+	quota := DefaultQuota().Merge(namespace.Quota)
+	if namespace.PendingDeletion() {
+		quota.Pods = "0"
+	}
+	return quota
+}
+
+// ValidateQuota makes sure all the values in the quota are valid
+// Kubernetes quantities.
+func ValidateQuota(quota models.Quota) error {
+	_, err := buildResourceQuota(&models.Namespace{}, quota)
+	if err != nil {
+		return err
+	}
+
+	_, err = buildLimitRange(&models.Namespace{}, quota)
+	return err
+}
+
+// ApplyQuota creates or updates the ResourceQuota and LimitRange of
+// the namespace to match the effective quota.
+func (c *Client) ApplyQuota(namespace *models.Namespace) error {
+	return c.applyQuota(namespace, QuotaFor(namespace))
+}
+
+func (c *Client) applyQuota(namespace *models.Namespace, quota models.Quota) error {
+	_, err := c.ensureResourceQuota(namespace, quota)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.ensureLimitRange(namespace, quota)
+	return err
+}
+
+func (c *Client) ensureResourceQuota(namespace *models.Namespace, quota models.Quota) (bool, error) {
+	desired, err := buildResourceQuota(namespace, quota)
+	if err != nil {
+		return false, err
+	}
+
+	existing, err := c.client.CoreV1().ResourceQuotas(namespace.Name).Get(desired.Name, metav1.GetOptions{})
+	if kubernetesErrors.IsNotFound(err) {
+		_, err = c.client.CoreV1().ResourceQuotas(namespace.Name).Create(desired)
+		return err == nil, err
+	}
+	if err != nil {
+		return false, err
+	}
+
+	metadataChanged := updateManagedMetadata(&existing.ObjectMeta, desired.ObjectMeta)
+	if !metadataChanged && equality.Semantic.DeepEqual(existing.Spec, desired.Spec) {
+		return false, nil
+	}
+
+	existing.Spec = desired.Spec
+	_, err = c.client.CoreV1().ResourceQuotas(namespace.Name).Update(existing)
+	return false, err
+}
+
+func (c *Client) ensureLimitRange(namespace *models.Namespace, quota models.Quota) (bool, error) {
+	desired, err := buildLimitRange(namespace, quota)
+	if err != nil {
+		return false, err
+	}
+
+	existing, err := c.client.CoreV1().LimitRanges(namespace.Name).Get(desired.Name, metav1.GetOptions{})
+	if kubernetesErrors.IsNotFound(err) {
+		_, err = c.client.CoreV1().LimitRanges(namespace.Name).Create(desired)
+		return err == nil, err
+	}
+	if err != nil {
+		return false, err
+	}
+
+	metadataChanged := updateManagedMetadata(&existing.ObjectMeta, desired.ObjectMeta)
+	if !metadataChanged && equality.Semantic.DeepEqual(existing.Spec, desired.Spec) {
+		return false, nil
+	}
+
+	existing.Spec = desired.Spec
+	_, err = c.client.CoreV1().LimitRanges(namespace.Name).Update(existing)
+	return false, err
+}
+
+func (c *Client) deleteResourceQuotaIfPresent(namespace string) error {
+	err := c.client.CoreV1().ResourceQuotas(namespace).Delete(getResourceQuotaName(namespace), &metav1.DeleteOptions{})
+	return ignoreNotFound(err)
+}
+
+func (c *Client) deleteLimitRangeIfPresent(namespace string) error {
+	err := c.client.CoreV1().LimitRanges(namespace).Delete(getLimitRangeName(namespace), &metav1.DeleteOptions{})
+	return ignoreNotFound(err)
+}
+
+// isQuotaInSync reports if the ResourceQuota and LimitRange in the
+// cluster exist and match the given quota.
+func (c *Client) isQuotaInSync(namespace *models.Namespace, quota models.Quota) (bool, error) {
+	desiredQuota, err := buildResourceQuota(namespace, quota)
+	if err != nil {
+		return false, err
+	}
+
+	existingQuota, err := c.client.CoreV1().ResourceQuotas(namespace.Name).Get(desiredQuota.Name, metav1.GetOptions{})
+	if kubernetesErrors.IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if !equality.Semantic.DeepEqual(existingQuota.Spec, desiredQuota.Spec) {
+		return false, nil
+	}
+
+	desiredLimits, err := buildLimitRange(namespace, quota)
+	if err != nil {
+		return false, err
+	}
+
+	existingLimits, err := c.client.CoreV1().LimitRanges(namespace.Name).Get(desiredLimits.Name, metav1.GetOptions{})
+	if kubernetesErrors.IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return equality.Semantic.DeepEqual(existingLimits.Spec, desiredLimits.Spec), nil
+}
+
+func buildResourceQuota(namespace *models.Namespace, quota models.Quota) (*corev1.ResourceQuota, error) {
+	hard, err := resourceList(map[corev1.ResourceName]string{
+		corev1.ResourceRequestsCPU:    quota.RequestsCPU,
+		corev1.ResourceRequestsMemory: quota.RequestsMemory,
+		corev1.ResourceLimitsCPU:      quota.LimitsCPU,
+		corev1.ResourceLimitsMemory:   quota.LimitsMemory,
+		corev1.ResourcePods:           quota.Pods,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &corev1.ResourceQuota{
+		ObjectMeta: managedObjectMeta(namespace, getResourceQuotaName(namespace.Name), nil),
+		Spec: corev1.ResourceQuotaSpec{
+			Hard: hard,
+		},
+	}, nil
+}
+
+func buildLimitRange(namespace *models.Namespace, quota models.Quota) (*corev1.LimitRange, error) {
+	defaults, err := resourceList(map[corev1.ResourceName]string{
+		corev1.ResourceCPU:    quota.DefaultCPU,
+		corev1.ResourceMemory: quota.DefaultMemory,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	defaultRequests, err := resourceList(map[corev1.ResourceName]string{
+		corev1.ResourceCPU:    quota.DefaultRequestCPU,
+		corev1.ResourceMemory: quota.DefaultRequestMemory,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &corev1.LimitRange{
+		ObjectMeta: managedObjectMeta(namespace, getLimitRangeName(namespace.Name), nil),
+		Spec: corev1.LimitRangeSpec{
+			Limits: []corev1.LimitRangeItem{
+				{
+					Type:           corev1.LimitTypeContainer,
+					Default:        defaults,
+					DefaultRequest: defaultRequests,
+				},
+			},
+		},
+	}, nil
+}
+
+// resourceList parses the quantities, skipping empty values.
+func resourceList(values map[corev1.ResourceName]string) (corev1.ResourceList, error) {
+	list := corev1.ResourceList{}
+	for name, value := range values {
+		if value == "" {
+			continue
+		}
+
+		quantity, err := resource.ParseQuantity(value)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid value %q for %s", value, name)
+		}
+		list[name] = quantity
+	}
+	return list, nil
+}
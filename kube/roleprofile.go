@@ -0,0 +1,361 @@
+package kube
+
+import (
+	"sort"
+
+	"github.com/gobuffalo/envy"
+	"github.com/kradalby/bork/models"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	kubernetesErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Built-in role profiles, administrators can change them or add new
+// ones in the database.
+const (
+	// RoleProfileViewer can read workloads, but not secrets
+	RoleProfileViewer = "viewer"
+	// RoleProfileDeveloper can manage workloads, but not secrets
+	RoleProfileDeveloper = "developer"
+	// RoleProfileFull can also manage secrets and roles in the namespace
+	RoleProfileFull = "full"
+)
+
+const roleProfileLabel = "bork.role-profile"
+
+// legacyRoleProfile gives the name of the single role namespaces had
+// before role profiles.
+const legacyRoleProfile = "user-full-access"
+
+// RoleProfiles maps the name of a profile to its rules
+type RoleProfiles map[string][]rbacv1.PolicyRule
+
+// Names returns the names of the profiles in order.
+func (p RoleProfiles) Names() []string {
+	names := make([]string, 0, len(p))
+	for name := range p {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Has reports if a profile with the name exists.
+func (p RoleProfiles) Has(name string) bool {
+	_, ok := p[name]
+	return ok
+}
+
+var workloadAPIGroups = []string{"", "apps", "batch", "extensions", "networking.k8s.io", "autoscaling", "policy"}
+
+// developerRules lets members manage the workloads of the namespace. The
+// resources are listed one by one, so members can not read the secrets
+// holding the tokens of the other members or change the quota, limits
+// and network policy bork manages, which they can only read.
+func developerRules() []rbacv1.PolicyRule {
+	read := []string{"get", "list", "watch"}
+	all := []string{"*"}
+
+	return []rbacv1.PolicyRule{
+		{
+			APIGroups: []string{""},
+			Resources: []string{
+				"pods", "pods/log", "pods/exec", "pods/attach", "pods/portforward",
+				"services", "endpoints", "configmaps", "persistentvolumeclaims",
+				"events", "replicationcontrollers", "replicationcontrollers/scale",
+			},
+			Verbs: all,
+		},
+		{
+			APIGroups: []string{""},
+			Resources: []string{"serviceaccounts", "resourcequotas", "limitranges"},
+			Verbs:     read,
+		},
+		{
+			APIGroups: []string{"apps", "extensions"},
+			Resources: []string{
+				"deployments", "deployments/scale", "statefulsets", "statefulsets/scale",
+				"daemonsets", "replicasets", "replicasets/scale",
+			},
+			Verbs: all,
+		},
+		{
+			APIGroups: []string{"batch"},
+			Resources: []string{"jobs", "cronjobs"},
+			Verbs:     all,
+		},
+		{
+			APIGroups: []string{"extensions", "networking.k8s.io"},
+			Resources: []string{"ingresses"},
+			Verbs:     all,
+		},
+		{
+			APIGroups: []string{"extensions", "networking.k8s.io"},
+			Resources: []string{"networkpolicies"},
+			Verbs:     read,
+		},
+		{
+			APIGroups: []string{"autoscaling"},
+			Resources: []string{"horizontalpodautoscalers"},
+			Verbs:     all,
+		},
+		{
+			APIGroups: []string{"policy"},
+			Resources: []string{"poddisruptionbudgets"},
+			Verbs:     all,
+		},
+	}
+}
+
+// BuiltinRoleProfiles returns the profiles available without any
+// configuration.
+func BuiltinRoleProfiles() RoleProfiles {
+	read := []string{"get", "list", "watch"}
+
+	return RoleProfiles{
+		RoleProfileViewer: {
+			{
+				APIGroups: []string{""},
+				Resources: []string{
+					"pods", "pods/log", "services", "endpoints", "configmaps",
+					"persistentvolumeclaims", "events", "replicationcontrollers",
+					"serviceaccounts", "resourcequotas", "limitranges",
+				},
+				Verbs: read,
+			},
+			{
+				APIGroups: workloadAPIGroups[1:],
+				Resources: []string{"*"},
+				Verbs:     read,
+			},
+		},
+		RoleProfileDeveloper: developerRules(),
+		RoleProfileDeploy:    deployRules(),
+		RoleProfileFull: append(developerRules(),
+			rbacv1.PolicyRule{
+				APIGroups: []string{""},
+				Resources: []string{"secrets"},
+				Verbs:     []string{"*"},
+			},
+			rbacv1.PolicyRule{
+				APIGroups: []string{"rbac.authorization.k8s.io"},
+				Resources: []string{"roles", "rolebindings"},
+				Verbs:     []string{"*"},
+			},
+		),
+	}
+}
+
+// LoadRoleProfiles returns the built-in profiles with the profiles from
+// the database added on top.
+func LoadRoleProfiles() (RoleProfiles, error) {
+	profiles := BuiltinRoleProfiles()
+
+	stored := models.RoleProfiles{}
+	if err := models.DB.All(&stored); err != nil {
+		return nil, err
+	}
+
+	for _, profile := range stored {
+		profiles[profile.Name] = policyRules(profile.Rules)
+	}
+
+	return profiles, nil
+}
+
+// DefaultRoleProfile returns the profile used when none is chosen.
+func DefaultRoleProfile() string {
+	return envy.Get("BORK_ROLE_PROFILE_DEFAULT", RoleProfileDeveloper)
+}
+
+// RoleProfileFor returns the profile of the namespace, given to the
+// namespace service account, the owner and co-owners without a profile
+// of their own.
+func RoleProfileFor(namespace *models.Namespace) string {
+	if namespace.RoleProfile == "" {
+		return DefaultRoleProfile()
+	}
+	return namespace.RoleProfile
+}
+
+// MemberRoleProfileFor returns the profile of an owner or co-owner.
+func MemberRoleProfileFor(namespace *models.Namespace, user models.User) string {
+	if user.ID != namespace.OwnerID {
+		if profile := namespace.MemberRoleProfiles[user.ID.String()]; profile != "" {
+			return profile
+		}
+	}
+	return RoleProfileFor(namespace)
+}
+
+// ApplyRoleProfiles reconciles the roles of the namespace with the
+// profile definitions, and the bindings of the namespace and deploy
+// service accounts and the members with the profiles they have been given. The
+// members must be loaded on the namespace.
+func (c *Client) ApplyRoleProfiles(namespace *models.Namespace) error {
+	if _, err := c.ensureRoles(namespace); err != nil {
+		return err
+	}
+
+	if _, err := c.ensureServiceAccountRoleBinding(namespace, RoleProfileFor(namespace)); err != nil {
+		return err
+	}
+
+	if _, err := c.ensureDeployServiceAccount(namespace); err != nil {
+		return err
+	}
+
+	for _, step := range c.memberSteps(namespace) {
+		if _, err := step.create(); err != nil {
+			return err
+		}
+	}
+
+	return c.ApplyOIDCBindings(namespace)
+}
+
+// ensureRoles creates a role for every profile in the namespace and
+// resets the rules of existing ones. Roles of profiles that no longer
+// exist are removed. It reports if any role had to be created.
+func (c *Client) ensureRoles(namespace *models.Namespace) (bool, error) {
+	profiles, err := c.roleProfiles()
+	if err != nil {
+		return false, err
+	}
+
+	created := false
+	for _, name := range profiles.Names() {
+		roleCreated, err := c.ensureRole(buildRole(namespace, name, profiles[name]))
+		created = created || roleCreated
+		if err != nil {
+			return created, err
+		}
+	}
+
+	roles, err := c.client.RbacV1().Roles(namespace.Name).List(metav1.ListOptions{LabelSelector: roleProfileLabel})
+	if err != nil {
+		return created, err
+	}
+
+	for _, role := range roles.Items {
+		if profiles.Has(role.Labels[roleProfileLabel]) {
+			continue
+		}
+		err := c.client.RbacV1().Roles(namespace.Name).Delete(role.Name, &metav1.DeleteOptions{})
+		if ignoreNotFound(err) != nil {
+			return created, err
+		}
+	}
+
+	// Namespaces created before role profiles had a single role
+	if profiles.Has(legacyRoleProfile) {
+		return created, nil
+	}
+	return created, c.deleteLegacyRoleIfPresent(namespace.Name)
+}
+
+// ensureRole creates the role or resets the rules of an existing one.
+func (c *Client) ensureRole(desired *rbacv1.Role) (bool, error) {
+	roles := c.client.RbacV1().Roles(desired.Namespace)
+
+	existing, err := roles.Get(desired.Name, metav1.GetOptions{})
+	if kubernetesErrors.IsNotFound(err) {
+		_, err = roles.Create(desired)
+		return err == nil, err
+	}
+	if err != nil {
+		return false, err
+	}
+
+	metadataChanged := updateManagedMetadata(&existing.ObjectMeta, desired.ObjectMeta)
+	if !metadataChanged && equality.Semantic.DeepEqual(existing.Rules, desired.Rules) {
+		return false, nil
+	}
+
+	existing.Rules = desired.Rules
+	_, err = roles.Update(existing)
+	return false, err
+}
+
+func (c *Client) deleteRolesIfPresent(namespace string) error {
+	roles, err := c.client.RbacV1().Roles(namespace).List(metav1.ListOptions{LabelSelector: roleProfileLabel})
+	if err != nil {
+		return err
+	}
+
+	for _, role := range roles.Items {
+		err := c.client.RbacV1().Roles(namespace).Delete(role.Name, &metav1.DeleteOptions{})
+		if ignoreNotFound(err) != nil {
+			return err
+		}
+	}
+
+	return c.deleteLegacyRoleIfPresent(namespace)
+}
+
+func (c *Client) deleteLegacyRoleIfPresent(namespace string) error {
+	err := c.client.RbacV1().Roles(namespace).Delete(getRoleName(namespace, legacyRoleProfile), &metav1.DeleteOptions{})
+	return ignoreNotFound(err)
+}
+
+// SyncRoleProfiles reconciles the roles and bindings of all namespaces
+// of the cluster present in both the database and the cluster.
+func (c *Client) SyncRoleProfiles() error {
+	namespaces, err := c.getNamespacesInCluster()
+	if err != nil {
+		return err
+	}
+
+	for i := range namespaces {
+		if err := c.ApplyRoleProfiles(&namespaces[i]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// getNamespacesInCluster returns the namespaces of the database that
+// are present in the cluster.
+func (c *Client) getNamespacesInCluster() (models.Namespaces, error) {
+	namespacesFromCluster, err := c.getAllNamespaces()
+	if err != nil {
+		return nil, err
+	}
+
+	namespacesFromDatabase, err := c.getNamespacesFromDatabase()
+	if err != nil {
+		return nil, err
+	}
+
+	namespaces := models.Namespaces{}
+	for _, ns := range namespacesFromDatabase {
+		if isNamespaceInClusterList(namespacesFromCluster.Items, ns.Name) {
+			namespaces = append(namespaces, ns)
+		}
+	}
+
+	return namespaces, nil
+}
+
+func buildRole(namespace *models.Namespace, profile string, rules []rbacv1.PolicyRule) *rbacv1.Role {
+	return &rbacv1.Role{
+		ObjectMeta: managedObjectMeta(namespace, getRoleName(namespace.Name, profile), map[string]string{
+			roleProfileLabel: profile,
+		}),
+		Rules: rules,
+	}
+}
+
+func policyRules(rules models.PolicyRules) []rbacv1.PolicyRule {
+	converted := make([]rbacv1.PolicyRule, len(rules))
+	for i, rule := range rules {
+		converted[i] = rbacv1.PolicyRule{
+			APIGroups: rule.APIGroups,
+			Resources: rule.Resources,
+			Verbs:     rule.Verbs,
+		}
+	}
+	return converted
+}
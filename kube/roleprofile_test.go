@@ -0,0 +1,230 @@
+package kube
+
+import (
+	"testing"
+
+	"github.com/kradalby/bork/models"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestMemberRoleProfileFor(t *testing.T) {
+	defaultProfile := DefaultRoleProfile()
+
+	owner := testUser("owner")
+	coOwner := testUser("coowner")
+	other := testUser("other")
+
+	ns := testNamespaceModel()
+	ns.OwnerID = owner.ID
+
+	if profile := MemberRoleProfileFor(ns, coOwner); profile != defaultProfile {
+		t.Errorf("expected default profile %q, got %q", defaultProfile, profile)
+	}
+
+	ns.RoleProfile = RoleProfileFull
+	ns.MemberRoleProfiles = models.MemberRoleProfiles{
+		coOwner.ID.String(): RoleProfileViewer,
+		owner.ID.String():   RoleProfileViewer,
+	}
+
+	tests := []struct {
+		user     models.User
+		expected string
+	}{
+		{owner, RoleProfileFull},
+		{coOwner, RoleProfileViewer},
+		{other, RoleProfileFull},
+	}
+
+	for _, tt := range tests {
+		if profile := MemberRoleProfileFor(ns, tt.user); profile != tt.expected {
+			t.Errorf("user %s: expected %q, got %q", tt.user.Username, tt.expected, profile)
+		}
+	}
+}
+
+func TestProvisionBindsRoleProfiles(t *testing.T) {
+	c, _ := newTestClient()
+
+	owner := testUser("owner")
+	coOwner := testUser("coowner")
+
+	ns := testNamespaceModel()
+	ns.Owner = owner
+	ns.OwnerID = owner.ID
+	ns.CoOwners = models.Users{coOwner}
+	ns.RoleProfile = RoleProfileFull
+	ns.MemberRoleProfiles = models.MemberRoleProfiles{coOwner.ID.String(): RoleProfileViewer}
+
+	if err := c.CreateNamespaceWithServiceAccount(ns); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	roles, err := c.client.RbacV1().Roles(testNamespace).List(metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(roles.Items) != len(BuiltinRoleProfiles()) {
+		t.Errorf("expected a role for every profile, got %d roles", len(roles.Items))
+	}
+
+	expected := map[string]string{
+		getRoleBindingName(testNamespace):                getRoleName(testNamespace, RoleProfileFull),
+		getMemberRoleBindingName(testNamespace, owner):   getRoleName(testNamespace, RoleProfileFull),
+		getMemberRoleBindingName(testNamespace, coOwner): getRoleName(testNamespace, RoleProfileViewer),
+	}
+
+	for name, role := range expected {
+		binding, err := c.client.RbacV1().RoleBindings(testNamespace).Get(name, metav1.GetOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if binding.RoleRef.Name != role {
+			t.Errorf("binding %s: expected role %q, got %q", name, role, binding.RoleRef.Name)
+		}
+	}
+}
+
+func TestApplyRoleProfilesFollowsDefinitions(t *testing.T) {
+	legacy := &rbacv1.Role{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      getRoleName(testNamespace, legacyRoleProfile),
+			Namespace: testNamespace,
+		},
+	}
+	c, _ := newTestClient(legacy)
+
+	ns := testNamespaceModel()
+	if err := c.CreateNamespaceWithServiceAccount(ns); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	restricted := []rbacv1.PolicyRule{{
+		APIGroups: []string{""},
+		Resources: []string{"pods"},
+		Verbs:     []string{"get"},
+	}}
+	c.roleProfiles = func() (RoleProfiles, error) {
+		return RoleProfiles{"restricted": restricted}, nil
+	}
+	ns.RoleProfile = "restricted"
+
+	if err := c.ApplyRoleProfiles(ns); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	roles, err := c.client.RbacV1().Roles(testNamespace).List(metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(roles.Items) != 1 || roles.Items[0].Name != getRoleName(testNamespace, "restricted") {
+		t.Fatalf("expected only the restricted role to be left, got %#v", roles.Items)
+	}
+	if !equality.Semantic.DeepEqual(roles.Items[0].Rules, restricted) {
+		t.Errorf("expected rules %#v, got %#v", restricted, roles.Items[0].Rules)
+	}
+
+	binding, err := c.client.RbacV1().RoleBindings(testNamespace).Get(getRoleBindingName(testNamespace), metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if binding.RoleRef.Name != getRoleName(testNamespace, "restricted") {
+		t.Errorf("expected service account to be bound to the restricted role, got %q", binding.RoleRef.Name)
+	}
+}
+
+func TestOIDCBindingsPerRoleProfile(t *testing.T) {
+	defer withOIDCBindings(OIDCBindingsEmail, "")()
+
+	c, _ := newTestClient()
+
+	owner := testUser("owner")
+	owner.Email = "owner@example.com"
+	coOwner := testUser("coowner")
+	coOwner.Email = "coowner@example.com"
+
+	ns := testNamespaceModel()
+	ns.Owner = owner
+	ns.OwnerID = owner.ID
+	ns.CoOwners = models.Users{coOwner}
+	ns.MemberRoleProfiles = models.MemberRoleProfiles{coOwner.ID.String(): RoleProfileViewer}
+
+	if err := c.CreateNamespaceWithServiceAccount(ns); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	binding, err := c.client.RbacV1().RoleBindings(testNamespace).Get(getOIDCRoleBindingName(testNamespace, RoleProfileViewer), metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(binding.Subjects) != 1 || binding.Subjects[0].Name != "coowner@example.com" {
+		t.Errorf("expected only the co-owner to be bound as viewer, got %#v", binding.Subjects)
+	}
+
+	ns.MemberRoleProfiles = models.MemberRoleProfiles{}
+	if err := c.ApplyRoleProfiles(ns); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	_, err = c.client.RbacV1().RoleBindings(testNamespace).Get(getOIDCRoleBindingName(testNamespace, RoleProfileViewer), metav1.GetOptions{})
+	if err == nil {
+		t.Error("expected the viewer binding without members to be removed")
+	}
+}
+
+// allows reports if the rules grant the verb on the resource.
+func allows(rules []rbacv1.PolicyRule, group string, resource string, verb string) bool {
+	matches := func(values []string, value string) bool {
+		for _, v := range values {
+			if v == "*" || v == value {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, rule := range rules {
+		if matches(rule.APIGroups, group) && matches(rule.Resources, resource) && matches(rule.Verbs, verb) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestBuiltinRoleProfilePermissions(t *testing.T) {
+	profiles := BuiltinRoleProfiles()
+
+	tests := []struct {
+		profile  string
+		group    string
+		resource string
+		verb     string
+		allowed  bool
+	}{
+		{RoleProfileDeveloper, "apps", "deployments", "create", true},
+		{RoleProfileDeveloper, "", "pods/exec", "create", true},
+		{RoleProfileDeveloper, "networking.k8s.io", "ingresses", "update", true},
+		{RoleProfileDeveloper, "", "secrets", "get", false},
+		{RoleProfileDeveloper, "", "secrets", "list", false},
+		{RoleProfileDeveloper, "", "serviceaccounts/token", "create", false},
+		{RoleProfileDeveloper, "", "resourcequotas", "get", true},
+		{RoleProfileDeveloper, "", "resourcequotas", "delete", false},
+		{RoleProfileDeveloper, "", "limitranges", "update", false},
+		{RoleProfileDeveloper, "networking.k8s.io", "networkpolicies", "list", true},
+		{RoleProfileDeveloper, "networking.k8s.io", "networkpolicies", "delete", false},
+		{RoleProfileDeveloper, "extensions", "networkpolicies", "update", false},
+		{RoleProfileViewer, "", "secrets", "get", false},
+		{RoleProfileViewer, "apps", "deployments", "update", false},
+		{RoleProfileFull, "", "secrets", "get", true},
+		{RoleProfileFull, "", "resourcequotas", "delete", false},
+		{RoleProfileFull, "networking.k8s.io", "networkpolicies", "delete", false},
+	}
+
+	for _, tt := range tests {
+		if allowed := allows(profiles[tt.profile], tt.group, tt.resource, tt.verb); allowed != tt.allowed {
+			t.Errorf("%s: expected %s on %s/%s allowed to be %t", tt.profile, tt.verb, tt.group, tt.resource, tt.allowed)
+		}
+	}
+}
@@ -13,7 +13,7 @@ import (
 
 func (c *Client) getAllNamespaces() (*corev1.NamespaceList, error) {
 
-	ns, err := c.client.CoreV1().Namespaces().List(metav1.ListOptions{LabelSelector: "bork"})
+	ns, err := c.client.CoreV1().Namespaces().List(metav1.ListOptions{LabelSelector: borkLabel})
 	if err != nil {
 		return &corev1.NamespaceList{}, err
 	}
@@ -21,6 +21,40 @@ func (c *Client) getAllNamespaces() (*corev1.NamespaceList, error) {
 	return ns, nil
 }
 
+// getNamespacesFromDatabase returns the namespaces stored in the
+// database that belong to the cluster of the client.
+func (c *Client) getNamespacesFromDatabase() (models.Namespaces, error) {
+	namespaces := models.Namespaces{}
+
+	// Eager loads the members, their service accounts are synced too
+	query := models.DB.Eager().Where("cluster_id IS NULL")
+	if c.cluster != nil {
+		query = models.DB.Eager().Where("cluster_id = ?", c.cluster.ID)
+	}
+
+	err := query.All(&namespaces)
+	return namespaces, err
+}
+
+// getNamespaceFromDatabase returns the namespace of the cluster with the
+// name, or nil if there is none.
+func (c *Client) getNamespaceFromDatabase(name string) (*models.Namespace, error) {
+	namespaces := models.Namespaces{}
+
+	query := models.DB.Eager().Where("cluster_id IS NULL AND name = ?", name)
+	if c.cluster != nil {
+		query = models.DB.Eager().Where("cluster_id = ? AND name = ?", c.cluster.ID, name)
+	}
+
+	if err := query.All(&namespaces); err != nil {
+		return nil, err
+	}
+	if len(namespaces) == 0 {
+		return nil, nil
+	}
+	return &namespaces[0], nil
+}
+
 // Missing: DB objects, Dead: Kube objects, error
 func (c *Client) FindOutOfSyncNamespaces() ([]models.Namespace, []corev1.Namespace, error) {
 	namespacesFromCluster, err := c.getAllNamespaces()
@@ -29,27 +63,12 @@ func (c *Client) FindOutOfSyncNamespaces() ([]models.Namespace, []corev1.Namespa
 		return []models.Namespace{}, []corev1.Namespace{}, err
 	}
 
-	namespacesFromDatabase := models.Namespaces{}
-
-	err = models.DB.All(&namespacesFromDatabase)
+	namespacesFromDatabase, err := c.getNamespacesFromDatabase()
 	if err != nil {
 		return []models.Namespace{}, []corev1.Namespace{}, err
 	}
 
-	namespacesMissingFromCluster := []models.Namespace{}
-	namespacesMissingFromDatabase := []corev1.Namespace{}
-
-	for _, ns := range namespacesFromDatabase {
-		if !isNamespaceInClusterList(namespacesFromCluster.Items, ns.Name) {
-			namespacesMissingFromCluster = append(namespacesMissingFromCluster, ns)
-		}
-	}
-
-	for _, ns := range namespacesFromCluster.Items {
-		if !isNamespaceInDatabaseList(&namespacesFromDatabase, ns.Name) {
-			namespacesMissingFromDatabase = append(namespacesMissingFromDatabase, ns)
-		}
-	}
+	missing, dead := diffNamespaces(namespacesFromCluster.Items, namespacesFromDatabase)
 	// for _, namespace := range namespacesFromDatabase {
 	// 	ns, err := c.getNamespaceFromCluster(namespace.Name)
 	// 	if err != nil {
@@ -73,12 +92,42 @@ func (c *Client) FindOutOfSyncNamespaces() ([]models.Namespace, []corev1.Namespa
 
 	// }
 
-	return namespacesMissingFromCluster, namespacesMissingFromDatabase, nil
+	return missing, dead, nil
 }
 
+// diffNamespaces returns the namespaces in the database missing from
+// the cluster, and the namespaces in the cluster missing from the
+// database.
+func diffNamespaces(cluster []corev1.Namespace, database models.Namespaces) ([]models.Namespace, []corev1.Namespace) {
+	namespacesMissingFromCluster := []models.Namespace{}
+	namespacesMissingFromDatabase := []corev1.Namespace{}
+
+	for _, ns := range database {
+		if !isNamespaceInClusterList(cluster, ns.Name) {
+			namespacesMissingFromCluster = append(namespacesMissingFromCluster, ns)
+		}
+	}
+
+	for _, ns := range cluster {
+		if !isNamespaceInDatabaseList(&database, ns.Name) {
+			namespacesMissingFromDatabase = append(namespacesMissingFromDatabase, ns)
+		}
+	}
+
+	return namespacesMissingFromCluster, namespacesMissingFromDatabase
+}
+
+// DeleteOrphansInCluster deletes the namespaces in the list with all
+// the objects belonging to them, skipping the locked ones.
 func (c *Client) DeleteOrphansInCluster(list []corev1.Namespace) error {
-	for _, ns := range list {
-		err := c.deleteNamespace(ns.Name)
+	for i := range list {
+		ns := list[i]
+		if isLocked(&ns) {
+			log.Printf("[INFO] Not deleting orphaned namespace %s, it is locked", ns.Name)
+			continue
+		}
+
+		err := c.DeleteNamespaceWithServiceAccount(ns.Name)
 		if err != nil {
 			return err
 		}
@@ -87,8 +136,52 @@ func (c *Client) DeleteOrphansInCluster(list []corev1.Namespace) error {
 }
 
 func (c *Client) CreateMissingFromDatabase(list []models.Namespace) error {
-	for _, ns := range list {
-		err := c.CreateNamespaceWithServiceAccount(ns.Name, ns.Owner.ID)
+	for i := range list {
+		err := c.CreateNamespaceWithServiceAccount(&list[i])
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// FindOutOfSyncQuotas returns the namespaces present in the cluster
+// where the ResourceQuota or LimitRange is missing or modified.
+func (c *Client) FindOutOfSyncQuotas() ([]models.Namespace, error) {
+	namespacesFromCluster, err := c.getAllNamespaces()
+	if err != nil {
+		return []models.Namespace{}, err
+	}
+
+	namespacesFromDatabase, err := c.getNamespacesFromDatabase()
+	if err != nil {
+		return []models.Namespace{}, err
+	}
+
+	outOfSync := []models.Namespace{}
+
+	for i := range namespacesFromDatabase {
+		ns := namespacesFromDatabase[i]
+		if !isNamespaceInClusterList(namespacesFromCluster.Items, ns.Name) {
+			continue
+		}
+
+		inSync, err := c.isQuotaInSync(&ns, QuotaFor(&ns))
+		if err != nil {
+			return []models.Namespace{}, err
+		}
+
+		if !inSync {
+			outOfSync = append(outOfSync, ns)
+		}
+	}
+
+	return outOfSync, nil
+}
+
+func (c *Client) RepairQuotas(list []models.Namespace) error {
+	for i := range list {
+		err := c.ApplyQuota(&list[i])
 		if err != nil {
 			return err
 		}
@@ -96,6 +189,22 @@ func (c *Client) CreateMissingFromDatabase(list []models.Namespace) error {
 	return nil
 }
 
+// SyncQuotas repairs missing or modified quota objects
+func (c *Client) SyncQuotas() error {
+	outOfSync, err := c.FindOutOfSyncQuotas()
+	if err != nil {
+		return err
+	}
+
+	log.Println("[INFO] Quota out of sync: ")
+	for _, ns := range outOfSync {
+		log.Println("[INFO] ", ns.Name)
+	}
+
+	log.Printf("[DEBUG] Repairing quotas")
+	return c.RepairQuotas(outOfSync)
+}
+
 func (c *Client) Sync(createList []models.Namespace, deleteList []corev1.Namespace) error {
 
 	log.Println("[INFO] Missing from cluster: ")
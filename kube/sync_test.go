@@ -0,0 +1,96 @@
+package kube
+
+import (
+	"testing"
+
+	"github.com/kradalby/bork/models"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func clusterNamespace(name string) corev1.Namespace {
+	return corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   name,
+			Labels: map[string]string{"bork": "true"},
+		},
+	}
+}
+
+func TestDiffNamespaces(t *testing.T) {
+	cluster := []corev1.Namespace{
+		clusterNamespace("both"),
+		clusterNamespace("only-cluster"),
+	}
+	database := models.Namespaces{
+		{Name: "both"},
+		{Name: "only-database"},
+	}
+
+	missing, dead := diffNamespaces(cluster, database)
+
+	if len(missing) != 1 || missing[0].Name != "only-database" {
+		t.Errorf("expected only-database to be missing from the cluster, got %v", missing)
+	}
+
+	if len(dead) != 1 || dead[0].Name != "only-cluster" {
+		t.Errorf("expected only-cluster to be missing from the database, got %v", dead)
+	}
+}
+
+func TestSync(t *testing.T) {
+	orphan := clusterNamespace("bork-orphan")
+	c, _ := newTestClient(&orphan)
+
+	err := c.Sync([]models.Namespace{*testNamespaceModel()}, []corev1.Namespace{orphan})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if remaining := remainingObjects(t, c, testNamespace); len(remaining) != 9 {
+		t.Errorf("expected missing namespace to be created, got %v", remaining)
+	}
+
+	if remaining := remainingObjects(t, c, orphan.Name); len(remaining) != 0 {
+		t.Errorf("expected orphan to be deleted, left %v", remaining)
+	}
+}
+
+func TestIsQuotaInSync(t *testing.T) {
+	c, _ := newTestClient()
+	ns := testNamespaceModel()
+
+	if err := c.CreateNamespaceWithServiceAccount(ns); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	inSync, err := c.isQuotaInSync(ns, QuotaFor(ns))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !inSync {
+		t.Error("expected quota of a new namespace to be in sync")
+	}
+
+	ns.Quota = models.Quota{Pods: "1"}
+
+	inSync, err = c.isQuotaInSync(ns, QuotaFor(ns))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if inSync {
+		t.Error("expected changed quota to be out of sync")
+	}
+
+	if err := c.RepairQuotas([]models.Namespace{*ns}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	inSync, err = c.isQuotaInSync(ns, QuotaFor(ns))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !inSync {
+		t.Error("expected repaired quota to be in sync")
+	}
+}
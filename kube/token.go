@@ -0,0 +1,318 @@
+package kube
+
+import (
+	"context"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/gobuffalo/envy"
+	"github.com/gobuffalo/uuid"
+	"github.com/kradalby/bork/models"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilrand "k8s.io/apimachinery/pkg/util/rand"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+// ErrTokenTimeout is returned when the token controller has not issued
+// a token for a service account within the configured timeout.
+var ErrTokenTimeout = errors.New("timed out waiting for service account token")
+
+const serviceAccountNameAnnotation = "kubernetes.io/service-account.name"
+
+// secretWatchBackoff spaces out restarts of a closed secret watch, so a
+// flapping API server is not listed and watched in a tight loop. The
+// delay stops growing after Steps restarts.
+var secretWatchBackoff = wait.Backoff{
+	Duration: 100 * time.Millisecond,
+	Factor:   2,
+	Jitter:   0.1,
+	Steps:    6,
+}
+
+// TokenTimeout returns how long to wait for the token controller to
+// issue a token for a new service account.
+func TokenTimeout() time.Duration {
+	timeout, err := time.ParseDuration(envy.Get("BORK_TOKEN_TIMEOUT", "30s"))
+	if err != nil {
+		log.Printf("[Error] Invalid BORK_TOKEN_TIMEOUT, using 30s: %#v", err)
+		return 30 * time.Second
+	}
+	return timeout
+}
+
+// IsTokenTimeout reports if the error, or the cause of it, is a timeout
+// waiting for a service account token.
+func IsTokenTimeout(err error) bool {
+	return errors.Cause(err) == ErrTokenTimeout
+}
+
+// waitForServiceAccountToken blocks until the token secret of the
+// service account exists or the context is done.
+func (c *Client) waitForServiceAccountToken(ctx context.Context, namespace string, serviceAccountName string) error {
+	err := c.waitForSecret(ctx, namespace, func(secret *corev1.Secret) bool {
+		return isServiceAccountToken(secret, serviceAccountName)
+	})
+	return errors.Wrapf(err, "service account %s in namespace %s", serviceAccountName, namespace)
+}
+
+// waitForSecret blocks until a secret matching the function exists or
+// the context is done. Existing secrets are listed first and the watch
+// is started from the resource version of the list, so a secret created
+// in between is not missed.
+func (c *Client) waitForSecret(ctx context.Context, namespace string, match func(*corev1.Secret) bool) error {
+	backoff := secretWatchBackoff
+
+	for {
+		if ctx.Err() != nil {
+			return ErrTokenTimeout
+		}
+
+		secrets, err := c.client.CoreV1().Secrets(namespace).List(metav1.ListOptions{})
+		if err != nil {
+			return err
+		}
+
+		for i := range secrets.Items {
+			if match(&secrets.Items[i]) {
+				return nil
+			}
+		}
+
+		w, err := c.client.CoreV1().Secrets(namespace).Watch(metav1.ListOptions{
+			ResourceVersion: secrets.ResourceVersion,
+		})
+		if err != nil {
+			return err
+		}
+
+		found, err := watchForSecret(ctx, w, match)
+		w.Stop()
+		if err != nil {
+			return err
+		}
+		if found {
+			return nil
+		}
+
+		// The watch was closed by the server, start over after a delay
+		delay := wait.Jitter(backoff.Duration, backoff.Jitter)
+		log.Printf("[INFO] Secret watch in namespace %s closed, restarting in %s", namespace, delay)
+		select {
+		case <-ctx.Done():
+			return ErrTokenTimeout
+		case <-time.After(delay):
+		}
+
+		if backoff.Steps > 0 {
+			backoff.Duration = time.Duration(float64(backoff.Duration) * backoff.Factor)
+			backoff.Steps--
+		}
+	}
+}
+
+// watchForSecret reads events until a matching secret is added or
+// modified, the watch is closed or the context is done.
+func watchForSecret(ctx context.Context, w watch.Interface, match func(*corev1.Secret) bool) (bool, error) {
+	for {
+		select {
+		case <-ctx.Done():
+			return false, ErrTokenTimeout
+
+		case event, ok := <-w.ResultChan():
+			if !ok {
+				return false, nil
+			}
+
+			switch event.Type {
+			case watch.Added, watch.Modified:
+				secret, ok := event.Object.(*corev1.Secret)
+				if !ok {
+					return false, errors.New("unexpected object type, not Secret")
+				}
+				if match(secret) {
+					return true, nil
+				}
+
+			case watch.Error:
+				// Usually an expired resource version, list again
+				return false, nil
+			}
+		}
+	}
+}
+
+// RotateToken issues a new token for the service account of the
+// namespace and revokes the old ones. A new token secret is created and
+// once the token controller has filled it in, the service account is
+// pointed at it and the old token secrets are deleted, which makes the
+// API server reject the old tokens.
+func (c *Client) RotateToken(namespace string) (string, error) {
+	return c.rotateServiceAccountToken(namespace, getServiceAccountName(namespace))
+}
+
+// RotateMemberToken issues a new token for the service account of a
+// member and revokes their old ones, see RotateToken.
+func (c *Client) RotateMemberToken(namespace string, user models.User) (string, error) {
+	return c.rotateServiceAccountToken(namespace, getMemberServiceAccountName(namespace, user))
+}
+
+// RotateAllTokens revokes every token handed out for the namespace, of
+// the namespace service account and of every member. The members must
+// be loaded on the namespace.
+func (c *Client) RotateAllTokens(namespace *models.Namespace) error {
+	if _, err := c.RotateToken(namespace.Name); err != nil {
+		return err
+	}
+
+	for _, user := range namespace.Users() {
+		if user.ID == uuid.Nil {
+			continue
+		}
+		if _, err := c.RotateMemberToken(namespace.Name, user); err != nil {
+			return errors.Wrapf(err, "rotating token of %s", user.Username)
+		}
+	}
+	return nil
+}
+
+// rotateServiceAccountToken issues a new token for the service account
+// and revokes the old ones, see RotateToken. In the tokenrequest
+// credential mode no token secret is created, the service account is
+// recreated instead.
+func (c *Client) rotateServiceAccountToken(namespace string, serviceAccountName string) (string, error) {
+	if CredentialMode() == CredentialModeTokenRequest {
+		return c.rotateMintedTokens(namespace, serviceAccountName)
+	}
+
+	serviceAccount, err := c.client.CoreV1().ServiceAccounts(namespace).Get(serviceAccountName, metav1.GetOptions{})
+	if err != nil {
+		return "", err
+	}
+
+	// The secret belongs to the same namespace and owners as the
+	// service account
+	annotations := map[string]string{
+		serviceAccountNameAnnotation: serviceAccount.Name,
+	}
+	for _, key := range []string{coOwnersAnnotation, versionAnnotation} {
+		if value, ok := serviceAccount.Annotations[key]; ok {
+			annotations[key] = value
+		}
+	}
+
+	secret, err := c.client.CoreV1().Secrets(namespace).Create(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        serviceAccount.Name + "-token-" + utilrand.String(5),
+			Namespace:   namespace,
+			Labels:      serviceAccount.Labels,
+			Annotations: annotations,
+		},
+		Type: corev1.SecretTypeServiceAccountToken,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), TokenTimeout())
+	defer cancel()
+
+	var token string
+	err = c.waitForSecret(ctx, namespace, func(s *corev1.Secret) bool {
+		token = string(s.Data["token"])
+		return s.Name == secret.Name && token != ""
+	})
+	if err != nil {
+		if deleteErr := c.client.CoreV1().Secrets(namespace).Delete(secret.Name, &metav1.DeleteOptions{}); deleteErr != nil {
+			log.Printf("[Error] Could not remove unused token secret %s: %#v", secret.Name, deleteErr)
+		}
+		return "", errors.Wrapf(err, "rotating token in namespace %s", namespace)
+	}
+
+	// Keep references to other secrets, like image pull secrets
+	references := []corev1.ObjectReference{{Name: secret.Name}}
+	for _, reference := range serviceAccount.Secrets {
+		if !strings.Contains(reference.Name, "token") {
+			references = append(references, reference)
+		}
+	}
+
+	serviceAccount.Secrets = references
+	if _, err := c.client.CoreV1().ServiceAccounts(namespace).Update(serviceAccount); err != nil {
+		return "", err
+	}
+
+	secrets, err := c.client.CoreV1().Secrets(namespace).List(metav1.ListOptions{})
+	if err != nil {
+		return "", err
+	}
+
+	for i := range secrets.Items {
+		old := &secrets.Items[i]
+		if old.Name == secret.Name || !isServiceAccountToken(old, serviceAccount.Name) {
+			continue
+		}
+
+		err := c.client.CoreV1().Secrets(namespace).Delete(old.Name, &metav1.DeleteOptions{})
+		if ignoreNotFound(err) != nil {
+			return "", errors.Wrapf(err, "revoking token secret %s", old.Name)
+		}
+	}
+
+	return token, nil
+}
+
+// rotateMintedTokens revokes the minted tokens of the service account
+// and mints a new one. Minted tokens are bound to the UID of the service
+// account, so a new service account under the same name makes the API
+// server reject all of them. Its bindings refer to it by name and keep
+// applying.
+func (c *Client) rotateMintedTokens(namespace string, serviceAccountName string) (string, error) {
+	serviceAccounts := c.client.CoreV1().ServiceAccounts(namespace)
+
+	existing, err := serviceAccounts.Get(serviceAccountName, metav1.GetOptions{})
+	if err != nil {
+		return "", err
+	}
+
+	if err := serviceAccounts.Delete(serviceAccountName, &metav1.DeleteOptions{}); err != nil {
+		return "", errors.Wrapf(err, "revoking tokens of service account %s", serviceAccountName)
+	}
+
+	_, err = serviceAccounts.Create(&corev1.ServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        existing.Name,
+			Namespace:   existing.Namespace,
+			Labels:      existing.Labels,
+			Annotations: existing.Annotations,
+		},
+		ImagePullSecrets: existing.ImagePullSecrets,
+	})
+	if err != nil {
+		return "", errors.Wrapf(err, "recreating service account %s", serviceAccountName)
+	}
+
+	token, err := c.requestToken(namespace, serviceAccountName, 0)
+	if err == nil || !isTokenRequestUnsupported(err) {
+		return token, err
+	}
+
+	// Old clusters issue a token secret for the new service account
+	ctx, cancel := context.WithTimeout(context.Background(), TokenTimeout())
+	defer cancel()
+
+	if err := c.waitForServiceAccountToken(ctx, namespace, serviceAccountName); err != nil {
+		return "", err
+	}
+	return c.serviceAccountToken(namespace, serviceAccountName, 0)
+}
+
+func isServiceAccountToken(secret *corev1.Secret, serviceAccountName string) bool {
+	if secret.Type != "" && secret.Type != corev1.SecretTypeServiceAccountToken {
+		return false
+	}
+	return secret.Annotations[serviceAccountNameAnnotation] == serviceAccountName
+}
@@ -0,0 +1,209 @@
+package kube
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gobuffalo/envy"
+	"github.com/kradalby/bork/models"
+	kubernetesErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestWaitForTokenFindsExistingSecret(t *testing.T) {
+	serviceAccountName := getServiceAccountName(testNamespace)
+	c := &Client{client: fake.NewSimpleClientset(testTokenSecret(testNamespace, serviceAccountName))}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	if err := c.waitForServiceAccountToken(ctx, testNamespace, serviceAccountName); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+}
+
+func TestWaitForTokenWatchesForNewSecret(t *testing.T) {
+	serviceAccountName := getServiceAccountName(testNamespace)
+	clientset := fake.NewSimpleClientset(testTokenSecret(testNamespace, "someone-else"))
+
+	clientset.PrependWatchReactor("secrets", func(action k8stesting.Action) (bool, watch.Interface, error) {
+		w := watch.NewFake()
+		go func() {
+			time.Sleep(10 * time.Millisecond)
+			w.Add(testTokenSecret(testNamespace, serviceAccountName))
+		}()
+		return true, w, nil
+	})
+
+	c := &Client{client: clientset}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	if err := c.waitForServiceAccountToken(ctx, testNamespace, serviceAccountName); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+}
+
+func TestWaitForTokenTimeout(t *testing.T) {
+	c := &Client{client: fake.NewSimpleClientset()}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	err := c.waitForServiceAccountToken(ctx, testNamespace, getServiceAccountName(testNamespace))
+	if !IsTokenTimeout(err) {
+		t.Fatalf("expected token timeout, got %v", err)
+	}
+}
+
+func TestWaitForTokenBacksOffClosedWatches(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+
+	watches := 0
+	clientset.PrependWatchReactor("secrets", func(action k8stesting.Action) (bool, watch.Interface, error) {
+		watches++
+		w := watch.NewFake()
+		w.Stop()
+		return true, w, nil
+	})
+
+	c := &Client{client: clientset}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+
+	err := c.waitForServiceAccountToken(ctx, testNamespace, getServiceAccountName(testNamespace))
+	if !IsTokenTimeout(err) {
+		t.Fatalf("expected token timeout, got %v", err)
+	}
+
+	// Restarted after 100ms and 200ms, not in a tight loop
+	if watches > 3 {
+		t.Errorf("expected the watch to be restarted with a backoff, got %d watches", watches)
+	}
+}
+
+func TestProvisionTokenTimeoutRollsBack(t *testing.T) {
+	envy.Set("BORK_TOKEN_TIMEOUT", "20ms")
+	defer envy.Set("BORK_TOKEN_TIMEOUT", "30s")
+
+	// No token controller, the token is never issued
+	c := &Client{client: fake.NewSimpleClientset()}
+
+	err := c.CreateNamespaceWithServiceAccount(testNamespaceModel())
+	if !IsTokenTimeout(err) {
+		t.Fatalf("expected token timeout, got %v", err)
+	}
+
+	if provisionErr := err.(*ProvisionError); provisionErr.Step != "service account" {
+		t.Errorf("expected failed step service account, got %q", provisionErr.Step)
+	}
+
+	if remaining := remainingObjects(t, c, testNamespace); len(remaining) != 0 {
+		t.Errorf("expected rollback to remove everything, left %v", remaining)
+	}
+}
+
+func TestRotateToken(t *testing.T) {
+	c, _ := newTestClient()
+
+	if err := c.CreateNamespaceWithServiceAccount(testNamespaceModel()); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	oldSecretName, err := c.getSecretName(testNamespace)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	oldToken, err := c.GetToken(testNamespace, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newToken, err := c.RotateToken(testNamespace)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if newToken == "" || newToken == oldToken {
+		t.Errorf("expected a new token, got %q", newToken)
+	}
+
+	token, err := c.GetToken(testNamespace, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != newToken {
+		t.Errorf("expected service account to use the new token, got %q", token)
+	}
+
+	_, err = c.client.CoreV1().Secrets(testNamespace).Get(oldSecretName, metav1.GetOptions{})
+	if !kubernetesErrors.IsNotFound(err) {
+		t.Errorf("expected old token secret to be deleted, got %v", err)
+	}
+
+	config, err := c.CreateConfiguration(testNamespace, 0, KubeconfigOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(config, "token: "+newToken) {
+		t.Errorf("expected configuration to contain the new token, got:\n%s", config)
+	}
+}
+
+func TestRotateAllTokens(t *testing.T) {
+	c, _ := newTestClient()
+
+	owner := testUser("owner")
+	coOwner := testUser("coowner")
+
+	ns := testNamespaceModel()
+	ns.Owner = owner
+	ns.OwnerID = owner.ID
+	ns.CoOwners = models.Users{coOwner}
+
+	if err := c.CreateNamespaceWithServiceAccount(ns); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	oldTokens := map[string]string{}
+	for _, user := range ns.Users() {
+		token, err := c.GetMemberToken(testNamespace, user, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		oldTokens[user.Username] = token
+	}
+
+	if err := c.RotateAllTokens(ns); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	for _, user := range ns.Users() {
+		token, err := c.GetMemberToken(testNamespace, user, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if token == "" || token == oldTokens[user.Username] {
+			t.Errorf("expected a new token for %s, got %q", user.Username, token)
+		}
+
+		secrets, err := c.client.CoreV1().Secrets(testNamespace).List(metav1.ListOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := range secrets.Items {
+			secret := &secrets.Items[i]
+			if isServiceAccountToken(secret, getMemberServiceAccountName(testNamespace, user)) && string(secret.Data["token"]) == oldTokens[user.Username] {
+				t.Errorf("expected the old token secret of %s to be deleted", user.Username)
+			}
+		}
+	}
+}
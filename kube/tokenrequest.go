@@ -0,0 +1,146 @@
+package kube
+
+import (
+	"io/ioutil"
+	"log"
+	"time"
+
+	"github.com/gobuffalo/envy"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	kubernetesErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Newer clusters no longer create a token secret for every service
+// account. With the tokenrequest credential mode tokens are minted with
+// the TokenRequest API instead, bound to the service account and
+// expiring after a TTL chosen by the caller. Clusters without the API
+// still get the token from the legacy secret.
+const (
+	// CredentialModeSecret reads the token from the legacy token secret
+	CredentialModeSecret = "secret"
+	// CredentialModeTokenRequest mints expiring tokens
+	CredentialModeTokenRequest = "tokenrequest"
+)
+
+// The API server does not issue tokens valid for less than ten minutes
+const minTokenTTL = 10 * time.Minute
+
+// rootCAConfigMapName is published in every namespace by newer clusters
+const rootCAConfigMapName = "kube-root-ca.crt"
+
+// CredentialMode returns how service account tokens are handed out.
+func CredentialMode() string {
+	if envy.Get("BORK_CREDENTIAL_MODE", CredentialModeSecret) == CredentialModeTokenRequest {
+		return CredentialModeTokenRequest
+	}
+	return CredentialModeSecret
+}
+
+// TokenTTL returns how long minted tokens are valid when the caller
+// does not choose.
+func TokenTTL() time.Duration {
+	return durationFromEnv("BORK_TOKEN_TTL", time.Hour)
+}
+
+// MaxTokenTTL returns the longest TTL a caller can choose.
+func MaxTokenTTL() time.Duration {
+	return durationFromEnv("BORK_TOKEN_MAX_TTL", 24*time.Hour)
+}
+
+// ClampTokenTTL returns the TTL a token is minted with, the default for
+// zero and otherwise limited to what the API server and the
+// configuration allow.
+func ClampTokenTTL(ttl time.Duration) time.Duration {
+	if ttl == 0 {
+		ttl = TokenTTL()
+	}
+	if max := MaxTokenTTL(); ttl > max {
+		ttl = max
+	}
+	if ttl < minTokenTTL {
+		ttl = minTokenTTL
+	}
+	return ttl
+}
+
+// serviceAccountToken returns a token for the service account, minted
+// with the given TTL in the tokenrequest credential mode and read from
+// the token secret otherwise.
+func (c *Client) serviceAccountToken(namespace string, serviceAccountName string, ttl time.Duration) (string, error) {
+	if CredentialMode() == CredentialModeTokenRequest {
+		token, err := c.requestToken(namespace, serviceAccountName, ttl)
+		if err == nil {
+			return token, nil
+		}
+		if !isTokenRequestUnsupported(err) {
+			return "", err
+		}
+		log.Printf("[INFO] TokenRequest not available for %s in namespace %s, using token secret", serviceAccountName, namespace)
+	}
+
+	secret, err := c.getServiceAccountSecret(namespace, serviceAccountName)
+	if err != nil {
+		return "", err
+	}
+
+	return string(secret.Data["token"]), nil
+}
+
+func (c *Client) requestToken(namespace string, serviceAccountName string, ttl time.Duration) (string, error) {
+	seconds := int64(ClampTokenTTL(ttl).Seconds())
+
+	request, err := c.client.CoreV1().ServiceAccounts(namespace).CreateToken(serviceAccountName, &authenticationv1.TokenRequest{
+		Spec: authenticationv1.TokenRequestSpec{
+			ExpirationSeconds: &seconds,
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return request.Status.Token, nil
+}
+
+// isTokenRequestUnsupported reports if the API server does not serve
+// the token subresource of service accounts.
+func isTokenRequestUnsupported(err error) bool {
+	return kubernetesErrors.IsNotFound(err) || kubernetesErrors.IsMethodNotSupported(err)
+}
+
+// getRootCertificate returns the CA of the API server without a token
+// secret, from the CA config map of the namespace or the configuration
+// bork connects with.
+func (c *Client) getRootCertificate(namespace string) (string, error) {
+	configMap, err := c.client.CoreV1().ConfigMaps(namespace).Get(rootCAConfigMapName, metav1.GetOptions{})
+	if err == nil && configMap.Data["ca.crt"] != "" {
+		return configMap.Data["ca.crt"], nil
+	}
+	if err != nil && !kubernetesErrors.IsNotFound(err) {
+		return "", err
+	}
+
+	if len(c.config.CAData) != 0 {
+		return string(c.config.CAData), nil
+	}
+
+	if c.config.CAFile != "" {
+		ca, err := ioutil.ReadFile(c.config.CAFile)
+		if err != nil {
+			return "", err
+		}
+		return string(ca), nil
+	}
+
+	return "", kubernetesErrors.NewNotFound(corev1.Resource("configmaps"), rootCAConfigMapName)
+}
+
+func durationFromEnv(key string, fallback time.Duration) time.Duration {
+	duration, err := time.ParseDuration(envy.Get(key, fallback.String()))
+	if err != nil {
+		log.Printf("[Error] Invalid %s, using %s: %#v", key, fallback, err)
+		return fallback
+	}
+	return duration
+}
@@ -0,0 +1,181 @@
+package kube
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gobuffalo/envy"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	kubernetesErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func withCredentialMode(mode string) func() {
+	envy.Set("BORK_CREDENTIAL_MODE", mode)
+	return func() {
+		envy.Set("BORK_CREDENTIAL_MODE", CredentialModeSecret)
+	}
+}
+
+// serveTokenRequests makes the fake clientset answer token requests
+// with a token naming the service account and the TTL.
+func serveTokenRequests(clientset *fake.Clientset) {
+	clientset.PrependReactor("create", "serviceaccounts", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if action.GetSubresource() != "token" {
+			return false, nil, nil
+		}
+
+		create := action.(k8stesting.CreateActionImpl)
+		request := create.GetObject().(*authenticationv1.TokenRequest)
+		seconds := *request.Spec.ExpirationSeconds
+
+		request.Status = authenticationv1.TokenRequestStatus{
+			Token:               fmt.Sprintf("minted-%s-%d", create.Name, seconds),
+			ExpirationTimestamp: metav1.NewTime(time.Now().Add(time.Duration(seconds) * time.Second)),
+		}
+		return true, request, nil
+	})
+}
+
+func TestClampTokenTTL(t *testing.T) {
+	tests := []struct {
+		ttl      time.Duration
+		expected time.Duration
+	}{
+		{0, time.Hour},
+		{time.Minute, minTokenTTL},
+		{8 * time.Hour, 8 * time.Hour},
+		{48 * time.Hour, 24 * time.Hour},
+	}
+
+	for _, tt := range tests {
+		if ttl := ClampTokenTTL(tt.ttl); ttl != tt.expected {
+			t.Errorf("ttl %s: expected %s, got %s", tt.ttl, tt.expected, ttl)
+		}
+	}
+}
+
+func TestTokenRequestCredentials(t *testing.T) {
+	defer withCredentialMode(CredentialModeTokenRequest)()
+
+	c, clientset := newTestClient(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: rootCAConfigMapName, Namespace: testNamespace},
+		Data:       map[string]string{"ca.crt": "root-certificate"},
+	})
+	serveTokenRequests(clientset)
+
+	if err := c.CreateNamespaceWithServiceAccount(testNamespaceModel()); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	token, err := c.GetToken(testNamespace, 2*time.Hour)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if expected := "minted-" + getServiceAccountName(testNamespace) + "-7200"; token != expected {
+		t.Errorf("expected token %q, got %q", expected, token)
+	}
+
+	config, err := c.CreateDeployConfiguration(testNamespace, 0, KubeconfigOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if expected := "token: minted-" + getDeployServiceAccountName(testNamespace) + "-3600"; !strings.Contains(config, expected) {
+		t.Errorf("expected configuration to contain %q, got:\n%s", expected, config)
+	}
+}
+
+func TestTokenRequestCertificateWithoutSecret(t *testing.T) {
+	defer withCredentialMode(CredentialModeTokenRequest)()
+
+	c, _ := newTestClient(
+		&corev1.ServiceAccount{
+			ObjectMeta: metav1.ObjectMeta{Name: getServiceAccountName(testNamespace), Namespace: testNamespace},
+		},
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: rootCAConfigMapName, Namespace: testNamespace},
+			Data:       map[string]string{"ca.crt": "root-certificate"},
+		},
+	)
+
+	certificate, err := c.GetCertificate(testNamespace)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if certificate != "root-certificate" {
+		t.Errorf("expected the certificate of the config map, got %q", certificate)
+	}
+}
+
+func TestTokenRequestFallsBackToSecret(t *testing.T) {
+	defer withCredentialMode(CredentialModeTokenRequest)()
+
+	c, clientset := newTestClient(
+		&corev1.ServiceAccount{
+			ObjectMeta: metav1.ObjectMeta{Name: getServiceAccountName(testNamespace), Namespace: testNamespace},
+			Secrets:    []corev1.ObjectReference{{Name: getServiceAccountName(testNamespace) + "-token-abcde"}},
+		},
+		testTokenSecret(testNamespace, getServiceAccountName(testNamespace)),
+	)
+	clientset.PrependReactor("create", "serviceaccounts", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if action.GetSubresource() != "token" {
+			return false, nil, nil
+		}
+		return true, nil, kubernetesErrors.NewNotFound(corev1.Resource("serviceaccounts/token"), "")
+	})
+
+	token, err := c.GetToken(testNamespace, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if expected := "token-" + getServiceAccountName(testNamespace); token != expected {
+		t.Errorf("expected the legacy token %q, got %q", expected, token)
+	}
+}
+
+func TestTokenRequestRotationRecreatesServiceAccount(t *testing.T) {
+	defer withCredentialMode(CredentialModeTokenRequest)()
+
+	c, clientset := newTestClient()
+	serveTokenRequests(clientset)
+
+	if err := c.CreateNamespaceWithServiceAccount(testNamespaceModel()); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	clientset.ClearActions()
+
+	token, err := c.RotateToken(testNamespace)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !strings.HasPrefix(token, "minted-") {
+		t.Errorf("expected a minted token, got %q", token)
+	}
+
+	deleted := false
+	for _, action := range clientset.Actions() {
+		if action.Matches("create", "secrets") {
+			t.Errorf("expected no token secret to be created, got %#v", action)
+		}
+		if action.Matches("delete", "serviceaccounts") {
+			deleted = true
+		}
+	}
+	if !deleted {
+		t.Error("expected the service account to be recreated")
+	}
+
+	serviceAccount, err := c.client.CoreV1().ServiceAccounts(testNamespace).Get(getServiceAccountName(testNamespace), metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("expected the service account to exist, got %s", err)
+	}
+	if serviceAccount.Labels[managedByLabel] != managedBy {
+		t.Errorf("expected the labels to be kept, got %v", serviceAccount.Labels)
+	}
+}
@@ -0,0 +1 @@
+ALTER TABLE namespaces DROP COLUMN locked;
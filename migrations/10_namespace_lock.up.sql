@@ -0,0 +1 @@
+ALTER TABLE namespaces ADD COLUMN locked boolean NOT NULL DEFAULT false;
@@ -0,0 +1,2 @@
+ALTER TABLE users DROP COLUMN namespace_ttl;
+ALTER TABLE namespaces DROP COLUMN expires_at;
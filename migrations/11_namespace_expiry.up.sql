@@ -0,0 +1,2 @@
+ALTER TABLE namespaces ADD COLUMN expires_at timestamp without time zone;
+ALTER TABLE users ADD COLUMN namespace_ttl character varying(255) NOT NULL DEFAULT '';
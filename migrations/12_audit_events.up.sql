@@ -0,0 +1,22 @@
+CREATE TABLE audit_events (
+  id uuid NOT NULL
+, created_at timestamp without time zone NOT NULL
+, updated_at timestamp without time zone NOT NULL
+, actor_id uuid
+, actor character varying(255) NOT NULL DEFAULT ''
+, source character varying(255) NOT NULL DEFAULT ''
+, action character varying(255) NOT NULL
+, target_type character varying(255) NOT NULL DEFAULT ''
+, target_id character varying(255) NOT NULL DEFAULT ''
+, target character varying(255) NOT NULL DEFAULT ''
+, namespace_id uuid
+, source_ip character varying(255) NOT NULL DEFAULT ''
+, outcome character varying(255) NOT NULL
+, status integer NOT NULL DEFAULT 0
+, detail text NOT NULL DEFAULT ''
+, PRIMARY KEY (id)
+);
+
+CREATE INDEX audit_events_created_at_idx ON audit_events (created_at);
+CREATE INDEX audit_events_namespace_id_idx ON audit_events (namespace_id, created_at);
+CREATE INDEX audit_events_actor_id_idx ON audit_events (actor_id, created_at);
@@ -0,0 +1 @@
+ALTER TABLE namespaces ADD COLUMN quota text NOT NULL DEFAULT '{}';
@@ -0,0 +1 @@
+ALTER TABLE namespaces ADD COLUMN network_policy character varying(255) NOT NULL DEFAULT '';
@@ -0,0 +1,2 @@
+ALTER TABLE namespaces DROP COLUMN cluster_id;
+DROP TABLE clusters;
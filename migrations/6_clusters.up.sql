@@ -0,0 +1,13 @@
+CREATE TABLE clusters (
+  id uuid NOT NULL
+, created_at timestamp without time zone NOT NULL
+, updated_at timestamp without time zone NOT NULL
+, name character varying(255) NOT NULL
+, endpoint character varying(255) NOT NULL
+, certificate_authority text NOT NULL DEFAULT ''
+, kubeconfig text NOT NULL DEFAULT ''
+, PRIMARY KEY (id)
+, UNIQUE (name)
+);
+
+ALTER TABLE namespaces ADD COLUMN cluster_id uuid REFERENCES clusters(id) ON DELETE RESTRICT;
@@ -0,0 +1,3 @@
+ALTER TABLE namespaces DROP COLUMN member_role_profiles;
+ALTER TABLE namespaces DROP COLUMN role_profile;
+DROP TABLE role_profiles;
@@ -0,0 +1,12 @@
+CREATE TABLE role_profiles (
+  id uuid NOT NULL
+, created_at timestamp without time zone NOT NULL
+, updated_at timestamp without time zone NOT NULL
+, name character varying(255) NOT NULL
+, rules text NOT NULL DEFAULT '[]'
+, PRIMARY KEY (id)
+, UNIQUE (name)
+);
+
+ALTER TABLE namespaces ADD COLUMN role_profile character varying(255) NOT NULL DEFAULT '';
+ALTER TABLE namespaces ADD COLUMN member_role_profiles text NOT NULL DEFAULT '{}';
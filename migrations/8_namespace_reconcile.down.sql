@@ -0,0 +1,3 @@
+ALTER TABLE namespaces DROP COLUMN reconciled_at;
+ALTER TABLE namespaces DROP COLUMN reconcile_error;
+ALTER TABLE namespaces DROP COLUMN reconcile_status;
@@ -0,0 +1,3 @@
+ALTER TABLE namespaces ADD COLUMN reconcile_status character varying(255) NOT NULL DEFAULT '';
+ALTER TABLE namespaces ADD COLUMN reconcile_error text NOT NULL DEFAULT '';
+ALTER TABLE namespaces ADD COLUMN reconciled_at timestamp without time zone;
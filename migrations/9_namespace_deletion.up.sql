@@ -0,0 +1 @@
+ALTER TABLE namespaces ADD COLUMN delete_at timestamp without time zone;
@@ -19,6 +19,48 @@ SET default_tablespace = '';
 
 SET default_with_oids = false;
 
+--
+-- Name: audit_events; Type: TABLE; Schema: public; Owner: bork
+--
+
+CREATE TABLE public.audit_events (
+    id uuid NOT NULL,
+    created_at timestamp without time zone NOT NULL,
+    updated_at timestamp without time zone NOT NULL,
+    actor_id uuid,
+    actor character varying(255) DEFAULT ''::character varying NOT NULL,
+    source character varying(255) DEFAULT ''::character varying NOT NULL,
+    action character varying(255) NOT NULL,
+    target_type character varying(255) DEFAULT ''::character varying NOT NULL,
+    target_id character varying(255) DEFAULT ''::character varying NOT NULL,
+    target character varying(255) DEFAULT ''::character varying NOT NULL,
+    namespace_id uuid,
+    source_ip character varying(255) DEFAULT ''::character varying NOT NULL,
+    outcome character varying(255) NOT NULL,
+    status integer DEFAULT 0 NOT NULL,
+    detail text DEFAULT ''::text NOT NULL
+);
+
+
+ALTER TABLE public.audit_events OWNER TO bork;
+
+--
+-- Name: clusters; Type: TABLE; Schema: public; Owner: bork
+--
+
+CREATE TABLE public.clusters (
+    id uuid NOT NULL,
+    created_at timestamp without time zone NOT NULL,
+    updated_at timestamp without time zone NOT NULL,
+    name character varying(255) NOT NULL,
+    endpoint character varying(255) NOT NULL,
+    certificate_authority text DEFAULT ''::text NOT NULL,
+    kubeconfig text DEFAULT ''::text NOT NULL
+);
+
+
+ALTER TABLE public.clusters OWNER TO bork;
+
 --
 -- Name: namespaces; Type: TABLE; Schema: public; Owner: bork
 --
@@ -28,7 +70,18 @@ CREATE TABLE public.namespaces (
     created_at timestamp without time zone NOT NULL,
     updated_at timestamp without time zone NOT NULL,
     name character varying(255) NOT NULL,
-    owner_id uuid
+    owner_id uuid,
+    quota text DEFAULT '{}'::text NOT NULL,
+    network_policy character varying(255) DEFAULT ''::character varying NOT NULL,
+    cluster_id uuid,
+    role_profile character varying(255) DEFAULT ''::character varying NOT NULL,
+    member_role_profiles text DEFAULT '{}'::text NOT NULL,
+    reconcile_status character varying(255) DEFAULT ''::character varying NOT NULL,
+    reconcile_error text DEFAULT ''::text NOT NULL,
+    reconciled_at timestamp without time zone,
+    delete_at timestamp without time zone,
+    locked boolean DEFAULT false NOT NULL,
+    expires_at timestamp without time zone
 );
 
 
@@ -46,6 +99,21 @@ CREATE TABLE public.namespaces_users (
 
 ALTER TABLE public.namespaces_users OWNER TO bork;
 
+--
+-- Name: role_profiles; Type: TABLE; Schema: public; Owner: bork
+--
+
+CREATE TABLE public.role_profiles (
+    id uuid NOT NULL,
+    created_at timestamp without time zone NOT NULL,
+    updated_at timestamp without time zone NOT NULL,
+    name character varying(255) NOT NULL,
+    rules text DEFAULT '[]'::text NOT NULL
+);
+
+
+ALTER TABLE public.role_profiles OWNER TO bork;
+
 --
 -- Name: schema_migration; Type: TABLE; Schema: public; Owner: bork
 --
@@ -72,12 +140,37 @@ CREATE TABLE public.users (
     is_active boolean NOT NULL,
     is_admin boolean NOT NULL,
     provider character varying(255) NOT NULL,
-    provider_id character varying(255) NOT NULL
+    provider_id character varying(255) NOT NULL,
+    namespace_ttl character varying(255) DEFAULT ''::character varying NOT NULL
 );
 
 
 ALTER TABLE public.users OWNER TO bork;
 
+--
+-- Name: audit_events audit_events_pkey; Type: CONSTRAINT; Schema: public; Owner: bork
+--
+
+ALTER TABLE ONLY public.audit_events
+    ADD CONSTRAINT audit_events_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: clusters clusters_name_key; Type: CONSTRAINT; Schema: public; Owner: bork
+--
+
+ALTER TABLE ONLY public.clusters
+    ADD CONSTRAINT clusters_name_key UNIQUE (name);
+
+
+--
+-- Name: clusters clusters_pkey; Type: CONSTRAINT; Schema: public; Owner: bork
+--
+
+ALTER TABLE ONLY public.clusters
+    ADD CONSTRAINT clusters_pkey PRIMARY KEY (id);
+
+
 --
 -- Name: namespaces namespaces_name_key; Type: CONSTRAINT; Schema: public; Owner: bork
 --
@@ -102,6 +195,22 @@ ALTER TABLE ONLY public.namespaces_users
     ADD CONSTRAINT namespaces_users_pkey PRIMARY KEY (namespace_id, user_id);
 
 
+--
+-- Name: role_profiles role_profiles_name_key; Type: CONSTRAINT; Schema: public; Owner: bork
+--
+
+ALTER TABLE ONLY public.role_profiles
+    ADD CONSTRAINT role_profiles_name_key UNIQUE (name);
+
+
+--
+-- Name: role_profiles role_profiles_pkey; Type: CONSTRAINT; Schema: public; Owner: bork
+--
+
+ALTER TABLE ONLY public.role_profiles
+    ADD CONSTRAINT role_profiles_pkey PRIMARY KEY (id);
+
+
 --
 -- Name: users users_pkey; Type: CONSTRAINT; Schema: public; Owner: bork
 --
@@ -118,6 +227,27 @@ ALTER TABLE ONLY public.users
     ADD CONSTRAINT users_username_key UNIQUE (username);
 
 
+--
+-- Name: audit_events_actor_id_idx; Type: INDEX; Schema: public; Owner: bork
+--
+
+CREATE INDEX audit_events_actor_id_idx ON public.audit_events USING btree (actor_id, created_at);
+
+
+--
+-- Name: audit_events_created_at_idx; Type: INDEX; Schema: public; Owner: bork
+--
+
+CREATE INDEX audit_events_created_at_idx ON public.audit_events USING btree (created_at);
+
+
+--
+-- Name: audit_events_namespace_id_idx; Type: INDEX; Schema: public; Owner: bork
+--
+
+CREATE INDEX audit_events_namespace_id_idx ON public.audit_events USING btree (namespace_id, created_at);
+
+
 --
 -- Name: schema_migration_version_idx; Type: INDEX; Schema: public; Owner: bork
 --
@@ -125,6 +255,14 @@ ALTER TABLE ONLY public.users
 CREATE UNIQUE INDEX schema_migration_version_idx ON public.schema_migration USING btree (version);
 
 
+--
+-- Name: namespaces namespaces_cluster_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: bork
+--
+
+ALTER TABLE ONLY public.namespaces
+    ADD CONSTRAINT namespaces_cluster_id_fkey FOREIGN KEY (cluster_id) REFERENCES public.clusters(id) ON DELETE RESTRICT;
+
+
 --
 -- Name: namespaces namespaces_owner_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: bork
 --
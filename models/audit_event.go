@@ -0,0 +1,106 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/gobuffalo/pop"
+	"github.com/gobuffalo/uuid"
+)
+
+// AuditEvent records who did what to which object, from where and how
+// it went. Events are written for every mutating API call, every read
+// of credentials and every CLI operation.
+type AuditEvent struct {
+	ID        uuid.UUID `json:"id" db:"id"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+	// ActorID is the user making an API call, Actor their username, the
+	// system user running the CLI or SystemActor
+	ActorID uuid.NullUUID `json:"actor_id" db:"actor_id"`
+	Actor   string        `json:"actor" db:"actor"`
+	Source  string        `json:"source" db:"source"`
+	Action  string        `json:"action" db:"action"`
+	// Target is the object acted on, the name is kept as the object may
+	// be deleted
+	TargetType  string        `json:"target_type" db:"target_type"`
+	TargetID    string        `json:"target_id" db:"target_id"`
+	Target      string        `json:"target" db:"target"`
+	NamespaceID uuid.NullUUID `json:"namespace_id" db:"namespace_id"`
+	SourceIP    string        `json:"source_ip" db:"source_ip"`
+	Outcome     string        `json:"outcome" db:"outcome"`
+	Status      int           `json:"status" db:"status"`
+	Detail      string        `json:"detail" db:"detail"`
+}
+
+// Sources and outcomes of audit events. A CLI operation is recorded as
+// started and updated when it finishes, one that is still started was
+// interrupted. The system source is bork acting on its own, like the
+// deleter.
+const (
+	AuditSourceAPI    = "api"
+	AuditSourceCLI    = "cli"
+	AuditSourceSystem = "system"
+
+	AuditSuccess = "success"
+	AuditFailure = "failure"
+	AuditStarted = "started"
+
+	// SystemActor is the actor of events with the system source
+	SystemActor = "bork"
+)
+
+// String is not required by pop and may be deleted
+func (a AuditEvent) String() string {
+	ja, _ := json.Marshal(a)
+	return string(ja)
+}
+
+// AuditEvents is not required by pop and may be deleted
+type AuditEvents []AuditEvent
+
+// AuditFilter selects audit events, empty fields match everything.
+type AuditFilter struct {
+	ActorID     string
+	NamespaceID string
+	Action      string
+	TargetType  string
+	Outcome     string
+	Source      string
+	Since       time.Time
+	Until       time.Time
+}
+
+// Apply adds the conditions of the filter to the query.
+func (f AuditFilter) Apply(q *pop.Query) *pop.Query {
+	for _, condition := range []struct {
+		column string
+		value  string
+	}{
+		{"actor_id", f.ActorID},
+		{"namespace_id", f.NamespaceID},
+		{"action", f.Action},
+		{"target_type", f.TargetType},
+		{"outcome", f.Outcome},
+		{"source", f.Source},
+	} {
+		if condition.value != "" {
+			q = q.Where(condition.column+" = ?", condition.value)
+		}
+	}
+
+	if !f.Since.IsZero() {
+		q = q.Where("created_at >= ?", f.Since)
+	}
+	if !f.Until.IsZero() {
+		q = q.Where("created_at < ?", f.Until)
+	}
+	return q
+}
+
+// RecordAuditEvent stores the event. It should be given a connection
+// outside the transaction of the request, so events of failed requests
+// are not rolled back.
+func RecordAuditEvent(tx *pop.Connection, event *AuditEvent) error {
+	return tx.Create(event)
+}
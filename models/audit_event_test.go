@@ -0,0 +1,43 @@
+package models_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gobuffalo/pop"
+	"github.com/kradalby/bork/models"
+)
+
+func Test_AuditFilter_Apply(t *testing.T) {
+	since := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	filter := models.AuditFilter{
+		NamespaceID: "7b5e0a52-8f33-4a59-9e0c-7d0f4b0e3f61",
+		Outcome:     models.AuditFailure,
+		Since:       since,
+	}
+
+	query := filter.Apply(models.DB.Q())
+	sql, args := query.ToSQL(&pop.Model{Value: &models.AuditEvents{}})
+
+	for _, condition := range []string{"namespace_id = $1", "outcome = $2", "created_at >= $3"} {
+		if !strings.Contains(sql, condition) {
+			t.Errorf("expected %q in %s", condition, sql)
+		}
+	}
+	for _, column := range []string{"actor_id", "action", "source", "created_at <"} {
+		if strings.Contains(sql, column+" ") {
+			t.Errorf("expected no condition on %s in %s", column, sql)
+		}
+	}
+	if len(args) != 3 || args[1] != models.AuditFailure || args[2] != since {
+		t.Errorf("unexpected arguments %v", args)
+	}
+}
+
+func Test_AuditFilter_Apply_Empty(t *testing.T) {
+	query := models.AuditFilter{}.Apply(models.DB.Q())
+	if sql, args := query.ToSQL(&pop.Model{Value: &models.AuditEvents{}}); strings.Contains(sql, "WHERE") || len(args) != 0 {
+		t.Errorf("expected no conditions, got %s %v", sql, args)
+	}
+}
@@ -0,0 +1,54 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/gobuffalo/pop"
+	"github.com/gobuffalo/uuid"
+	"github.com/gobuffalo/validate"
+	"github.com/gobuffalo/validate/validators"
+)
+
+// Cluster is a Kubernetes cluster namespaces can be created in.
+// Namespaces without a cluster live on the default cluster, the one
+// bork is configured with at startup.
+type Cluster struct {
+	ID        uuid.UUID `json:"id" db:"id"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+	Name      string    `json:"name" db:"name"`
+	// Endpoint is the public address of the API server given to users
+	Endpoint string `json:"endpoint" db:"endpoint"`
+	// CertificateAuthority is the PEM encoded CA of the API server given
+	// to users, if empty the CA from the service account token is used
+	CertificateAuthority string `json:"certificate_authority" db:"certificate_authority"`
+	// Kubeconfig is used by bork to connect to the cluster, only the
+	// default cluster uses the in-cluster configuration
+	Kubeconfig string `json:"-" db:"kubeconfig"`
+}
+
+// String is not required by pop and may be deleted
+func (c Cluster) String() string {
+	jc, _ := json.Marshal(c)
+	return string(jc)
+}
+
+// Clusters is not required by pop and may be deleted
+type Clusters []Cluster
+
+// String is not required by pop and may be deleted
+func (c Clusters) String() string {
+	jc, _ := json.Marshal(c)
+	return string(jc)
+}
+
+// Validate gets run every time you call a "pop.Validate*"
+// (pop.ValidateAndSave, pop.ValidateAndCreate, pop.ValidateAndUpdate) method.
+func (c *Cluster) Validate(tx *pop.Connection) (*validate.Errors, error) {
+	return validate.Validate(
+		&validators.StringIsPresent{Field: c.Name, Name: "Name"},
+		&validators.StringIsPresent{Field: c.Endpoint, Name: "Endpoint"},
+		&validators.StringIsPresent{Field: c.Kubeconfig, Name: "Kubeconfig"},
+	), nil
+}
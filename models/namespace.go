@@ -3,24 +3,47 @@ package models
 import (
 	"encoding/json"
 	"log"
+	"path"
 	"time"
 
 	"github.com/gobuffalo/pop"
+	"github.com/gobuffalo/pop/nulls"
 	"github.com/gobuffalo/uuid"
 	"github.com/gobuffalo/validate"
 	"github.com/gobuffalo/validate/validators"
 )
 
 type Namespace struct {
-	ID        uuid.UUID `json:"id" db:"id"`
-	CreatedAt time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
-	Owner     User      `json:"owner" belongs_to:"owner"`
-	OwnerID   uuid.UUID `json:"owner_id" db:"owner_id"`
-	CoOwners  Users     `json:"co_owners" many_to_many:"namespaces_users"`
-	Name      string    `json:"name" db:"name"`
+	ID                 uuid.UUID          `json:"id" db:"id"`
+	CreatedAt          time.Time          `json:"created_at" db:"created_at"`
+	UpdatedAt          time.Time          `json:"updated_at" db:"updated_at"`
+	Owner              User               `json:"owner" belongs_to:"owner"`
+	OwnerID            uuid.UUID          `json:"owner_id" db:"owner_id"`
+	CoOwners           Users              `json:"co_owners" many_to_many:"namespaces_users"`
+	Name               string             `json:"name" db:"name"`
+	Quota              Quota              `json:"quota" db:"quota"`
+	NetworkPolicy      string             `json:"network_policy" db:"network_policy"`
+	ClusterID          uuid.NullUUID      `json:"cluster_id" db:"cluster_id"`
+	RoleProfile        string             `json:"role_profile" db:"role_profile"`
+	MemberRoleProfiles MemberRoleProfiles `json:"member_role_profiles" db:"member_role_profiles"`
+	ReconcileStatus    string             `json:"reconcile_status" db:"reconcile_status"`
+	ReconcileError     string             `json:"reconcile_error" db:"reconcile_error"`
+	ReconciledAt       nulls.Time         `json:"reconciled_at" db:"reconciled_at"`
+	DeleteAt           nulls.Time         `json:"delete_at" db:"delete_at"`
+	Locked             bool               `json:"locked" db:"locked"`
+	ExpiresAt          nulls.Time         `json:"expires_at" db:"expires_at"`
 }
 
+// Reconcile statuses of a namespace, a namespace that has not been
+// reconciled yet has an empty status.
+const (
+	ReconcileSynced = "synced"
+	ReconcileFailed = "failed"
+	// ReconcileDeleting marks a namespace being deleted, the controller
+	// leaves it alone so it does not recreate the objects removed
+	ReconcileDeleting = "deleting"
+)
+
 // String is not required by pop and may be deleted
 func (n Namespace) String() string {
 	jn, _ := json.Marshal(n)
@@ -36,6 +59,92 @@ func (n Namespaces) String() string {
 	return string(jn)
 }
 
+// FilterName returns the namespaces with a name matching the shell
+// pattern, like "bork-kradalby-*". An empty pattern matches all.
+func (n Namespaces) FilterName(pattern string) (Namespaces, error) {
+	if pattern == "" {
+		return n, nil
+	}
+
+	filtered := Namespaces{}
+	for _, namespace := range n {
+		match, err := path.Match(pattern, namespace.Name)
+		if err != nil {
+			return nil, err
+		}
+		if match {
+			filtered = append(filtered, namespace)
+		}
+	}
+	return filtered, nil
+}
+
+// MemberNamespaces returns the namespaces the user owns or co-owns,
+// ordered by name.
+func MemberNamespaces(tx *pop.Connection, user User) (Namespaces, error) {
+	namespaces := Namespaces{}
+	err := tx.Eager().
+		Where("owner_id = ? OR id IN (SELECT namespace_id FROM namespaces_users WHERE user_id = ?)", user.ID, user.ID).
+		Order("name").
+		All(&namespaces)
+	return namespaces, err
+}
+
+// SetReconcileStatus records the result of reconciling the namespace
+// with the cluster. Only the reconcile columns are written, the rest of
+// the row may have changed in the meantime.
+func (n *Namespace) SetReconcileStatus(tx *pop.Connection, status string, reconcileErr error) error {
+	n.ReconcileStatus = status
+	n.ReconcileError = ""
+	if reconcileErr != nil {
+		n.ReconcileError = reconcileErr.Error()
+	}
+	n.ReconciledAt = nulls.NewTime(time.Now())
+
+	return tx.RawQuery(
+		"UPDATE namespaces SET reconcile_status = ?, reconcile_error = ?, reconciled_at = ? WHERE id = ?",
+		n.ReconcileStatus, n.ReconcileError, n.ReconciledAt, n.ID,
+	).Exec()
+}
+
+// PendingDeletion reports if the namespace is scheduled for deletion
+// and can still be restored.
+func (n *Namespace) PendingDeletion() bool {
+	return n.DeleteAt.Valid
+}
+
+// ScheduleDeletion marks the namespace to be deleted at the given time.
+// Only the deletion column is written.
+func (n *Namespace) ScheduleDeletion(tx *pop.Connection, at time.Time) error {
+	n.DeleteAt = nulls.NewTime(at)
+	return tx.RawQuery("UPDATE namespaces SET delete_at = ? WHERE id = ?", n.DeleteAt, n.ID).Exec()
+}
+
+// CancelDeletion takes the namespace out of pending deletion.
+func (n *Namespace) CancelDeletion(tx *pop.Connection) error {
+	n.DeleteAt = nulls.Time{}
+	return tx.RawQuery("UPDATE namespaces SET delete_at = NULL WHERE id = ?", n.ID).Exec()
+}
+
+// SetLocked locks or unlocks the namespace, a locked namespace can not
+// be deleted. Only the lock column is written.
+func (n *Namespace) SetLocked(tx *pop.Connection, locked bool) error {
+	n.Locked = locked
+	return tx.RawQuery("UPDATE namespaces SET locked = ? WHERE id = ?", n.Locked, n.ID).Exec()
+}
+
+// Expired reports if the expiry of the namespace has passed.
+func (n *Namespace) Expired(now time.Time) bool {
+	return n.ExpiresAt.Valid && !n.ExpiresAt.Time.After(now)
+}
+
+// SetExpiry changes when the namespace expires, an invalid time never
+// expires it. Only the expiry column is written.
+func (n *Namespace) SetExpiry(tx *pop.Connection, expiresAt nulls.Time) error {
+	n.ExpiresAt = expiresAt
+	return tx.RawQuery("UPDATE namespaces SET expires_at = ? WHERE id = ?", n.ExpiresAt, n.ID).Exec()
+}
+
 // Validate gets run every time you call a "pop.Validate*"
 // (pop.ValidateAndSave, pop.ValidateAndCreate, pop.ValidateAndUpdate) method.
 // This method is not required and may be deleted.
@@ -100,3 +209,19 @@ func (n *Namespace) isOwnerOrCoOwner(user User) bool {
 	}
 	return false
 }
+
+// CreateWithCoOwners stores a new namespace with the co-owners set on
+// it, used when the row is rebuilt from a namespace in the cluster.
+func (n *Namespace) CreateWithCoOwners(tx *pop.Connection) error {
+	if err := tx.Create(n); err != nil {
+		return err
+	}
+
+	for _, coOwner := range n.CoOwners {
+		err := tx.RawQuery("INSERT INTO namespaces_users (namespace_id, user_id) VALUES (?, ?)", n.ID, coOwner.ID).Exec()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
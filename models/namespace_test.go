@@ -1,7 +1,107 @@
 package models_test
 
-import "testing"
+import (
+	"testing"
 
-func Test_Namespace(t *testing.T) {
-	t.Fatal("This test needs to be implemented!")
+	"github.com/gobuffalo/uuid"
+	"github.com/kradalby/bork/models"
+)
+
+func newUser(username string) models.User {
+	return models.User{
+		ID:       uuid.Must(uuid.NewV4()),
+		Username: username,
+	}
+}
+
+func Test_Namespace_Users(t *testing.T) {
+	owner := newUser("owner")
+	coOwner := newUser("coowner")
+
+	ns := models.Namespace{
+		Owner:    owner,
+		OwnerID:  owner.ID,
+		CoOwners: models.Users{coOwner},
+	}
+
+	users := ns.Users()
+	if len(users) != 2 {
+		t.Fatalf("expected 2 users, got %d", len(users))
+	}
+
+	if users[0].ID != owner.ID {
+		t.Errorf("expected owner first, got %s", users[0].Username)
+	}
+
+	if users[1].ID != coOwner.ID {
+		t.Errorf("expected co-owner second, got %s", users[1].Username)
+	}
+}
+
+func Test_Namespace_AddCoOwner(t *testing.T) {
+	owner := newUser("owner")
+	coOwner := newUser("coowner")
+
+	ns := models.Namespace{
+		Owner:   owner,
+		OwnerID: owner.ID,
+	}
+
+	ns.AddCoOwner(coOwner)
+	if len(ns.CoOwners) != 1 || ns.CoOwners[0].ID != coOwner.ID {
+		t.Fatalf("expected co-owner to be added, got %v", ns.CoOwners)
+	}
+
+	// Adding the owner or an existing co-owner does nothing
+	ns.AddCoOwner(owner)
+	ns.AddCoOwner(coOwner)
+	if len(ns.CoOwners) != 1 {
+		t.Errorf("expected a single co-owner, got %v", ns.CoOwners)
+	}
+}
+
+func Test_Namespace_Validate(t *testing.T) {
+	ns := models.Namespace{}
+
+	verrs, err := ns.Validate(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !verrs.HasAny() {
+		t.Error("expected a namespace without name to be invalid")
+	}
+}
+
+func Test_Namespaces_FilterName(t *testing.T) {
+	namespaces := models.Namespaces{
+		{Name: "bork-kradalby-api"},
+		{Name: "bork-kradalby-web"},
+		{Name: "bork-other-api"},
+	}
+
+	tests := []struct {
+		pattern  string
+		expected int
+	}{
+		{"", 3},
+		{"bork-kradalby-*", 2},
+		{"*-api", 2},
+		{"bork-other-api", 1},
+		{"nothing", 0},
+	}
+
+	for _, tt := range tests {
+		filtered, err := namespaces.FilterName(tt.pattern)
+		if err != nil {
+			t.Fatalf("pattern %q: unexpected error: %s", tt.pattern, err)
+		}
+		if len(filtered) != tt.expected {
+			t.Errorf("pattern %q: expected %d namespaces, got %d", tt.pattern, tt.expected, len(filtered))
+		}
+	}
+
+	if _, err := namespaces.FilterName("["); err == nil {
+		t.Error("expected an error for an invalid pattern")
+	}
 }
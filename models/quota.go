@@ -0,0 +1,79 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+
+	"github.com/pkg/errors"
+)
+
+// Quota holds the resource limits for a namespace. Every field is a
+// Kubernetes quantity (e.g. "500m" or "4Gi"), an empty field means
+// "not set".
+type Quota struct {
+	RequestsCPU          string `json:"requests_cpu,omitempty"`
+	RequestsMemory       string `json:"requests_memory,omitempty"`
+	LimitsCPU            string `json:"limits_cpu,omitempty"`
+	LimitsMemory         string `json:"limits_memory,omitempty"`
+	Pods                 string `json:"pods,omitempty"`
+	DefaultCPU           string `json:"default_cpu,omitempty"`
+	DefaultMemory        string `json:"default_memory,omitempty"`
+	DefaultRequestCPU    string `json:"default_request_cpu,omitempty"`
+	DefaultRequestMemory string `json:"default_request_memory,omitempty"`
+}
+
+// Merge returns a copy of q where every field set in override
+// replaces the value in q.
+func (q Quota) Merge(override Quota) Quota {
+	merged := q
+
+	pick := func(dst *string, src string) {
+		if src != "" {
+			*dst = src
+		}
+	}
+
+	pick(&merged.RequestsCPU, override.RequestsCPU)
+	pick(&merged.RequestsMemory, override.RequestsMemory)
+	pick(&merged.LimitsCPU, override.LimitsCPU)
+	pick(&merged.LimitsMemory, override.LimitsMemory)
+	pick(&merged.Pods, override.Pods)
+	pick(&merged.DefaultCPU, override.DefaultCPU)
+	pick(&merged.DefaultMemory, override.DefaultMemory)
+	pick(&merged.DefaultRequestCPU, override.DefaultRequestCPU)
+	pick(&merged.DefaultRequestMemory, override.DefaultRequestMemory)
+
+	return merged
+}
+
+// Value stores the quota as JSON in the database
+func (q Quota) Value() (driver.Value, error) {
+	b, err := json.Marshal(q)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+// Scan reads the JSON representation of the quota from the database
+func (q *Quota) Scan(src interface{}) error {
+	var b []byte
+	switch v := src.(type) {
+	case nil:
+		*q = Quota{}
+		return nil
+	case string:
+		b = []byte(v)
+	case []byte:
+		b = v
+	default:
+		return errors.Errorf("cannot scan %T into Quota", src)
+	}
+
+	if len(b) == 0 {
+		*q = Quota{}
+		return nil
+	}
+
+	return json.Unmarshal(b, q)
+}
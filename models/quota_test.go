@@ -0,0 +1,51 @@
+package models_test
+
+import (
+	"testing"
+
+	"github.com/kradalby/bork/models"
+)
+
+func Test_Quota_Merge(t *testing.T) {
+	defaults := models.Quota{
+		LimitsCPU:    "4",
+		LimitsMemory: "8Gi",
+		Pods:         "20",
+	}
+
+	merged := defaults.Merge(models.Quota{LimitsCPU: "8"})
+
+	if merged.LimitsCPU != "8" {
+		t.Errorf("expected override to win, got %q", merged.LimitsCPU)
+	}
+
+	if merged.LimitsMemory != "8Gi" || merged.Pods != "20" {
+		t.Errorf("expected defaults to be kept, got %#v", merged)
+	}
+}
+
+func Test_Quota_ValueScan(t *testing.T) {
+	quota := models.Quota{RequestsCPU: "500m", Pods: "10"}
+
+	value, err := quota.Value()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	scanned := models.Quota{}
+	if err := scanned.Scan(value); err != nil {
+		t.Fatal(err)
+	}
+
+	if scanned != quota {
+		t.Errorf("expected %#v, got %#v", quota, scanned)
+	}
+
+	if err := scanned.Scan(nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if scanned != (models.Quota{}) {
+		t.Errorf("expected empty quota, got %#v", scanned)
+	}
+}
@@ -0,0 +1,121 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"time"
+
+	"github.com/gobuffalo/pop"
+	"github.com/gobuffalo/uuid"
+	"github.com/gobuffalo/validate"
+	"github.com/gobuffalo/validate/validators"
+	"github.com/pkg/errors"
+)
+
+// RoleProfile is a named set of RBAC rules members of a namespace can
+// be given. Profiles in the database override the built-in profiles
+// with the same name.
+type RoleProfile struct {
+	ID        uuid.UUID   `json:"id" db:"id"`
+	CreatedAt time.Time   `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time   `json:"updated_at" db:"updated_at"`
+	Name      string      `json:"name" db:"name"`
+	Rules     PolicyRules `json:"rules" db:"rules"`
+}
+
+// RoleProfiles is not required by pop and may be deleted
+type RoleProfiles []RoleProfile
+
+// Validate gets run every time you call a "pop.Validate*"
+// (pop.ValidateAndSave, pop.ValidateAndCreate, pop.ValidateAndUpdate) method.
+func (r *RoleProfile) Validate(tx *pop.Connection) (*validate.Errors, error) {
+	return validate.Validate(
+		&validators.StringIsPresent{Field: r.Name, Name: "Name"},
+		&validators.RegexMatch{Field: r.Name, Name: "Name", Expr: "^[a-z0-9]([-a-z0-9]*[a-z0-9])?$"},
+	), nil
+}
+
+// PolicyRule mirrors the Kubernetes RBAC policy rule
+type PolicyRule struct {
+	APIGroups []string `json:"api_groups"`
+	Resources []string `json:"resources"`
+	Verbs     []string `json:"verbs"`
+}
+
+// PolicyRules is stored as JSON in the database
+type PolicyRules []PolicyRule
+
+// Value stores the rules as JSON in the database
+func (p PolicyRules) Value() (driver.Value, error) {
+	if p == nil {
+		p = PolicyRules{}
+	}
+	b, err := json.Marshal(p)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+// Scan reads the JSON representation of the rules from the database
+func (p *PolicyRules) Scan(src interface{}) error {
+	var b []byte
+	switch v := src.(type) {
+	case nil:
+		*p = PolicyRules{}
+		return nil
+	case string:
+		b = []byte(v)
+	case []byte:
+		b = v
+	default:
+		return errors.Errorf("cannot scan %T into PolicyRules", src)
+	}
+
+	if len(b) == 0 {
+		*p = PolicyRules{}
+		return nil
+	}
+
+	return json.Unmarshal(b, p)
+}
+
+// MemberRoleProfiles maps the ID of a co-owner to the role profile they
+// have been given in a namespace. Co-owners not in the map get the
+// profile of the namespace.
+type MemberRoleProfiles map[string]string
+
+// Value stores the map as JSON in the database
+func (m MemberRoleProfiles) Value() (driver.Value, error) {
+	if m == nil {
+		m = MemberRoleProfiles{}
+	}
+	b, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+// Scan reads the JSON representation of the map from the database
+func (m *MemberRoleProfiles) Scan(src interface{}) error {
+	var b []byte
+	switch v := src.(type) {
+	case nil:
+		*m = MemberRoleProfiles{}
+		return nil
+	case string:
+		b = []byte(v)
+	case []byte:
+		b = v
+	default:
+		return errors.Errorf("cannot scan %T into MemberRoleProfiles", src)
+	}
+
+	if len(b) == 0 {
+		*m = MemberRoleProfiles{}
+		return nil
+	}
+
+	return json.Unmarshal(b, m)
+}
@@ -24,6 +24,10 @@ type User struct {
 	IsActive   bool      `json:"is_active" db:"is_active"`
 	Provider   string    `json:"provider" db:"provider"`
 	ProviderID string    `json:"provider_id" db:"provider_id"`
+	// NamespaceTTL is how long the namespaces of the user live by
+	// default, a duration like "168h". Empty uses the default of their
+	// role.
+	NamespaceTTL string `json:"namespace_ttl" db:"namespace_ttl"`
 }
 
 // String is not required by pop and may be deleted
@@ -1,7 +1,53 @@
 package models_test
 
-import "testing"
+import (
+	"testing"
 
-func Test_User(t *testing.T) {
-	t.Fatal("This test needs to be implemented!")
+	"github.com/gobuffalo/envy"
+	"github.com/kradalby/bork/models"
+)
+
+func Test_User_NamespacePrefix(t *testing.T) {
+	envy.Set("BORK_NAMESPACE_PREFIX", "bork")
+
+	tests := []struct {
+		username string
+		prefix   string
+	}{
+		{"kradalby", "bork-kradalby"},
+		{"KraDalby", "bork-kradalby"},
+		{"Kristoffer Dalby", "bork-kristoffer-dalby"},
+	}
+
+	for _, tt := range tests {
+		u := models.User{Username: tt.username}
+		if prefix := u.NamespacePrefix(); prefix != tt.prefix {
+			t.Errorf("expected prefix %q for %q, got %q", tt.prefix, tt.username, prefix)
+		}
+	}
+}
+
+func Test_User_Validate(t *testing.T) {
+	u := models.User{}
+
+	verrs, err := u.Validate(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !verrs.HasAny() {
+		t.Error("expected a user without provider to be invalid")
+	}
+
+	u.Provider = "openid-connect"
+	u.ProviderID = "1234"
+
+	verrs, err = u.Validate(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if verrs.HasAny() {
+		t.Errorf("expected user to be valid, got %s", verrs)
+	}
 }